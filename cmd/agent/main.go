@@ -1,12 +1,20 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/galogen13/yandex-go-metrics/internal/agent"
+	"github.com/galogen13/yandex-go-metrics/internal/agent/collector"
 	"github.com/galogen13/yandex-go-metrics/internal/buildinfo"
 	"github.com/galogen13/yandex-go-metrics/internal/config"
 	"github.com/galogen13/yandex-go-metrics/internal/logger"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+	"go.uber.org/zap"
 )
 
 var (
@@ -15,6 +23,10 @@ var (
 	buildCommit  string = buildinfo.BuildInfoNotAvaluable
 )
 
+// pushCommand - команда однократной отправки метрик: agent push --type gauge --id Foo --value 1.5
+// или agent push -f batch.json. Остальные флаги и файл конфигурации - те же, что у агента.
+const pushCommand = "push"
+
 func main() {
 
 	buildinfo.PrintBuildInfo(buildVersion, buildDate, buildCommit)
@@ -31,11 +43,60 @@ func run() error {
 	}
 	defer logger.Log.Sync()
 
+	if len(os.Args) > 1 && os.Args[1] == pushCommand {
+		return runPush()
+	}
+
 	config, err := config.GetAgentConfig()
 	if err != nil {
 		return err
 	}
-	agent.Start(config)
+	agent.Start(config, collector.NewDefaultRegistry())
 
 	return nil
 }
+
+// runPush отправляет на сервер одну метрику или пакет метрик из файла
+func runPush() error {
+	agentConfig, pushConfig, err := config.GetAgentPushConfig()
+	if err != nil {
+		return err
+	}
+
+	batch, err := pushBatch(pushConfig)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	if err := agent.Push(ctx, agentConfig, batch); err != nil {
+		return err
+	}
+
+	logger.Log.Info("metrics pushed", zap.Int("count", len(batch)))
+	return nil
+}
+
+func pushBatch(pushConfig config.AgentPushConfig) ([]*metrics.Metric, error) {
+	if pushConfig.File == "" {
+		metric, err := agent.NewPushMetric(pushConfig.Type, pushConfig.ID, pushConfig.Value, pushConfig.Labels)
+		if err != nil {
+			return nil, err
+		}
+		return []*metrics.Metric{metric}, nil
+	}
+
+	if pushConfig.File == "-" {
+		return agent.ReadPushBatch(os.Stdin)
+	}
+
+	file, err := os.Open(pushConfig.File)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open metrics batch file: %w", err)
+	}
+	defer file.Close()
+
+	return agent.ReadPushBatch(file)
+}
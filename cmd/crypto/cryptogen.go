@@ -1,9 +1,15 @@
 package main
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"io/fs"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/galogen13/yandex-go-metrics/internal/crypto"
 	"github.com/galogen13/yandex-go-metrics/internal/logger"
@@ -14,6 +20,10 @@ func main() {
 	var (
 		privateOut string
 		publicOut  string
+		tlsDir     string
+		hosts      string
+		clientCN   string
+		days       int
 	)
 
 	if err := logger.Initialize("info"); err != nil {
@@ -23,8 +33,19 @@ func main() {
 
 	flag.StringVar(&privateOut, "private", "private.pem", "output file for private key")
 	flag.StringVar(&publicOut, "public", "public.pem", "output file for public key")
+	flag.StringVar(&tlsDir, "tls-dir", "", "generate TLS CA, server and client certificates into directory instead of RSA keys")
+	flag.StringVar(&hosts, "hosts", "localhost,127.0.0.1", "comma-separated server DNS names and IP addresses")
+	flag.StringVar(&clientCN, "client-cn", "agent", "client certificate common name")
+	flag.IntVar(&days, "days", 365, "certificates validity in days")
 	flag.Parse()
 
+	if tlsDir != "" {
+		if err := generateTLS(tlsDir, strings.Split(hosts, ","), clientCN, time.Duration(days)*24*time.Hour); err != nil {
+			log.Fatalf("Failed to generate certificates: %v\n", err)
+		}
+		return
+	}
+
 	logger.Log.Info("Generating RSA key pair")
 
 	privatePEM, publicPEM, err := crypto.GenerateKeys()
@@ -44,3 +65,75 @@ func main() {
 	logger.Log.Info("Private key", zap.String("path", privateOut))
 	logger.Log.Info("Public key", zap.String("path", publicOut))
 }
+
+// generateTLS создает в каталоге dir локальный удостоверяющий центр (ca.pem, ca-key.pem),
+// сертификат сервера (server.pem, server-key.pem) и клиентский сертификат агента (<clientCN>.pem, <clientCN>-key.pem).
+// Если центр в каталоге уже есть, сертификаты выпускаются им - так можно выпустить сертификаты для новых агентов.
+func generateTLS(dir string, hosts []string, clientCN string, validFor time.Duration) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("cannot create directory: %w", err)
+	}
+
+	caCertPath := filepath.Join(dir, "ca.pem")
+	caKeyPath := filepath.Join(dir, "ca-key.pem")
+
+	caCert, caKey, err := readPair(caCertPath, caKeyPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		logger.Log.Info("Generating CA")
+		caCert, caKey, err = crypto.GenerateCA("metrics local CA", validFor)
+		if err != nil {
+			return err
+		}
+		if err := writePair(caCertPath, caKeyPath, caCert, caKey); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	} else {
+		logger.Log.Info("Using existing CA", zap.String("path", caCertPath))
+	}
+
+	serverCert, serverKey, err := crypto.GenerateCertificate(caCert, caKey, hosts[0], hosts, crypto.ServerCert, validFor)
+	if err != nil {
+		return err
+	}
+	if err := writePair(filepath.Join(dir, "server.pem"), filepath.Join(dir, "server-key.pem"), serverCert, serverKey); err != nil {
+		return err
+	}
+
+	clientCert, clientKey, err := crypto.GenerateCertificate(caCert, caKey, clientCN, nil, crypto.ClientCert, validFor)
+	if err != nil {
+		return err
+	}
+	if err := writePair(filepath.Join(dir, clientCN+".pem"), filepath.Join(dir, clientCN+"-key.pem"), clientCert, clientKey); err != nil {
+		return err
+	}
+
+	logger.Log.Info("Certificates generated successfully",
+		zap.String("dir", dir),
+		zap.Strings("server hosts", hosts),
+		zap.String("client", clientCN))
+	return nil
+}
+
+func readPair(certPath, keyPath string) (certPEM, keyPEM string, err error) {
+	cert, err := os.ReadFile(certPath)
+	if err != nil {
+		return "", "", err
+	}
+	key, err := os.ReadFile(keyPath)
+	if err != nil {
+		return "", "", err
+	}
+	return string(cert), string(key), nil
+}
+
+func writePair(certPath, keyPath, certPEM, keyPEM string) error {
+	if err := os.WriteFile(certPath, []byte(certPEM), 0644); err != nil {
+		return fmt.Errorf("cannot write certificate: %w", err)
+	}
+	if err := os.WriteFile(keyPath, []byte(keyPEM), 0600); err != nil {
+		return fmt.Errorf("cannot write key: %w", err)
+	}
+	return nil
+}
@@ -278,6 +278,11 @@ func getResetCode(name string, expr ast.Expr) (string, error) {
 	case *ast.Ident:
 		return getSimpleResetCode(name, t), nil
 	case *ast.StarExpr:
+		if ident, ok := t.X.(*ast.Ident); ok && ident.Obj == nil && getZeroValue(ident.Name) == "nil" {
+			// тип объявлен в другом файле пакета и его устройство неизвестно:
+			// значение сбрасывается собственным методом Reset, если он есть
+			return getResetCodePointer(name), nil
+		}
 		rCode, err := getResetCode(name, t.X)
 		if err != nil {
 			return "", fmt.Errorf("cannot parse star expr: %w", err)
@@ -322,6 +327,14 @@ func getResetCodeStruct(name string) string {
 	return buf.String()
 }
 
+func getResetCodePointer(name string) string {
+	var buf bytes.Buffer
+	buf.WriteString("if resetter, ok := any(v." + name + ").(interface{ Reset() }); ok && v." + name + " != nil {\n")
+	buf.WriteString("\tresetter.Reset()\n")
+	buf.WriteString("}")
+	return buf.String()
+}
+
 func getSimpleResetCode(name string, t *ast.Ident) string {
 	return "v." + name + " = " + getIdentResetCode(t)
 }
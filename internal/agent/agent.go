@@ -3,8 +3,9 @@
 // - Тип gauge (float64) — метрика, новое значение которой полностью замещает текущее значение на сервере.
 // - Тип counter (int64) — метрика-счетчик. Агент отправляет дельту, на которую должно измениться значение счетчика за сервере.
 //
-// Агентом собираются следующие метрики:
-// 1. Метрики типа gauge из пакета runtime:
+// Метрики собираются сборщиками (см. пакет collector), которые регистрируются в реестре
+// и включаются/выключаются в конфигурации агента. Встроенные сборщики собирают следующие метрики:
+// 1. Метрики типа gauge из пакета runtime (сборщик runtime):
 // - Alloc,
 // - BuckHashSys,
 // - Frees,
@@ -32,44 +33,113 @@
 // - StackSys,
 // - Sys,
 // - TotalAlloc,
+// - RandomValue — обновляемое произвольное значение.
+// Значения вычисляются по метрикам пакета runtime/metrics, который не останавливает программу.
+// В опции samples сборщика runtime можно перечислить любые метрики runtime/metrics (например, /sched/latencies:seconds);
+// для гистограмм отдаются квантили распределения за время с прошлого сбора (метка quantile).
 //
-// 2. Метрики типа gauge из пакета runtime:
+// 2. Метрики типа gauge из пакета gopsutil (сборщик ps):
 // - TotalMemory,
 // - FreeMemory,
 // - CPUutilization1 (точное количество — по числу CPU, определяемому во время исполнения).
 //
-// 3. Произвольные метрики:
-// - PollCount (тип counter) — счётчик, увеличивающийся на 1 при каждом обновлении метрик из пакета runtime (на каждый pollInterval).
-// - RandomValue (тип gauge) — обновляемое произвольное значение.
+// 3. PollCount (тип counter) — счётчик, увеличивающийся на 1 каждый pollInterval.
+//
+// Сборщики, выключенные по умолчанию:
+// - disk — заполненность точек монтирования (DiskTotal, DiskUsed, DiskFree, DiskUsedPercent с меткой mountpoint, gauge)
+// и дельты счетчиков ввода-вывода устройств (DiskReadBytes, DiskWriteBytes, DiskReadCount, DiskWriteCount с меткой device, counter);
+// - net — дельты счетчиков интерфейсов (NetBytesSent, NetBytesRecv, NetPacketsSent, NetPacketsRecv, NetErrin, NetErrout
+// с меткой interface, counter);
+// - load — средняя загрузка системы (Load1, Load5, Load15, gauge);
+// - process — отслеживаемые процессы (ProcessCount<Name>, ProcessCPUPercent<Name>, ProcessRSS<Name>,
+// ProcessOpenFDs<Name>, ProcessThreads<Name>, ProcessUptime<Name>, gauge);
+// - statsd — метрики, которые приложения присылают агенту по UDP в формате StatsD;
+// - exec — метрики из вывода внешних команд, запускаемых со своим интервалом и таймаутом
+// (ExecSuccess<Name>, ExecExitCode<Name>, ExecDuration<Name>, gauge; ExecFailures<Name>, ExecTimeouts<Name>, ExecParseErrors<Name>, counter);
+// - textfile — метрики из файлов *.prom и *.json каталога, которые пишут внешние задачи
+// (TextfileMtime, TextfileStale, TextfileParseErrors с меткой file, gauge);
+// - scrape — метрики HTTP-эндпоинтов в формате Prometheus с меткой job
+// (ScrapeUp, ScrapeDuration, ScrapeSamples, gauge);
+// - cgroup — потребление CPU, памяти, ввода-вывода и PSI контрольными группами cgroup v2
+// с меткой cgroup (CgroupCPUUtilization, CgroupMemoryCurrent, CgroupIOReadBytes и т.п.).
+//
+// Каждый сборщик работает в своей горутине по своему расписанию, поэтому медленный сборщик
+// не задерживает остальные. В секции collectors для сборщика задаются интервал (по умолчанию - poll_interval),
+// таймаут сбора (по умолчанию равен интервалу), выравнивание сборов по границам интервала по часам (align)
+// и случайная задержка сбора (jitter), чтобы агенты парка не опрашивали источники одновременно.
+//
+// Вместе с каждым пакетом агент отправляет собственные метрики:
+// - AgentBatchesSent, AgentBatchesFailed, AgentSendRetries (counter) — доставленные и недоставленные пакеты, повторные попытки;
+// - AgentPayloadBytes, AgentPayloadGzipBytes, AgentPayloadEncryptedBytes (counter) — объем пакетов до и после сжатия и шифрования;
+// - AgentLastSendLatency (gauge) — длительность последней отправки в секундах;
+// - AgentPollDuration<Collector> (gauge) — длительность последнего сбора метрик сборщиком в секундах;
+// - AgentSpoolSegments, AgentSpoolBytes (gauge) — размер очереди на диске, если она включена;
+// - UpstreamUp<Host>, UpstreamFailures<Host>, UpstreamBackoff<Host> (gauge) — состояние серверов, если их несколько.
+//
+// Дельты метрик типа counter накапливаются агентом между отправками и уменьшаются
+// только на отправленную величину после успешного ответа сервера.
+//
+// Пакет, в котором больше batch_max_metrics метрик или размер которого в формате JSON превышает
+// batch_max_size байт, делится на части. Части отправляются параллельно горутинами отправки
+// (их количество задает rate_limit), повторяются и учитываются независимо друг от друга:
+// ошибка отправки одной части не отменяет доставку остальных.
+//
+// Каждая часть отправляется с идентификатором экземпляра агента, идентификатором и порядковым номером пакета
+// (заголовки X-Agent-ID, X-Batch-ID, X-Batch-Seq или поля запроса gRPC). Идентификатор не меняется при повторных
+// попытках и при отправке из очереди на диске, поэтому сервер не применяет повторно пакет, ответ на который
+// не дошел до агента, и дельты счетчиков не удваиваются.
+//
+// По умолчанию для метрик типа gauge отправляется последнее собранное значение.
+// В параметре gauge_aggregation для отдельных метрик (или шаблонов идентификаторов) можно задать
+// агрегаты значений, собранных за интервал отправки: last, min, max, mean, sum, p95.
+// Агрегаты отправляются отдельными метриками с суффиксами Min, Max, Mean, Sum, P95.
+//
+// В режиме send_changed_only агент не отправляет значения gauge, совпадающие с последними доставленными
+// на сервер, и нулевые дельты counter. Каждый heartbeat_every пакет (по умолчанию - каждый десятый)
+// отправляется полностью, чтобы сервер, потерявший данные, получил все метрики.
+//
+// Транспорт https шифрует соединение с сервером TLS. Сертификат сервера проверяется центром из параметра tls_ca
+// (или системными центрами, если он не задан); если заданы tls_cert и tls_key, агент предъявляет серверу
+// клиентский сертификат (mTLS). Для транспорта grpc TLS включается заданием tls_ca или tls_cert.
+// Локальный центр и сертификаты сервера и агентов выпускает команда cmd/crypto с флагом -tls-dir.
+//
+// В каждом запросе агент передает в заголовке X-Real-IP (для grpc - в метаданных x-real-ip) адрес своего
+// сетевого интерфейса, через который идут запросы на сервер. Если на сервере задан параметр trusted_subnet,
+// сервер принимает метрики только от агентов, адрес которых входит в одну из доверенных подсетей.
+//
+// Если задан параметр status_address, агент запускает HTTP-сервер состояния:
+// /healthz отвечает, пока агент работает, /readyz - после первого сбора метрик и первой успешной отправки,
+// /snapshot возвращает в формате JSON собранные значения gauge и накопленные дельты счетчиков.
+//
+// Ко всем метрикам агент добавляет метки host (имя хоста) и instance (параметр instance,
+// по умолчанию - имя хоста), а также дополнительные метки из параметра labels.
+// Это позволяет нескольким агентам отправлять метрики с одинаковыми идентификаторами на один сервер.
+//
+// По сигналу SIGHUP (а при включенном параметре config_watch - и при изменении файла конфигурации)
+// агент перечитывает конфигурацию и без перезапуска применяет интервалы опроса и отправки,
+// количество отправляющих горутин, ключи подписи и шифрования и адреса серверов.
+// Накопленные метрики и очередь на диске при этом сохраняются.
 package agent
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"math/rand/v2"
-	"net/http"
-	"net/url"
+	"maps"
+	"os"
 	"os/signal"
-	"runtime"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
-	"github.com/shirou/gopsutil/v4/cpu"
-	"github.com/shirou/gopsutil/v4/mem"
-
-	"github.com/galogen13/yandex-go-metrics/internal/compression"
+	"github.com/galogen13/yandex-go-metrics/internal/agent/collector"
+	"github.com/galogen13/yandex-go-metrics/internal/agent/spool"
 	"github.com/galogen13/yandex-go-metrics/internal/config"
-	"github.com/galogen13/yandex-go-metrics/internal/crypto"
 	"github.com/galogen13/yandex-go-metrics/internal/logger"
-	"github.com/galogen13/yandex-go-metrics/internal/retry"
 	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
-	"github.com/galogen13/yandex-go-metrics/internal/validation"
 	"go.uber.org/zap"
-
-	"github.com/go-resty/resty/v2"
 )
 
 const (
@@ -81,35 +151,125 @@ const (
 //
 // generate:reset
 type Agent struct {
-	// metrics - слайс метрик, собранных агентом
-	metrics        []*metrics.Metric
-	muxMetrics     *sync.Mutex
-	muxPollCounter *sync.Mutex
+	// gauges - последние значения метрик типа gauge, ключ - имя сборщика
+	gauges map[string][]*metrics.Metric
+	// counters - накопленные и еще не доставленные на сервер дельты метрик типа counter с метками агента,
+	// ключ - ключ метрики (см. metrics.Metric.Key): метрики с разными метками накапливаются раздельно
+	counters    map[string]*metrics.Metric
+	muxMetrics  *sync.Mutex
+	muxCounters *sync.Mutex
 	// config - структура с параметрами работы агента
-	config config.AgentConfig
-	// PollCount - метрика-счетчик, которая накапливает количество попыток сбора метрик из пакета runtime
-	PollCount int64
-	encryptor *crypto.Encryptor
+	config    config.AgentConfig
+	muxConfig *sync.RWMutex
+	// collectors - включенные сборщики метрик
+	collectors []collector.Collector
+	// sender - способ доставки пакетов метрик на сервер (http или grpc)
+	sender    sender
+	muxSender *sync.RWMutex
+	// muxDispatch удерживается, пока отправляются части очередного пакета
+	muxDispatch *sync.Mutex
+	// spool - очередь на диске для пакетов, не доставленных на сервер; nil, если отключена
+	spool *spool.Spool
+	// aggregator - агрегация значений метрик типа gauge за интервал отправки; nil, если не настроена
+	aggregator *gaugeAggregator
+	// changes - доставленные значения gauge для режима send_changed_only
+	changes *changeFilter
+	// telemetry - собственные метрики агента
+	telemetry *telemetry
+	// labels - метки, которые добавляются ко всем отправляемым метрикам
+	labels map[string]string
+	// agentID - идентификатор экземпляра агента, по которому сервер различает пакеты агентов
+	agentID string
+	// batchSeq - порядковый номер последнего сформированного пакета
+	batchSeq *atomic.Uint64
+	// polled и delivered - метрики собраны хотя бы раз и хотя бы один пакет доставлен на сервер (см. /readyz)
+	polled    *atomic.Bool
+	delivered *atomic.Bool
+}
+
+// addCounter накапливает дельту метрики типа counter без меток сборщика
+func (agent *Agent) addCounter(mID string, delta int64) {
+	agent.addCounterMetric(metrics.Metric{ID: mID, MType: metrics.Counter, Delta: &delta})
 }
 
-func (agent *Agent) increasePollСounter() {
-	agent.PollCount++
+// addCounterMetric накапливает дельту метрики типа counter. Метки агента добавляются сразу,
+// чтобы ключ накопленной дельты совпадал с ключом метрики в отправленном пакете (см. countersOf).
+func (agent *Agent) addCounterMetric(metric metrics.Metric) {
+	labeled := withLabels([]*metrics.Metric{&metric}, agent.labels)[0]
+	key := labeled.Key()
+
+	agent.muxCounters.Lock()
+	defer agent.muxCounters.Unlock()
+
+	if counter, ok := agent.counters[key]; ok {
+		*counter.Delta += *metric.Delta
+		return
+	}
+
+	delta := *metric.Delta
+	counter := metrics.NewMetrics(metric.ID, metrics.Counter)
+	counter.Delta = &delta
+	counter.Labels = maps.Clone(labeled.Labels)
+	agent.counters[key] = counter
 }
 
-func (agent *Agent) decreasePollCounter(decrementer int64) {
+// counterMetrics возвращает копии накопленных метрик типа counter, упорядоченные по ключу
+func (agent *Agent) counterMetrics() []*metrics.Metric {
+	agent.muxCounters.Lock()
+	defer agent.muxCounters.Unlock()
 
-	if agent.PollCount-decrementer < 0 {
-		agent.PollCount = 0
-	} else {
-		agent.PollCount -= decrementer
+	counters := make([]*metrics.Metric, 0, len(agent.counters))
+	for _, key := range slices.Sorted(maps.Keys(agent.counters)) {
+		counter := *agent.counters[key]
+		delta := *counter.Delta
+		counter.Delta = &delta
+		counter.Labels = maps.Clone(counter.Labels)
+		counters = append(counters, &counter)
 	}
+	return counters
+}
 
+// decreaseCounters вычитает доставленные дельты sent из накопленных, ключ sent - ключ метрики
+func (agent *Agent) decreaseCounters(sent map[string]int64) {
+	agent.muxCounters.Lock()
+	defer agent.muxCounters.Unlock()
+
+	for key, decrementer := range sent {
+		counter, ok := agent.counters[key]
+		if !ok {
+			continue
+		}
+		*counter.Delta = max(0, *counter.Delta-decrementer)
+	}
+}
+
+// countersOf возвращает дельты метрик типа counter из пакета по ключам метрик
+func countersOf(batch []*metrics.Metric) map[string]int64 {
+	counters := map[string]int64{}
+	for _, metric := range batch {
+		if metric.MType == metrics.Counter && metric.Delta != nil {
+			counters[metric.Key()] += *metric.Delta
+		}
+	}
+	return counters
+}
+
+// countersExcept возвращает дельты counters, кроме дельт с ключами из except
+func countersExcept(counters, except map[string]int64) map[string]int64 {
+	result := make(map[string]int64, len(counters))
+	for key, delta := range counters {
+		if _, ok := except[key]; !ok {
+			result[key] = delta
+		}
+	}
+	return result
 }
 
 // Start иницииализирует агента, запускает таймеры сбора метрик и их отправки на сервер.
-func Start(config config.AgentConfig) error {
+// Метрики собираются сборщиками из реестра registry, включенными в конфигурации.
+func Start(config config.AgentConfig, registry *collector.Registry) error {
 
-	agent, err := NewAgent(config)
+	agent, err := NewAgent(config, registry)
 
 	if err != nil {
 		return fmt.Errorf("cannot start agent: %w", err)
@@ -117,23 +277,56 @@ func Start(config config.AgentConfig) error {
 
 	logger.Log.Info("starting agent",
 		zap.String("Host", config.Host),
+		zap.Strings("Hosts", config.Hosts),
+		zap.String("UpstreamMode", config.UpstreamMode),
+		zap.String("Transport", config.Transport),
 		zap.Int("PollInterval", config.PollInterval),
 		zap.Any("ReportInterval", config.ReportInterval),
 		zap.Int("RateLimit", config.RateLimit),
+		zap.Strings("Collectors", agent.collectorNames()),
+		zap.String("SpoolDir", config.SpoolDir),
+		zap.String("StatusAddress", config.StatusAddress),
 	)
 
+	defer func() {
+		agent.muxSender.RLock()
+		defer agent.muxSender.RUnlock()
+		agent.sender.Close()
+	}()
+
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
 	defer stop()
 
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
 	var wg sync.WaitGroup
 
-	const numJobs = 1
-	jobs := make(chan any, numJobs)
-	for w := 1; w <= agent.config.RateLimit; w++ {
+	agent.startRunners(ctx, &wg)
+	agent.startCollectors(ctx, &wg)
+
+	if config.StatusAddress != "" {
+		if err := agent.startStatusServer(ctx, &wg, config.StatusAddress); err != nil {
+			return fmt.Errorf("cannot start agent: %w", err)
+		}
+	}
+
+	configChanged := make(chan struct{}, 1)
+	if config.ConfigWatch {
 		wg.Add(1)
-		go agent.startSendWorker(ctx, &wg, jobs)
+		go func() {
+			defer wg.Done()
+			if err := watchConfigFile(ctx, config.ConfigPath, configChanged); err != nil {
+				logger.Log.Error("cannot watch config file", zap.Error(err))
+			}
+		}()
 	}
 
+	jobs := newSendJobs()
+	workers := newSendWorkerPool(ctx, &wg, jobs, agent.startSendWorker)
+	workers.resize(config.RateLimit)
+
 	tickerPoll := time.NewTicker(time.Duration(config.PollInterval) * time.Second)
 	tickerReport := time.NewTicker(time.Duration(config.ReportInterval) * time.Second)
 
@@ -141,10 +334,17 @@ loop:
 	for {
 		select {
 		case <-tickerPoll.C:
-			wg.Add(1)
-			go agent.updateMetrics(&wg)
+			agent.addCounter(pollCounterName, 1)
 		case <-tickerReport.C:
-			jobs <- nil
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				agent.dispatchMetrics(ctx, jobs)
+			}()
+		case <-hup:
+			agent.reload(workers, tickerPoll, tickerReport)
+		case <-configChanged:
+			agent.reload(workers, tickerPoll, tickerReport)
 		case <-ctx.Done():
 			break loop
 		}
@@ -174,445 +374,322 @@ loop:
 
 }
 
-func (agent *Agent) startSendWorker(ctx context.Context, wg *sync.WaitGroup, jobs <-chan any) {
-	defer wg.Done()
-
-	for {
-		select {
-		case <-jobs:
-			agent.sendMetrics()
-		case <-ctx.Done():
-			logger.Log.Info("send worker stopped")
-			return
+// startRunners запускает фоновую работу сборщиков, реализующих collector.Runner
+func (agent *Agent) startRunners(ctx context.Context, wg *sync.WaitGroup) {
+	for _, c := range agent.collectors {
+		runner, ok := c.(collector.Runner)
+		if !ok {
+			continue
 		}
-	}
-}
-
-// NewAgent инициализирует структуру агента с параметрами настройки
-func NewAgent(agentConfig config.AgentConfig) (*Agent, error) {
-	encryptor, err := crypto.NewEncryptor(agentConfig.CryptoKeyPath)
-	if err != nil {
-		return nil, fmt.Errorf("cannot initialize encryptor: %w", err)
-	}
-	return &Agent{
-			config:         agentConfig,
-			metrics:        []*metrics.Metric{},
-			muxMetrics:     &sync.Mutex{},
-			muxPollCounter: &sync.Mutex{},
-			encryptor:      encryptor},
-		nil
-}
-
-func (agent *Agent) updateMetrics(wg *sync.WaitGroup) {
-
-	defer wg.Done()
-
-	channels := fanOut()
-	addResultCh := fanIn(channels...)
-
-	metrics := multiplyMetrics(addResultCh)
-
-	agent.muxPollCounter.Lock()
-	agent.increasePollСounter()
-	metric, err := newCounterMetric(pollCounterName, agent.PollCount)
-	agent.muxPollCounter.Unlock()
-	if err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		metrics = append(metrics, metric)
-	}
-
-	agent.muxMetrics.Lock()
-	defer agent.muxMetrics.Unlock()
-	agent.metrics = metrics
-
-}
-
-type metricsResult struct {
-	metrics []*metrics.Metric
-}
-
-func fanOut() []chan metricsResult {
-
-	workers := []func() []*metrics.Metric{
-		getRuntimeMetrics,
-		getPSMetrics,
-	}
-	channels := make([]chan metricsResult, len(workers))
-
-	for i, worker := range workers {
-		addResultCh := startWorker(worker)
-		channels[i] = addResultCh
-	}
-
-	return channels
-}
-
-func startWorker(getMetrics func() []*metrics.Metric) chan metricsResult {
-	addRes := make(chan metricsResult)
-
-	go func() {
-		defer close(addRes)
-
-		result := metricsResult{metrics: getMetrics()}
-		addRes <- result
-
-	}()
-	return addRes
-}
-
-func fanIn(resultChs ...chan metricsResult) chan metricsResult {
-
-	finalCh := make(chan metricsResult)
-
-	var wg sync.WaitGroup
-
-	for _, ch := range resultChs {
-
-		chClosure := ch
 
 		wg.Add(1)
-
 		go func() {
 			defer wg.Done()
-
-			for data := range chClosure {
-				finalCh <- data
+			if err := runner.Run(ctx); err != nil {
+				logger.Log.Error("collector stopped with error", zap.String("collector", c.Name()), zap.Error(err))
 			}
 		}()
 	}
-
-	go func() {
-		wg.Wait()
-		close(finalCh)
-	}()
-
-	return finalCh
 }
 
-func multiplyMetrics(inputCh chan metricsResult) []*metrics.Metric {
-	result := []*metrics.Metric{}
+func (agent *Agent) startSendWorker(ctx context.Context, wg *sync.WaitGroup, jobs <-chan sendJob) {
+	defer wg.Done()
 
-	for data := range inputCh {
-		result = append(result, data.metrics...)
+	for {
+		select {
+		case job := <-jobs:
+			agent.sendBatch(job.batch, job.identity)
+			job.done()
+		case <-ctx.Done():
+			logger.Log.Info("send worker stopped")
+			return
+		}
 	}
-	return result
 }
 
-func getRuntimeMetrics() []*metrics.Metric {
-
-	var rtm runtime.MemStats
-	runtime.ReadMemStats(&rtm)
-
-	result := []*metrics.Metric{}
-
-	if metric, err := newGaugeMetric("Alloc", float64(rtm.Alloc)); err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		result = append(result, metric)
-	}
-
-	if metric, err := newGaugeMetric("BuckHashSys", float64(rtm.BuckHashSys)); err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		result = append(result, metric)
-	}
-
-	if metric, err := newGaugeMetric("Frees", float64(rtm.Frees)); err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		result = append(result, metric)
-	}
-
-	if metric, err := newGaugeMetric("GCCPUFraction", float64(rtm.GCCPUFraction)); err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		result = append(result, metric)
-	}
-
-	if metric, err := newGaugeMetric("GCSys", float64(rtm.GCSys)); err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		result = append(result, metric)
-	}
-
-	if metric, err := newGaugeMetric("HeapAlloc", float64(rtm.HeapAlloc)); err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		result = append(result, metric)
-	}
-
-	if metric, err := newGaugeMetric("HeapIdle", float64(rtm.HeapIdle)); err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		result = append(result, metric)
-	}
-
-	if metric, err := newGaugeMetric("HeapInuse", float64(rtm.HeapInuse)); err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		result = append(result, metric)
+// NewAgent инициализирует структуру агента с параметрами настройки и сборщиками из реестра
+func NewAgent(agentConfig config.AgentConfig, registry *collector.Registry) (*Agent, error) {
+	agent := &Agent{
+		config:      agentConfig,
+		gauges:      map[string][]*metrics.Metric{},
+		counters:    map[string]*metrics.Metric{},
+		muxMetrics:  &sync.Mutex{},
+		muxCounters: &sync.Mutex{},
+		muxConfig:   &sync.RWMutex{},
+		muxSender:   &sync.RWMutex{},
+		muxDispatch: &sync.Mutex{},
+		agentID:     newAgentID(),
+		batchSeq:    &atomic.Uint64{},
+		polled:      &atomic.Bool{},
+		delivered:   &atomic.Bool{},
+		changes:     newChangeFilter(),
+	}
+	agent.telemetry = newTelemetry(agent.addCounter)
+
+	labels, err := agentLabels(agentConfig)
+	if err != nil {
+		return nil, fmt.Errorf("cannot initialize labels: %w", err)
 	}
+	agent.labels = labels
 
-	if metric, err := newGaugeMetric("HeapObjects", float64(rtm.HeapObjects)); err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		result = append(result, metric)
+	metricsSender, err := newSender(agentConfig, agent.telemetry)
+	if err != nil {
+		return nil, fmt.Errorf("cannot initialize sender: %w", err)
 	}
+	agent.sender = metricsSender
 
-	if metric, err := newGaugeMetric("HeapReleased", float64(rtm.HeapReleased)); err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		result = append(result, metric)
+	agent.collectors, err = registry.Build(agentConfig)
+	if err != nil {
+		return nil, fmt.Errorf("cannot initialize collectors: %w", err)
 	}
 
-	if metric, err := newGaugeMetric("HeapSys", float64(rtm.HeapSys)); err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		result = append(result, metric)
+	agent.aggregator, err = newGaugeAggregator(agentConfig.GaugeAggregation)
+	if err != nil {
+		return nil, fmt.Errorf("cannot initialize gauge aggregation: %w", err)
 	}
 
-	if metric, err := newGaugeMetric("LastGC", float64(rtm.LastGC)); err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		result = append(result, metric)
+	if agentConfig.SpoolDir != "" {
+		agent.spool, err = spool.New(
+			agentConfig.SpoolDir,
+			agentConfig.SpoolSegmentSize,
+			agentConfig.SpoolMaxSize,
+			time.Duration(agentConfig.SpoolMaxAge)*time.Second)
+		if err != nil {
+			return nil, fmt.Errorf("cannot initialize spool: %w", err)
+		}
 	}
 
-	if metric, err := newGaugeMetric("Lookups", float64(rtm.Lookups)); err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		result = append(result, metric)
-	}
+	return agent, nil
+}
 
-	if metric, err := newGaugeMetric("MCacheInuse", float64(rtm.MCacheInuse)); err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		result = append(result, metric)
+func (agent *Agent) collectorNames() []string {
+	names := make([]string, 0, len(agent.collectors))
+	for _, c := range agent.collectors {
+		names = append(names, c.Name())
 	}
+	return names
+}
 
-	if metric, err := newGaugeMetric("MCacheSys", float64(rtm.MCacheSys)); err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		result = append(result, metric)
+// storeResult запоминает метрики типа gauge сборщика и накапливает дельты метрик типа counter.
+func (agent *Agent) storeResult(result metricsResult) {
+	if result.err != nil {
+		logger.Log.Error("error collecting metrics", zap.String("collector", result.name), zap.Error(result.err))
 	}
 
-	if metric, err := newGaugeMetric("MSpanInuse", float64(rtm.MSpanInuse)); err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		result = append(result, metric)
+	gauges := make([]*metrics.Metric, 0, len(result.metrics))
+	for _, metric := range result.metrics {
+		if metric.MType == metrics.Counter {
+			agent.addCounterMetric(*metric)
+			continue
+		}
+		gauges = append(gauges, metric)
 	}
 
-	if metric, err := newGaugeMetric("MSpanSys", float64(rtm.MSpanSys)); err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		result = append(result, metric)
+	if agent.aggregator != nil {
+		agent.aggregator.observe(gauges)
 	}
 
-	if metric, err := newGaugeMetric("Mallocs", float64(rtm.Mallocs)); err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		result = append(result, metric)
-	}
+	agent.muxMetrics.Lock()
+	defer agent.muxMetrics.Unlock()
+	agent.gauges[result.name] = gauges
+}
 
-	if metric, err := newGaugeMetric("NextGC", float64(rtm.NextGC)); err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		result = append(result, metric)
-	}
+// flushCollectors забирает метрики, которые сборщики агрегируют за интервал отправки (collector.Flusher).
+// Дельты counter добавляются к накопленным, метрики gauge возвращаются для пакета.
+func (agent *Agent) flushCollectors() []*metrics.Metric {
+	gauges := []*metrics.Metric{}
+	for _, c := range agent.collectors {
+		flusher, ok := c.(collector.Flusher)
+		if !ok {
+			continue
+		}
 
-	if metric, err := newGaugeMetric("NumForcedGC", float64(rtm.NumForcedGC)); err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		result = append(result, metric)
+		flushed, err := flusher.Flush()
+		if err != nil {
+			logger.Log.Error("error flushing collector metrics", zap.String("collector", c.Name()), zap.Error(err))
+		}
+		for _, metric := range flushed {
+			if metric.MType == metrics.Counter {
+				agent.addCounterMetric(*metric)
+				continue
+			}
+			gauges = append(gauges, metric)
+		}
 	}
+	return gauges
+}
 
-	if metric, err := newGaugeMetric("NumGC", float64(rtm.NumGC)); err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		result = append(result, metric)
+// snapshot формирует пакет метрик для отправки.
+func (agent *Agent) snapshot() ([]*metrics.Metric, error) {
+	agent.muxMetrics.Lock()
+	batch := []*metrics.Metric{}
+	for _, c := range agent.collectors {
+		batch = append(batch, agent.gauges[c.Name()]...)
 	}
+	agent.muxMetrics.Unlock()
 
-	if metric, err := newGaugeMetric("OtherSys", float64(rtm.OtherSys)); err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		result = append(result, metric)
+	if agent.aggregator != nil {
+		aggregated, err := agent.aggregator.flush(batch)
+		if err != nil {
+			return nil, err
+		}
+		batch = aggregated
 	}
+	batch = append(batch, agent.flushCollectors()...)
 
-	if metric, err := newGaugeMetric("PauseTotalNs", float64(rtm.PauseTotalNs)); err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		result = append(result, metric)
+	if agent.spool != nil {
+		if segments, size, err := agent.spool.Stats(); err == nil {
+			agent.telemetry.set(telemetrySpoolSegments, float64(segments))
+			agent.telemetry.set(telemetrySpoolBytes, float64(size))
+		} else {
+			logger.Log.Error("cannot get spool stats", zap.Error(err))
+		}
 	}
 
-	if metric, err := newGaugeMetric("StackInuse", float64(rtm.StackInuse)); err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		result = append(result, metric)
+	batch = append(batch, agent.telemetry.Metrics()...)
+	agent.muxSender.RLock()
+	if reporter, ok := agent.sender.(metricsReporter); ok {
+		batch = append(batch, reporter.Metrics()...)
 	}
+	agent.muxSender.RUnlock()
 
-	if metric, err := newGaugeMetric("StackSys", float64(rtm.StackSys)); err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		result = append(result, metric)
+	batch = withLabels(batch, agent.labels)
+	// метки агента у накопленных счетчиков уже есть
+	batch = append(batch, agent.counterMetrics()...)
+	if agentConfig := agent.currentConfig(); agentConfig.SendChangedOnly {
+		batch = agent.changes.filter(batch, agentConfig.HeartbeatEvery)
 	}
 
-	if metric, err := newGaugeMetric("Sys", float64(rtm.Sys)); err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		result = append(result, metric)
-	}
+	return batch, nil
+}
 
-	if metric, err := newGaugeMetric("TotalAlloc", float64(rtm.TotalAlloc)); err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		result = append(result, metric)
-	}
+type metricsResult struct {
+	name    string
+	metrics []*metrics.Metric
+	err     error
+}
 
-	if metric, err := newGaugeMetric("RandomValue", rand.Float64()); err != nil {
-		logger.Log.Error("error updating agent metric values", zap.Error(err))
-	} else {
-		result = append(result, metric)
+// sendMetrics формирует пакет метрик и отправляет его части по очереди.
+// Используется для последней отправки при остановке агента.
+func (agent *Agent) sendMetrics() {
+	for _, chunk := range agent.splitSnapshot() {
+		agent.sendBatch(chunk, agent.nextBatchIdentity())
 	}
-
-	return result
-
 }
 
-func getPSMetrics() []*metrics.Metric {
-
-	result := []*metrics.Metric{}
+// sendBatch отправляет часть пакета и вычитает доставленные дельты счетчиков из накопленных.
+// Если сервер недоступен, недоставленное кладется в очередь на диске (если она включена),
+// иначе дельты остаются у агента и отправляются со следующим пакетом.
+// Недоставленная часть кладется в очередь с идентификатором пакета identity: в режиме shard
+// идентификатор части зависит от сервера, поэтому уже примененная часть не применится повторно.
+func (agent *Agent) sendBatch(batch []*metrics.Metric, identity batchIdentity) {
+	sentCounters := countersOf(batch)
 
-	if vmStat, err := mem.VirtualMemory(); err == nil {
-
-		if metric, err := newGaugeMetric("TotalMemory", float64(vmStat.Total)); err != nil {
-			logger.Log.Error("error updating agent metric values", zap.Error(err))
-		} else {
-			result = append(result, metric)
-		}
-
-		if metric, err := newGaugeMetric("FreeMemory", float64(vmStat.Free)); err != nil {
-			logger.Log.Error("error updating agent metric values", zap.Error(err))
-		} else {
-			result = append(result, metric)
+	if err := agent.send(batch, identity); err != nil {
+		logger.Log.Error("error sending metrics", zap.Error(err))
+		agent.changes.notDelivered()
+
+		var partialErr *PartialSendError
+		if errors.As(err, &partialErr) {
+			// доставленная часть пакета учитывается как отправленная
+			batch = partialErr.Failed
+			failedCounters := countersOf(batch)
+			agent.decreaseCounters(countersExcept(sentCounters, failedCounters))
+			sentCounters = failedCounters
 		}
 
-	} else {
-		logger.Log.Error("error getting virtual memory metrics", zap.Error(err))
-	}
-
-	if cpuPercent, err := cpu.Percent(0, true); err == nil {
-		for cpuNum, usage := range cpuPercent {
-			if metric, err := newGaugeMetric(fmt.Sprintf("CPUutilization%d", cpuNum), float64(usage)); err != nil {
-				logger.Log.Error("error updating agent metric values", zap.Error(err))
-			} else {
-				result = append(result, metric)
-			}
+		if agent.spool != nil && errors.Is(err, ErrServerUnavailable) {
+			agent.spoolBatch(batch, sentCounters, identity)
 		}
-	} else {
-		logger.Log.Error("error getting cpu metrics", zap.Error(err))
+		return
 	}
 
-	return result
+	agent.decreaseCounters(sentCounters)
+	agent.changes.delivered(batch)
 }
 
-func newGaugeMetric(mID string, value float64) (*metrics.Metric, error) {
-	metric := metrics.NewMetrics(mID, metrics.Gauge)
-	if err := metric.UpdateValue(value); err != nil {
-		return nil, fmt.Errorf("error adding new gauge metric ID: %s, mType: %s, value: %v, err: %w", metric.ID, metric.MType, value, err)
-	}
-	return metric, nil
-}
+// send отправляет пакет с идентификатором identity на сервер и учитывает результат в собственных метриках агента
+func (agent *Agent) send(batch []*metrics.Metric, identity batchIdentity) error {
+	agent.muxSender.RLock()
+	defer agent.muxSender.RUnlock()
 
-func newCounterMetric(mID string, value int64) (*metrics.Metric, error) {
-	metric := metrics.NewMetrics(mID, metrics.Counter)
-	if err := metric.UpdateValue(value); err != nil {
-		return nil, fmt.Errorf("error adding new counter metric ID: %s, mType: %s, value: %v, err: %w", metric.ID, metric.MType, value, err)
+	start := time.Now()
+	err := agent.sender.Send(withBatchIdentity(context.Background(), identity), batch)
+	agent.telemetry.sendAttempt(err, time.Since(start))
+	if err == nil {
+		agent.delivered.Store(true)
 	}
-	return metric, nil
+	return err
 }
 
-func (agent *Agent) sendMetrics() {
-
-	agent.muxMetrics.Lock()
-	if len(agent.metrics) == 0 {
-		logger.Log.Info("nothing to send")
+// spoolBatch кладет пакет в очередь на диске. Дельты счетчиков пакета с этого момента
+// хранятся в очереди, поэтому накопленные агентом счетчики уменьшаются так же, как после отправки.
+func (agent *Agent) spoolBatch(batch []*metrics.Metric, sentCounters map[string]int64, identity batchIdentity) {
+	if err := agent.spool.Enqueue(newSpoolBatch(batch, identity)); err != nil {
+		logger.Log.Error("cannot put metrics batch to spool", zap.Error(err))
 		return
 	}
-	currentPollCounterMetricValue := agent.metrics[len(agent.metrics)-1].GetValue() // последняя метрика - PollCounter
-	currentPollCounter, err := metrics.CounterValue(currentPollCounterMetricValue)
-	if err != nil {
-		logger.Log.Error("cannot read currentPollCounter", zap.Error(err))
-		return
-	}
-
-	logger.Log.Debug("prepairing to send metrics batch",
-		zap.Any("metrics", agent.metrics),
-	)
+	agent.decreaseCounters(sentCounters)
+	logger.Log.Info("metrics batch put to spool", zap.Int("metrics", len(batch)))
+}
 
-	bodyBytes, err := json.Marshal(agent.metrics)
-	agent.muxMetrics.Unlock()
-	if err != nil {
-		logger.Log.Error("error while marshalling metrics", zap.Error(err))
-		return
+// requeueCounters дописывает в очередь пакет из метрик типа counter с идентификатором identity
+func (agent *Agent) requeueCounters(batchMetrics []*metrics.Metric, identity batchIdentity) error {
+	counters := make([]*metrics.Metric, 0, len(batchMetrics))
+	for _, metric := range batchMetrics {
+		if metric.MType == metrics.Counter {
+			counters = append(counters, metric)
+		}
 	}
-
-	compressed, err := compression.GzipCompress(bodyBytes)
-	if err != nil {
-		logger.Log.Error("error while gzip compress metrics", zap.Error(err))
-		return
+	if len(counters) == 0 {
+		return nil
 	}
+	return agent.spool.Enqueue(newSpoolBatch(counters, identity))
+}
 
-	body, err := agent.encryptor.Encrypt(compressed.Bytes())
-	if err != nil {
-		logger.Log.Error("failed to encrypt data", zap.Error(err))
-		return
-	}
+// newSpoolBatch создает пакет очереди на диске с идентификатором identity
+func newSpoolBatch(batchMetrics []*metrics.Metric, identity batchIdentity) spool.Batch {
+	batch := spool.NewBatch(batchMetrics)
+	batch.AgentID = identity.agentID
+	batch.ID = identity.id
+	batch.Seq = identity.seq
+	return batch
+}
 
-	client := resty.New()
-	client.SetRedirectPolicy(resty.RedirectPolicyFunc(
-		func(req *http.Request, _ []*http.Request) error {
-			req.Method = http.MethodPost
+// replaySpool отправляет пакеты из очереди на диске по порядку.
+// Пакеты, отклоненные сервером, удаляются из очереди, чтобы не блокировать остальные.
+func (agent *Agent) replaySpool() {
+	err := agent.spool.Replay(func(batch spool.Batch) error {
+		identity := batchIdentity{agentID: batch.AgentID, id: batch.ID, seq: batch.Seq}
+		if identity.id == "" {
+			// пакет из объединенных при вытеснении счетчиков
+			identity = agent.nextBatchIdentity()
+		}
+		err := agent.send(batch.Metrics, identity)
+		if err == nil {
+			// сервер получил значения gauge из очереди: при send_changed_only они
+			// больше не считаются недоставленными
+			agent.changes.delivered(batch.Metrics)
 			return nil
-		}))
-
-	req := client.R().
-		SetHeader("Content-Type", "application/json").
-		SetHeader("Content-Encoding", "gzip").
-		SetBody(body)
-
-	if agent.config.Key != "" {
-		hash := validation.CalculateHMAC(body, agent.config.Key)
-		req.SetHeader("HashSHA256", hash)
-	}
-
-	baseURL := &url.URL{
-		Scheme: "http",
-		Host:   agent.config.Host,
-		Path:   "updates",
-	}
-	fullURL := baseURL.String()
+		}
 
-	resp, err := retry.DoWithResult(
-		context.Background(),
-		func() (*resty.Response, error) {
-			return req.Post(fullURL)
-		},
-		NewAgentErrorClassifier())
+		var partialErr *PartialSendError
+		if errors.As(err, &partialErr) && errors.Is(err, ErrServerUnavailable) {
+			// доставленную часть нельзя отправлять повторно: недоставленные дельты счетчиков
+			// возвращаются в конец очереди, а устаревшие значения gauge отбрасываются
+			if qerr := agent.requeueCounters(partialErr.Failed, identity); qerr != nil {
+				return errors.Join(err, qerr)
+			}
+			return fmt.Errorf("%w: %w", spool.ErrStopReplay, err)
+		}
 
+		if !errors.Is(err, ErrServerUnavailable) {
+			logger.Log.Error("spooled metrics batch rejected, dropped", zap.Time("created at", batch.CreatedAt), zap.Error(err))
+			return nil
+		}
+		return err
+	})
 	if err != nil {
-		logger.Log.Error("error sending metrics", zap.Error(err))
-		return
+		logger.Log.Error("error sending spooled metrics", zap.Error(err))
 	}
-
-	logger.Log.Info("data sent", zap.String("url", fullURL), zap.Int("respCode", resp.StatusCode()))
-
-	if resp.StatusCode() != http.StatusOK {
-		logger.Log.Error("unexpected status code", zap.Int("status code", resp.StatusCode()))
-		return
-	}
-
-	agent.muxPollCounter.Lock()
-	defer agent.muxPollCounter.Unlock()
-	agent.decreasePollCounter(currentPollCounter)
-
 }
@@ -2,12 +2,35 @@ package agent
 
 import (
 	"errors"
+	"fmt"
 	"syscall"
 
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
 	"github.com/galogen13/yandex-go-metrics/internal/classification"
 	"github.com/galogen13/yandex-go-metrics/internal/retry"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
 )
 
+// ErrServerUnavailable - сервер недоступен или временно не может принять метрики
+var ErrServerUnavailable = errors.New("server unavailable")
+
+// PartialSendError - пакет доставлен частично, метрики Failed не доставлены.
+// Причина недоставки доступна через errors.Is/errors.As (например, ErrServerUnavailable).
+type PartialSendError struct {
+	Failed []*metrics.Metric
+	Err    error
+}
+
+func (e *PartialSendError) Error() string {
+	return fmt.Sprintf("%d metrics not delivered: %v", len(e.Failed), e.Err)
+}
+
+func (e *PartialSendError) Unwrap() error {
+	return e.Err
+}
+
 type AgentErrorClassifier struct{}
 
 func NewAgentErrorClassifier() *AgentErrorClassifier {
@@ -24,6 +47,10 @@ func (c *AgentErrorClassifier) Classify(err error) retry.ErrorClassification {
 		return classifySyscallError(reqErr)
 	}
 
+	if st, ok := status.FromError(err); ok && st.Code() == codes.Unavailable {
+		return retry.Retriable
+	}
+
 	return retry.NonRetriable
 }
 
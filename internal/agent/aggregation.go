@@ -0,0 +1,193 @@
+package agent
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/galogen13/yandex-go-metrics/internal/agent/collector"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+// Способы агрегации значений метрики типа gauge за интервал отправки
+const (
+	AggregateLast = "last" // последнее значение под исходным идентификатором
+	AggregateMin  = "min"
+	AggregateMax  = "max"
+	AggregateMean = "mean"
+	AggregateSum  = "sum"
+	AggregateP95  = "p95"
+)
+
+// aggregateSuffixes - суффиксы идентификаторов агрегированных метрик
+var aggregateSuffixes = map[string]string{
+	AggregateLast: "",
+	AggregateMin:  "Min",
+	AggregateMax:  "Max",
+	AggregateMean: "Mean",
+	AggregateSum:  "Sum",
+	AggregateP95:  "P95",
+}
+
+type aggregationRule struct {
+	pattern string
+	modes   []string
+}
+
+// gaugeAggregator накапливает значения метрик типа gauge, собранные между отправками,
+// и заменяет последнее значение агрегатами за интервал: метрика Foo с режимами
+// max и p95 отправляется как FooMax и FooP95, режим last сохраняет метрику Foo.
+type gaugeAggregator struct {
+	exact    map[string][]string
+	patterns []aggregationRule // в порядке сортировки шаблонов
+
+	mux     sync.Mutex
+	samples map[string][]float64 // ключ - metrics.Metric.Key(): метрики с разными метками агрегируются раздельно
+}
+
+// newGaugeAggregator создает агрегатор по правилам из конфигурации.
+// Если правила не заданы, возвращает nil: отправляются последние значения.
+func newGaugeAggregator(rules map[string][]string) (*gaugeAggregator, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	aggregator := &gaugeAggregator{
+		exact:   map[string][]string{},
+		samples: map[string][]float64{},
+	}
+
+	for _, pattern := range slices.Sorted(maps.Keys(rules)) {
+		modes := make([]string, 0, len(rules[pattern]))
+		for _, mode := range rules[pattern] {
+			mode = strings.ToLower(mode)
+			if _, ok := aggregateSuffixes[mode]; !ok {
+				return nil, fmt.Errorf("unknown gauge aggregation mode %q for %s", mode, pattern)
+			}
+			if !slices.Contains(modes, mode) {
+				modes = append(modes, mode)
+			}
+		}
+		if len(modes) == 0 {
+			return nil, fmt.Errorf("gauge aggregation modes for %s are not filled", pattern)
+		}
+
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid gauge aggregation pattern %q: %w", pattern, err)
+		}
+		if strings.ContainsAny(pattern, `*?[\`) {
+			aggregator.patterns = append(aggregator.patterns, aggregationRule{pattern: pattern, modes: modes})
+		} else {
+			aggregator.exact[pattern] = modes
+		}
+	}
+
+	return aggregator, nil
+}
+
+// modes возвращает способы агрегации метрики: точное совпадение идентификатора
+// имеет приоритет над шаблонами. nil означает, что метрика не агрегируется.
+func (a *gaugeAggregator) modes(mID string) []string {
+	if modes, ok := a.exact[mID]; ok {
+		return modes
+	}
+	for _, rule := range a.patterns {
+		if ok, _ := filepath.Match(rule.pattern, mID); ok {
+			return rule.modes
+		}
+	}
+	return nil
+}
+
+// observe запоминает значения собранных метрик типа gauge, для которых задана агрегация
+func (a *gaugeAggregator) observe(gauges []*metrics.Metric) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	for _, metric := range gauges {
+		if metric.MType != metrics.Gauge || metric.Value == nil || a.modes(metric.ID) == nil {
+			continue
+		}
+		a.samples[metric.Key()] = append(a.samples[metric.Key()], *metric.Value)
+	}
+}
+
+// flush заменяет последние значения метрик агрегатами за интервал и начинает новый интервал.
+// Если с прошлой отправки метрика не собиралась, агрегаты считаются по последнему значению.
+func (a *gaugeAggregator) flush(gauges []*metrics.Metric) ([]*metrics.Metric, error) {
+	a.mux.Lock()
+	samples := a.samples
+	a.samples = map[string][]float64{}
+	a.mux.Unlock()
+
+	result := make([]*metrics.Metric, 0, len(gauges))
+	for _, metric := range gauges {
+		modes := a.modes(metric.ID)
+		if metric.MType != metrics.Gauge || metric.Value == nil || modes == nil {
+			result = append(result, metric)
+			continue
+		}
+
+		values := samples[metric.Key()]
+		if len(values) == 0 {
+			values = []float64{*metric.Value}
+		}
+
+		for _, mode := range modes {
+			value := *metric.Value
+			if mode != AggregateLast {
+				value = aggregate(mode, values)
+			}
+			aggregated, err := collector.NewGaugeMetric(metric.ID+aggregateSuffixes[mode], value)
+			if err != nil {
+				return nil, err
+			}
+			aggregated.Labels = metric.Labels
+			result = append(result, aggregated)
+		}
+	}
+
+	return result, nil
+}
+
+func aggregate(mode string, values []float64) float64 {
+	switch mode {
+	case AggregateMin:
+		return slices.Min(values)
+	case AggregateMax:
+		return slices.Max(values)
+	case AggregateSum, AggregateMean:
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		if mode == AggregateMean {
+			return sum / float64(len(values))
+		}
+		return sum
+	case AggregateP95:
+		return percentile(values, 0.95)
+	}
+	return values[len(values)-1]
+}
+
+// percentile вычисляет перцентиль методом ближайшего ранга
+func percentile(values []float64, p float64) float64 {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(0, min(rank, len(sorted)-1))]
+}
+
+// Reset начинает новый интервал агрегации
+func (a *gaugeAggregator) Reset() {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	clear(a.samples)
+}
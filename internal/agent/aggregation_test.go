@@ -0,0 +1,114 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+func testGauge(t *testing.T, mID string, value float64) *metrics.Metric {
+	t.Helper()
+
+	metric := metrics.NewMetrics(mID, metrics.Gauge)
+	require.NoError(t, metric.UpdateValue(value))
+	return metric
+}
+
+func TestNewGaugeAggregator(t *testing.T) {
+	aggregator, err := newGaugeAggregator(nil)
+	require.NoError(t, err)
+	assert.Nil(t, aggregator)
+
+	_, err = newGaugeAggregator(map[string][]string{"Alloc": {"median"}})
+	assert.Error(t, err)
+
+	_, err = newGaugeAggregator(map[string][]string{"Alloc": {}})
+	assert.Error(t, err)
+
+	_, err = newGaugeAggregator(map[string][]string{"CPU[": {"max"}})
+	assert.Error(t, err)
+}
+
+func TestGaugeAggregator_Flush(t *testing.T) {
+	aggregator, err := newGaugeAggregator(map[string][]string{
+		"CPUutilization*": {"max", "mean", "p95"},
+		"CPUutilization1": {"last", "min", "sum"},
+	})
+	require.NoError(t, err)
+
+	for _, value := range []float64{10, 90, 20, 30, 50} {
+		aggregator.observe([]*metrics.Metric{
+			testGauge(t, "CPUutilization0", value),
+			testGauge(t, "CPUutilization1", value),
+			testGauge(t, "Alloc", value),
+		})
+	}
+
+	flushed, err := aggregator.flush([]*metrics.Metric{
+		testGauge(t, "CPUutilization0", 50),
+		testGauge(t, "CPUutilization1", 50),
+		testGauge(t, "Alloc", 50),
+	})
+	require.NoError(t, err)
+
+	got := map[string]float64{}
+	for _, metric := range flushed {
+		got[metric.ID] = *metric.Value
+	}
+	assert.Equal(t, map[string]float64{
+		"CPUutilization0Max":  90,
+		"CPUutilization0Mean": 40,
+		"CPUutilization0P95":  90,
+		"CPUutilization1":     50,
+		"CPUutilization1Min":  10,
+		"CPUutilization1Sum":  200,
+		"Alloc":               50,
+	}, got)
+
+	// новый интервал без опросов - агрегаты по последнему значению
+	flushed, err = aggregator.flush([]*metrics.Metric{testGauge(t, "CPUutilization0", 7)})
+	require.NoError(t, err)
+	require.Len(t, flushed, 3)
+	for _, metric := range flushed {
+		assert.Equal(t, 7.0, *metric.Value)
+	}
+}
+
+func TestGaugeAggregator_Labels(t *testing.T) {
+	aggregator, err := newGaugeAggregator(map[string][]string{"QueueSize": {"max"}})
+	require.NoError(t, err)
+
+	labeled := func(queue string, value float64) *metrics.Metric {
+		metric := testGauge(t, "QueueSize", value)
+		metric.Labels = map[string]string{"queue": queue}
+		return metric
+	}
+
+	aggregator.observe([]*metrics.Metric{labeled("mail", 10), labeled("sms", 1)})
+	aggregator.observe([]*metrics.Metric{labeled("mail", 2), labeled("sms", 3)})
+
+	flushed, err := aggregator.flush([]*metrics.Metric{labeled("mail", 2), labeled("sms", 3)})
+	require.NoError(t, err)
+
+	got := map[string]float64{}
+	for _, metric := range flushed {
+		got[metric.Key()] = *metric.Value
+	}
+	assert.Equal(t, map[string]float64{
+		`QueueSizeMax{queue="mail"}`: 10,
+		`QueueSizeMax{queue="sms"}`:  3,
+	}, got)
+}
+
+func TestPercentile(t *testing.T) {
+	values := make([]float64, 0, 100)
+	for i := 100; i >= 1; i-- {
+		values = append(values, float64(i))
+	}
+	assert.Equal(t, 95.0, percentile(values, 0.95))
+	assert.Equal(t, 3.0, percentile([]float64{3}, 0.95))
+	assert.Equal(t, 100.0, values[0], "исходный слайс не изменяется")
+}
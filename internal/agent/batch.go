@@ -0,0 +1,138 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/galogen13/yandex-go-metrics/internal/logger"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+// sendJob - часть пакета метрик, которую отправляет одна из горутин отправки
+type sendJob struct {
+	batch    []*metrics.Metric
+	identity batchIdentity
+	// done вызывается после обработки части
+	done func()
+}
+
+// newSendJobs создает канал частей пакета для горутин отправки. Канал не буферизован:
+// часть считается переданной, только когда ее приняла горутина отправки, а принятая часть
+// всегда обрабатывается до конца. Часть из буфера могла бы остаться необработанной
+// при остановке агента, и dispatchMetrics ждал бы ее вечно.
+func newSendJobs() chan sendJob {
+	return make(chan sendJob)
+}
+
+// splitBatch делит пакет на части не более чем из maxMetrics метрик, размер которых
+// в формате JSON не превышает maxBytes байт. Нулевое ограничение не применяется.
+// Метрика, которая одна превышает maxBytes, отправляется отдельной частью.
+func splitBatch(batch []*metrics.Metric, maxMetrics, maxBytes int) ([][]*metrics.Metric, error) {
+	if maxMetrics <= 0 && maxBytes <= 0 {
+		return [][]*metrics.Metric{batch}, nil
+	}
+
+	chunks := [][]*metrics.Metric{}
+	chunk := []*metrics.Metric{}
+	// размер пустого массива "[]"
+	const emptySize = 2
+	size := emptySize
+
+	for _, metric := range batch {
+		encoded, err := json.Marshal(metric)
+		if err != nil {
+			return nil, fmt.Errorf("error while marshalling metric %s: %w", metric.ID, err)
+		}
+		metricSize := len(encoded)
+		if len(chunk) > 0 {
+			// разделитель ","
+			metricSize++
+		}
+
+		full := maxMetrics > 0 && len(chunk) >= maxMetrics
+		tooLarge := maxBytes > 0 && size+metricSize > maxBytes
+		if len(chunk) > 0 && (full || tooLarge) {
+			chunks = append(chunks, chunk)
+			chunk = []*metrics.Metric{}
+			size = emptySize
+			metricSize = len(encoded)
+		}
+
+		chunk = append(chunk, metric)
+		size += metricSize
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks, nil
+}
+
+// splitSnapshot формирует пакет метрик для отправки и делит его на части по ограничениям
+// batch_max_metrics и batch_max_size. Если в очереди на диске есть неотправленные пакеты,
+// части кладутся в очередь после них, очередь отправляется по порядку и возвращается nil.
+func (agent *Agent) splitSnapshot() [][]*metrics.Metric {
+	batch, err := agent.snapshot()
+	if err != nil {
+		logger.Log.Error("cannot prepare metrics batch", zap.Error(err))
+		return nil
+	}
+	if len(batch) == 0 {
+		logger.Log.Info("nothing to send")
+		return nil
+	}
+
+	agentConfig := agent.currentConfig()
+	chunks, err := splitBatch(batch, agentConfig.BatchMaxMetrics, agentConfig.BatchMaxSize)
+	if err != nil {
+		logger.Log.Error("cannot split metrics batch", zap.Error(err))
+		return nil
+	}
+
+	if agent.spool != nil && !agent.spool.Empty() {
+		// в очереди есть неотправленные пакеты - текущий пакет отправляется после них
+		agent.changes.notDelivered()
+		for _, chunk := range chunks {
+			agent.spoolBatch(chunk, countersOf(chunk), agent.nextBatchIdentity())
+		}
+		agent.replaySpool()
+		return nil
+	}
+
+	return chunks
+}
+
+// dispatchMetrics формирует пакет метрик и передает его части горутинам отправки,
+// которые отправляют их параллельно. Следующий пакет формируется только после обработки
+// всех частей предыдущего: иначе еще не вычтенные дельты счетчиков были бы отправлены повторно.
+// При отмене ctx части, не переданные горутинам отправки, не отправляются: их дельты счетчиков
+// остаются у агента и уходят с последней отправкой, которая выполняется после завершения горутин.
+func (agent *Agent) dispatchMetrics(ctx context.Context, jobs chan<- sendJob) {
+	if !agent.muxDispatch.TryLock() {
+		logger.Log.Warn("previous metrics batch is still being sent, report skipped")
+		return
+	}
+	defer agent.muxDispatch.Unlock()
+
+	chunks := agent.splitSnapshot()
+	if len(chunks) > 1 {
+		logger.Log.Info("metrics batch split", zap.Int("chunks", len(chunks)))
+	}
+
+	var done sync.WaitGroup
+	defer done.Wait()
+
+	for _, chunk := range chunks {
+		done.Add(1)
+		select {
+		case jobs <- sendJob{batch: chunk, identity: agent.nextBatchIdentity(), done: done.Done}:
+		case <-ctx.Done():
+			done.Done()
+			return
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/galogen13/yandex-go-metrics/internal/agent/collector"
+	"github.com/galogen13/yandex-go-metrics/internal/config"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+func TestSplitBatch(t *testing.T) {
+	batch := testMetricsBatch(t, 10)
+
+	t.Run("Без ограничений", func(t *testing.T) {
+		chunks, err := splitBatch(batch, 0, 0)
+		require.NoError(t, err)
+		assert.Equal(t, [][]*metrics.Metric{batch}, chunks)
+	})
+
+	t.Run("По количеству метрик", func(t *testing.T) {
+		chunks, err := splitBatch(batch, 4, 0)
+		require.NoError(t, err)
+		require.Len(t, chunks, 3)
+		assert.Len(t, chunks[0], 4)
+		assert.Len(t, chunks[2], 2)
+		assert.Equal(t, batch, slices.Concat(chunks...))
+	})
+
+	t.Run("По размеру", func(t *testing.T) {
+		const maxBytes = 150
+		chunks, err := splitBatch(batch, 0, maxBytes)
+		require.NoError(t, err)
+		assert.Greater(t, len(chunks), 1)
+		assert.Equal(t, batch, slices.Concat(chunks...))
+		for _, chunk := range chunks {
+			encoded, err := json.Marshal(chunk)
+			require.NoError(t, err)
+			assert.LessOrEqual(t, len(encoded), maxBytes)
+		}
+	})
+
+	t.Run("Метрика больше ограничения", func(t *testing.T) {
+		chunks, err := splitBatch(batch[:3], 0, 10)
+		require.NoError(t, err)
+		assert.Len(t, chunks, 3)
+	})
+}
+
+// funcSender отправляет пакеты функцией send
+type funcSender func(batch []*metrics.Metric) error
+
+func (s funcSender) Send(_ context.Context, batch []*metrics.Metric) error {
+	return s(batch)
+}
+
+func (s funcSender) Close() error {
+	return nil
+}
+
+func TestAgent_DispatchMetrics(t *testing.T) {
+	agentConfig := config.AgentConfig{Host: "localhost:8080", RateLimit: 3, BatchMaxMetrics: 2}
+	agent, err := NewAgent(agentConfig, collector.NewRegistry())
+	require.NoError(t, err)
+	agent.labels = nil
+
+	for i := range 6 {
+		agent.addCounter(fmt.Sprintf("Counter%d", i), int64(i+1))
+	}
+
+	var mux sync.Mutex
+	delivered := map[string]bool{}
+	agent.sender = funcSender(func(batch []*metrics.Metric) error {
+		if slices.ContainsFunc(batch, func(m *metrics.Metric) bool { return m.ID == "Counter3" }) {
+			return fmt.Errorf("%w: broken chunk", ErrServerUnavailable)
+		}
+		mux.Lock()
+		defer mux.Unlock()
+		for _, metric := range batch {
+			delivered[metric.ID] = true
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	var wg sync.WaitGroup
+	jobs := newSendJobs()
+	newSendWorkerPool(ctx, &wg, jobs, agent.startSendWorker).resize(agentConfig.RateLimit)
+
+	agent.dispatchMetrics(ctx, jobs)
+
+	assert.True(t, delivered["Counter0"])
+	assert.True(t, delivered["Counter5"])
+	assert.False(t, delivered["Counter3"])
+
+	// дельты доставленных частей вычтены, недоставленная часть остается для следующей отправки
+	assert.Equal(t, int64(0), counterDeltas(agent)["Counter0"])
+	assert.Equal(t, int64(0), counterDeltas(agent)["Counter5"])
+	assert.Equal(t, int64(3), counterDeltas(agent)["Counter2"], "Counter2 отправлялся в одной части с Counter3")
+	assert.Equal(t, int64(4), counterDeltas(agent)["Counter3"])
+
+	cancel()
+	wg.Wait()
+}
+
+func TestAgent_DispatchMetricsShutdown(t *testing.T) {
+	agentConfig := config.AgentConfig{Host: "localhost:8080", RateLimit: 1, BatchMaxMetrics: 1}
+	agent, err := NewAgent(agentConfig, collector.NewRegistry())
+	require.NoError(t, err)
+
+	for i := range 3 {
+		agent.addCounter(fmt.Sprintf("Counter%d", i), int64(i+1))
+	}
+
+	var mux sync.Mutex
+	delivered := map[string]bool{}
+	sending := make(chan struct{}, 1)
+	release := make(chan struct{})
+	agent.sender = funcSender(func(batch []*metrics.Metric) error {
+		select {
+		case sending <- struct{}{}:
+		default:
+		}
+		<-release
+
+		mux.Lock()
+		defer mux.Unlock()
+		for _, metric := range batch {
+			delivered[metric.ID] = true
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	var wg sync.WaitGroup
+	jobs := newSendJobs()
+	newSendWorkerPool(ctx, &wg, jobs, agent.startSendWorker).resize(agentConfig.RateLimit)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		agent.dispatchMetrics(ctx, jobs)
+	}()
+
+	<-sending
+	cancel()
+	close(release)
+
+	stopped := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("agent did not stop: dispatched chunk was never processed")
+	}
+
+	deltas := counterDeltas(agent)
+	undelivered := 0
+	for i := range 3 {
+		mID := fmt.Sprintf("Counter%d", i)
+		if delivered[mID] {
+			assert.Zero(t, deltas[mID], "дельта доставленной части вычтена")
+			continue
+		}
+		undelivered++
+		assert.Equal(t, int64(i+1), deltas[mID], "дельты непереданных частей остаются для последней отправки")
+	}
+	assert.Positive(t, undelivered)
+}
+
+// flushingCollector - сборщик, отдающий часть метрик при формировании пакета
+type flushingCollector struct {
+	funcCollector
+	flush func() ([]*metrics.Metric, error)
+}
+
+func (c *flushingCollector) Flush() ([]*metrics.Metric, error) {
+	return c.flush()
+}
+
+func TestAgent_SnapshotFlushesCollectors(t *testing.T) {
+	var timerMax float64
+	timerCount := int64(0)
+
+	registry := collector.NewRegistry()
+	registry.Register("timers", func(settings collector.Settings) (collector.Collector, error) {
+		return &flushingCollector{
+			funcCollector: funcCollector{name: settings.Name, interval: settings.Interval},
+			flush: func() ([]*metrics.Metric, error) {
+				if timerCount == 0 {
+					return nil, nil
+				}
+				gauge, err := collector.NewGaugeMetric("DbQueryMax", timerMax)
+				require.NoError(t, err)
+				counter := testCounter(t, "DbQueryCount", timerCount)
+				timerMax, timerCount = 0, 0
+				return []*metrics.Metric{gauge, counter}, nil
+			},
+		}, nil
+	}, true)
+
+	agent, err := NewAgent(config.AgentConfig{Host: "localhost:8080"}, registry)
+	require.NoError(t, err)
+
+	// несколько сборов за интервал отправки: собранное сборщиком не заменяет накопленное для пакета
+	for _, value := range []float64{300, 100} {
+		agent.storeResult(metricsResult{name: "timers"})
+		timerMax = max(timerMax, value)
+		timerCount++
+	}
+
+	batch, err := agent.snapshot()
+	require.NoError(t, err)
+	got := map[string]*metrics.Metric{}
+	for _, metric := range batch {
+		got[metric.ID] = metric
+	}
+	require.Contains(t, got, "DbQueryMax")
+	assert.Equal(t, 300.0, *got["DbQueryMax"].Value)
+	require.Contains(t, got, "DbQueryCount")
+	assert.Equal(t, int64(2), *got["DbQueryCount"].Delta)
+}
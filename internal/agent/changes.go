@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"sync"
+
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+// changeFilter исключает из пакета метрики, отправка которых ничего не изменит на сервере:
+// значения gauge, совпадающие с последними доставленными, и нулевые дельты counter.
+// Чтобы сервер, потерявший данные (например, перезапущенный без файла хранилища), получил все метрики,
+// каждый heartbeat_every пакет отправляется полностью. Пакеты считаются только доставленные:
+// если пакет доставлен не полностью, следующий пакет формируется так же, как он.
+type changeFilter struct {
+	mu sync.Mutex
+	// sent - последние доставленные на сервер значения gauge, ключ - metrics.Metric.Key
+	sent map[string]float64
+	// reports - количество пакетов, доставленных после последнего полного
+	reports int
+	// reporting - сформирован пакет, результат отправки которого еще не учтен в reports
+	reporting bool
+	// failed - часть последнего сформированного пакета не доставлена
+	failed bool
+}
+
+func newChangeFilter() *changeFilter {
+	return &changeFilter{sent: map[string]float64{}}
+}
+
+// filter возвращает метрики пакета, изменившиеся после последней доставки.
+// Первый и каждый heartbeatEvery пакет после доставленного полного содержит все значения gauge;
+// если heartbeatEvery = 0, полностью отправляется только первый доставленный пакет. Нулевые дельты counter исключаются всегда.
+func (f *changeFilter) filter(batch []*metrics.Metric, heartbeatEvery int) []*metrics.Metric {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.advance(heartbeatEvery)
+	full := f.reports == 0
+	f.reporting = true
+	f.failed = false
+
+	result := make([]*metrics.Metric, 0, len(batch))
+	for _, metric := range batch {
+		switch {
+		case metric.MType == metrics.Counter && metric.Delta != nil:
+			if *metric.Delta == 0 {
+				continue
+			}
+		case metric.MType == metrics.Gauge && metric.Value != nil && !full:
+			if value, ok := f.sent[metric.Key()]; ok && value == *metric.Value {
+				continue
+			}
+		}
+		result = append(result, metric)
+	}
+	return result
+}
+
+// advance учитывает в reports предыдущий сформированный пакет, если он доставлен полностью
+func (f *changeFilter) advance(heartbeatEvery int) {
+	if !f.reporting || f.failed {
+		return
+	}
+	f.reporting = false
+	f.reports++
+	if heartbeatEvery > 0 && f.reports >= heartbeatEvery {
+		f.reports = 0
+	}
+}
+
+// notDelivered отмечает, что часть последнего сформированного пакета не доставлена на сервер
+func (f *changeFilter) notDelivered() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.failed = true
+}
+
+// delivered запоминает значения gauge из пакета, доставленного на сервер
+func (f *changeFilter) delivered(batch []*metrics.Metric) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, metric := range batch {
+		if metric.MType == metrics.Gauge && metric.Value != nil {
+			f.sent[metric.Key()] = *metric.Value
+		}
+	}
+}
+
+// Reset забывает доставленные значения: следующий пакет будет отправлен полностью
+func (f *changeFilter) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	clear(f.sent)
+	f.reports = 0
+	f.reporting = false
+	f.failed = false
+}
@@ -0,0 +1,153 @@
+package agent
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/galogen13/yandex-go-metrics/internal/agent/collector"
+	"github.com/galogen13/yandex-go-metrics/internal/config"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+func testCounter(t *testing.T, mID string, delta int64) *metrics.Metric {
+	t.Helper()
+
+	metric, err := collector.NewCounterMetric(mID, delta)
+	require.NoError(t, err)
+	return metric
+}
+
+func metricIDs(batch []*metrics.Metric) []string {
+	ids := make([]string, 0, len(batch))
+	for _, metric := range batch {
+		ids = append(ids, metric.ID)
+	}
+	return ids
+}
+
+func TestChangeFilter(t *testing.T) {
+	const heartbeatEvery = 3
+	filter := newChangeFilter()
+
+	report := func(totalMemory, alloc float64, pollCount, forcedGC int64) []string {
+		batch := []*metrics.Metric{
+			testGauge(t, "TotalMemory", totalMemory),
+			testGauge(t, "Alloc", alloc),
+			testCounter(t, "PollCount", pollCount),
+			testCounter(t, "NumForcedGC", forcedGC),
+		}
+		filtered := filter.filter(batch, heartbeatEvery)
+		filter.delivered(filtered)
+		return metricIDs(filtered)
+	}
+
+	assert.Equal(t, []string{"TotalMemory", "Alloc", "PollCount"}, report(100, 1, 5, 0),
+		"первый пакет отправляется полностью, кроме нулевых дельт")
+	assert.Equal(t, []string{"Alloc", "PollCount", "NumForcedGC"}, report(100, 2, 5, 1),
+		"неизменившееся значение gauge не отправляется")
+	assert.Equal(t, []string{"PollCount"}, report(100, 2, 5, 0))
+	assert.Equal(t, []string{"TotalMemory", "Alloc", "PollCount"}, report(100, 2, 5, 0),
+		"каждый heartbeat_every пакет отправляется полностью")
+	assert.Equal(t, []string{"PollCount"}, report(100, 2, 5, 0))
+
+	// недоставленное значение отправляется снова
+	batch := []*metrics.Metric{testGauge(t, "TotalMemory", 200)}
+	assert.Len(t, filter.filter(batch, heartbeatEvery), 1)
+	assert.Len(t, filter.filter(batch, heartbeatEvery), 1)
+
+	filter.Reset()
+	assert.Equal(t, []string{"TotalMemory", "Alloc", "PollCount"}, report(100, 2, 5, 0),
+		"после сброса пакет отправляется полностью")
+}
+
+func TestChangeFilter_NoHeartbeat(t *testing.T) {
+	filter := newChangeFilter()
+
+	batch := []*metrics.Metric{testGauge(t, "TotalMemory", 100)}
+	require.Len(t, filter.filter(batch, 0), 1)
+	filter.delivered(batch)
+
+	for range 20 {
+		assert.Empty(t, filter.filter(batch, 0), "при heartbeat_every = 0 полностью отправляется только первый пакет")
+	}
+}
+
+func TestAgent_ChangedOnlyFailedReport(t *testing.T) {
+	registry := collector.NewRegistry()
+	registry.Register("app", funcCollectorFactory(nil), true)
+	agent, err := NewAgent(config.AgentConfig{
+		Host:            "localhost:8080",
+		SendChangedOnly: true,
+		HeartbeatEvery:  2,
+	}, registry)
+	require.NoError(t, err)
+
+	var sendErr error
+	agent.sender = funcSender(func([]*metrics.Metric) error { return sendErr })
+	agent.storeResult(metricsResult{name: "app", metrics: []*metrics.Metric{testGauge(t, "Temperature", 20)}})
+
+	report := func() bool {
+		batch, err := agent.snapshot()
+		require.NoError(t, err)
+		agent.sendBatch(batch, agent.nextBatchIdentity())
+		for _, metric := range batch {
+			if metric.ID == "Temperature" {
+				return true
+			}
+		}
+		return false
+	}
+
+	require.True(t, report(), "первый пакет отправляется полностью")
+
+	sendErr = fmt.Errorf("%w: connection refused", ErrServerUnavailable)
+	assert.False(t, report(), "неизменившееся значение не отправляется")
+	assert.False(t, report(), "недоставленный пакет не продвигает цикл полной отправки")
+
+	sendErr = nil
+	assert.False(t, report())
+	sendErr = fmt.Errorf("%w: connection refused", ErrServerUnavailable)
+	assert.True(t, report(), "каждый heartbeat_every пакет отправляется полностью")
+	sendErr = nil
+	assert.True(t, report(), "недоставленный полный пакет повторяется полностью")
+	assert.False(t, report())
+}
+
+func TestAgent_ChangedOnlyReplayedReport(t *testing.T) {
+	registry := collector.NewRegistry()
+	registry.Register("app", funcCollectorFactory(nil), true)
+	agent, err := NewAgent(config.AgentConfig{
+		Host:            "localhost:8080",
+		SendChangedOnly: true,
+		SpoolDir:        t.TempDir(),
+	}, registry)
+	require.NoError(t, err)
+
+	var sendErr error
+	agent.sender = funcSender(func([]*metrics.Metric) error { return sendErr })
+
+	report := func(temperature float64) bool {
+		agent.storeResult(metricsResult{name: "app", metrics: []*metrics.Metric{testGauge(t, "Temperature", temperature)}})
+		batch, err := agent.snapshot()
+		require.NoError(t, err)
+		agent.sendBatch(batch, agent.nextBatchIdentity())
+		for _, metric := range batch {
+			if metric.ID == "Temperature" {
+				return true
+			}
+		}
+		return false
+	}
+
+	require.True(t, report(20))
+
+	sendErr = fmt.Errorf("%w: connection refused", ErrServerUnavailable)
+	require.True(t, report(21), "изменившееся значение отправляется и попадает в очередь")
+
+	sendErr = nil
+	agent.replaySpool()
+	assert.False(t, report(21), "значение, доставленное из очереди, не отправляется повторно")
+}
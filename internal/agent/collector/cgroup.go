@@ -0,0 +1,405 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+// CgroupCollectorName - имя сборщика метрик контрольных групп cgroup v2
+const CgroupCollectorName = "cgroup"
+
+// defaultCgroupRoot - точка монтирования иерархии cgroup v2
+const defaultCgroupRoot = "/sys/fs/cgroup"
+
+// cgroupLabel - метка с путем контрольной группы относительно корня иерархии
+const cgroupLabel = "cgroup"
+
+// cgroupPressureResources - ресурсы, для которых ядро отдает информацию о простое (PSI)
+var cgroupPressureResources = []struct {
+	file   string
+	prefix string
+}{
+	{file: "cpu.pressure", prefix: "CgroupCPU"},
+	{file: "memory.pressure", prefix: "CgroupMemory"},
+	{file: "io.pressure", prefix: "CgroupIO"},
+}
+
+// cgroupOptions - параметры сборщика cgroup
+type cgroupOptions struct {
+	Root    string   `mapstructure:"root"`    // точка монтирования иерархии cgroup v2
+	Cgroups []string `mapstructure:"cgroups"` // пути контрольных групп относительно root, допускаются шаблоны filepath.Match
+}
+
+// cgroupState - состояние контрольной группы с прошлого сбора
+type cgroupState struct {
+	counters *deltaTracker
+	cpuUsage uint64
+	at       time.Time
+}
+
+// cgroupCollector собирает потребление ресурсов контрольными группами cgroup v2
+// (срезы systemd, сервисы, контейнеры). Все метрики имеют метку cgroup с путем группы
+// относительно корня иерархии, например /system.slice/nginx.service.
+//
+// Метрики типа gauge:
+//   - CgroupCPUUtilization - загрузка CPU в процентах от одного ядра за время с прошлого сбора;
+//   - CgroupMemoryCurrent, CgroupMemoryMax - потребление памяти и его предел в байтах
+//     (CgroupMemoryMax не отдается, если предел не установлен);
+//   - Cgroup<Resource>PressureSome, Cgroup<Resource>PressureFull - доля времени за последние 10 секунд
+//     в процентах, когда часть (все) задачи группы ожидали ресурс; Resource - CPU, Memory или IO.
+//
+// Метрики типа counter (приращение с прошлого сбора):
+//   - CgroupCPUUsage, CgroupCPUUser, CgroupCPUSystem, CgroupCPUThrottled - время CPU в микросекундах;
+//   - CgroupMemoryOOMKills - число процессов, завершенных при нехватке памяти;
+//   - CgroupIOReadBytes, CgroupIOWriteBytes - байты ввода-вывода по всем устройствам;
+//   - Cgroup<Resource>StallSome, Cgroup<Resource>StallFull - время ожидания ресурса в микросекундах.
+//
+// Файлы отключенных контроллеров пропускаются.
+type cgroupCollector struct {
+	name     string
+	interval time.Duration
+	root     string
+	patterns []string
+	state    map[string]*cgroupState
+	now      func() time.Time
+}
+
+// NewCgroupCollector создает сборщик метрик контрольных групп.
+//
+// Опции:
+//   - root - точка монтирования иерархии cgroup v2; по умолчанию /sys/fs/cgroup
+//   - cgroups - пути контрольных групп относительно root, например "system.slice/*.service" (обязательная)
+func NewCgroupCollector(settings Settings) (Collector, error) {
+	var options cgroupOptions
+	if err := DecodeOptions(settings.Options, &options); err != nil {
+		return nil, err
+	}
+	if options.Root == "" {
+		options.Root = defaultCgroupRoot
+	}
+	if len(options.Cgroups) == 0 {
+		return nil, errors.New("cgroups list is not filled")
+	}
+
+	patterns := make([]string, 0, len(options.Cgroups))
+	for _, pattern := range options.Cgroups {
+		pattern = filepath.Clean(strings.TrimPrefix(pattern, "/"))
+		if pattern == ".." || strings.HasPrefix(pattern, "../") {
+			return nil, fmt.Errorf("invalid cgroup path %q: must be inside root", pattern)
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid cgroup path %q: %w", pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	return &cgroupCollector{
+		name:     settings.Name,
+		interval: settings.Interval,
+		root:     options.Root,
+		patterns: patterns,
+		state:    map[string]*cgroupState{},
+		now:      time.Now,
+	}, nil
+}
+
+func (c *cgroupCollector) Name() string {
+	return c.name
+}
+
+func (c *cgroupCollector) Interval() time.Duration {
+	return c.interval
+}
+
+func (c *cgroupCollector) Collect(_ context.Context) ([]*metrics.Metric, error) {
+	if _, err := os.Stat(filepath.Join(c.root, "cgroup.controllers")); err != nil {
+		return nil, fmt.Errorf("%s is not a cgroup v2 hierarchy: %w", c.root, err)
+	}
+
+	paths, err := c.cgroups()
+	if err != nil {
+		return nil, err
+	}
+
+	now := c.now()
+	result := []*metrics.Metric{}
+	errs := []error{}
+	state := make(map[string]*cgroupState, len(paths))
+
+	for _, path := range paths {
+		prev, ok := c.state[path]
+		if !ok {
+			prev = &cgroupState{counters: newDeltaTracker()}
+		}
+
+		collected, current, err := c.collectCgroup(path, prev, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cgroup %s: %w", path, err))
+			continue
+		}
+		state[path] = current
+
+		for _, metric := range collected {
+			metric.Labels = map[string]string{cgroupLabel: path}
+		}
+		result = append(result, collected...)
+	}
+
+	// состояние удаленных групп забывается
+	c.state = state
+	return result, errors.Join(errs...)
+}
+
+// cgroups возвращает пути существующих контрольных групп, подходящих под шаблоны.
+// Путь без шаблона, не найденный в иерархии, считается ошибкой; шаблон может не найти ни одной группы.
+func (c *cgroupCollector) cgroups() ([]string, error) {
+	seen := map[string]bool{}
+	paths := []string{}
+	errs := []error{}
+
+	for _, pattern := range c.patterns {
+		matches, _ := filepath.Glob(filepath.Join(c.root, pattern))
+		if len(matches) == 0 && !strings.ContainsAny(pattern, `*?[\`) {
+			errs = append(errs, fmt.Errorf("cgroup %s not found", pattern))
+			continue
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil || !info.IsDir() {
+				continue
+			}
+			rel, err := filepath.Rel(c.root, match)
+			if err != nil {
+				continue
+			}
+			path := "/" + filepath.ToSlash(rel)
+			if rel == "." {
+				path = "/"
+			}
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+
+	return paths, errors.Join(errs...)
+}
+
+// collectCgroup читает файлы интерфейса контрольной группы
+func (c *cgroupCollector) collectCgroup(path string, prev *cgroupState, now time.Time) ([]*metrics.Metric, *cgroupState, error) {
+	dir := filepath.Join(c.root, filepath.FromSlash(path))
+	gauges := []gaugeValue{}
+	counters := map[string]uint64{}
+	current := &cgroupState{counters: prev.counters, at: now}
+
+	cpuStat, err := readCgroupKeyValues(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if usage, ok := cpuStat["usage_usec"]; ok {
+		counters["CgroupCPUUsage"] = usage
+		current.cpuUsage = usage
+		if !prev.at.IsZero() && usage >= prev.cpuUsage {
+			if elapsed := now.Sub(prev.at); elapsed > 0 {
+				utilization := float64(usage-prev.cpuUsage) / float64(elapsed.Microseconds()) * 100
+				gauges = append(gauges, gaugeValue{prefix: "CgroupCPUUtilization", value: utilization})
+			}
+		}
+	}
+	setCounter(counters, "CgroupCPUUser", cpuStat, "user_usec")
+	setCounter(counters, "CgroupCPUSystem", cpuStat, "system_usec")
+	setCounter(counters, "CgroupCPUThrottled", cpuStat, "throttled_usec")
+
+	if value, ok, err := readCgroupValue(filepath.Join(dir, "memory.current")); err != nil {
+		return nil, nil, err
+	} else if ok {
+		gauges = append(gauges, gaugeValue{prefix: "CgroupMemoryCurrent", value: float64(value)})
+	}
+	if value, ok, err := readCgroupValue(filepath.Join(dir, "memory.max")); err != nil {
+		return nil, nil, err
+	} else if ok {
+		gauges = append(gauges, gaugeValue{prefix: "CgroupMemoryMax", value: float64(value)})
+	}
+
+	memoryEvents, err := readCgroupKeyValues(filepath.Join(dir, "memory.events"))
+	if err != nil {
+		return nil, nil, err
+	}
+	setCounter(counters, "CgroupMemoryOOMKills", memoryEvents, "oom_kill")
+
+	ioRead, ioWrite, ok, err := readCgroupIOStat(filepath.Join(dir, "io.stat"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if ok {
+		counters["CgroupIOReadBytes"] = ioRead
+		counters["CgroupIOWriteBytes"] = ioWrite
+	}
+
+	for _, resource := range cgroupPressureResources {
+		pressure, err := readCgroupPressure(filepath.Join(dir, resource.file))
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, kind := range []string{"some", "full"} {
+			line, ok := pressure[kind]
+			if !ok {
+				continue
+			}
+			gauges = append(gauges, gaugeValue{prefix: MetricID(resource.prefix+"Pressure", kind), value: line.avg10})
+			counters[MetricID(resource.prefix+"Stall", kind)] = line.total
+		}
+	}
+
+	result, err := gaugeMetrics(gauges)
+	if err != nil {
+		return nil, nil, err
+	}
+	counterResult, err := counterMetrics(current.counters.deltas(counters))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return append(result, counterResult...), current, nil
+}
+
+func setCounter(counters map[string]uint64, mID string, values map[string]uint64, key string) {
+	if value, ok := values[key]; ok {
+		counters[mID] = value
+	}
+}
+
+// readCgroupFile читает файл интерфейса. Отсутствующий файл (контроллер отключен) не считается ошибкой.
+func readCgroupFile(path string) ([]byte, bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// readCgroupValue читает файл с одним числом. Значение "max" означает отсутствие предела.
+func readCgroupValue(path string) (uint64, bool, error) {
+	data, ok, err := readCgroupFile(path)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+
+	text := strings.TrimSpace(string(data))
+	if text == "max" {
+		return 0, false, nil
+	}
+	value, err := strconv.ParseUint(text, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("cannot parse %s: %w", filepath.Base(path), err)
+	}
+	return value, true, nil
+}
+
+// readCgroupKeyValues читает файл из строк "ключ значение" (cpu.stat, memory.events)
+func readCgroupKeyValues(path string) (map[string]uint64, error) {
+	data, ok, err := readCgroupFile(path)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	result := map[string]uint64{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %s: %w", filepath.Base(path), err)
+		}
+		result[fields[0]] = value
+	}
+	return result, nil
+}
+
+// readCgroupIOStat суммирует прочитанные и записанные байты по всем устройствам из io.stat,
+// строки которого имеют вид "8:0 rbytes=1024 wbytes=2048 rios=1 wios=2 dbytes=0 dios=0".
+func readCgroupIOStat(path string) (read, write uint64, ok bool, err error) {
+	data, ok, err := readCgroupFile(path)
+	if err != nil || !ok {
+		return 0, 0, false, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		for _, field := range fields[min(1, len(fields)):] {
+			key, text, found := strings.Cut(field, "=")
+			if !found || (key != "rbytes" && key != "wbytes") {
+				continue
+			}
+			value, err := strconv.ParseUint(text, 10, 64)
+			if err != nil {
+				return 0, 0, false, fmt.Errorf("cannot parse io.stat: %w", err)
+			}
+			if key == "rbytes" {
+				read += value
+			} else {
+				write += value
+			}
+		}
+	}
+	return read, write, true, nil
+}
+
+// pressureLine - строка файла PSI: "some avg10=0.12 avg60=0.05 avg300=0.01 total=123456"
+type pressureLine struct {
+	avg10 float64
+	total uint64
+}
+
+// readCgroupPressure читает файл PSI (cpu.pressure, memory.pressure, io.pressure)
+func readCgroupPressure(path string) (map[string]pressureLine, error) {
+	data, ok, err := readCgroupFile(path)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	result := map[string]pressureLine{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		var line pressureLine
+		for _, field := range fields[1:] {
+			key, text, _ := strings.Cut(field, "=")
+			switch key {
+			case "avg10":
+				line.avg10, err = strconv.ParseFloat(text, 64)
+			case "total":
+				line.total, err = strconv.ParseUint(text, 10, 64)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse %s: %w", filepath.Base(path), err)
+			}
+		}
+		result[fields[0]] = line
+	}
+	return result, nil
+}
@@ -0,0 +1,114 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+func TestCgroupCollector(t *testing.T) {
+	root := t.TempDir()
+
+	writeFiles := func(cgroup string, files map[string]string) {
+		t.Helper()
+		dir := filepath.Join(root, cgroup)
+		require.NoError(t, os.MkdirAll(dir, 0o755))
+		for name, content := range files {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+		}
+	}
+
+	writeFiles(".", map[string]string{"cgroup.controllers": "cpu io memory pids\n"})
+	writeFiles("system.slice/nginx.service", map[string]string{
+		"cpu.stat":       "usage_usec 1000000\nuser_usec 600000\nsystem_usec 400000\nnr_throttled 0\nthrottled_usec 0\n",
+		"memory.current": "104857600\n",
+		"memory.max":     "max\n",
+		"memory.events":  "low 0\nhigh 0\nmax 0\noom 0\noom_kill 0\n",
+		"io.stat":        "8:0 rbytes=1000 wbytes=2000 rios=1 wios=2 dbytes=0 dios=0\n259:0 rbytes=500 wbytes=0 rios=1 wios=0 dbytes=0 dios=0\n",
+		"cpu.pressure":   "some avg10=1.50 avg60=0.80 avg300=0.20 total=5000\nfull avg10=0.00 avg60=0.00 avg300=0.00 total=0\n",
+		"memory.pressure": "some avg10=0.00 avg60=0.00 avg300=0.00 total=100\n" +
+			"full avg10=0.00 avg60=0.00 avg300=0.00 total=50\n",
+	})
+	// контроллеры cpu и io для группы не включены
+	writeFiles("system.slice/cron.service", map[string]string{
+		"memory.current": "2048\n",
+		"memory.max":     "1073741824\n",
+	})
+	writeFiles("user.slice", map[string]string{"memory.current": "1\n"})
+
+	c, err := NewCgroupCollector(Settings{Name: CgroupCollectorName, Interval: time.Second,
+		Options: map[string]any{"root": root, "cgroups": []any{"system.slice/*.service"}}})
+	require.NoError(t, err)
+
+	now := time.Now()
+	c.(*cgroupCollector).now = func() time.Time { return now }
+
+	collect := func() map[string]*metrics.Metric {
+		t.Helper()
+		collected, err := c.Collect(t.Context())
+		require.NoError(t, err)
+		result := map[string]*metrics.Metric{}
+		for _, metric := range collected {
+			result[metric.Key()] = metric
+		}
+		return result
+	}
+
+	const nginx = `{cgroup="/system.slice/nginx.service"}`
+	const cron = `{cgroup="/system.slice/cron.service"}`
+
+	got := collect()
+	assert.Equal(t, 104857600.0, *got["CgroupMemoryCurrent"+nginx].Value)
+	assert.NotContains(t, got, "CgroupMemoryMax"+nginx, "предел не установлен")
+	assert.Equal(t, 1073741824.0, *got["CgroupMemoryMax"+cron].Value)
+	assert.Equal(t, 1.5, *got["CgroupCPUPressureSome"+nginx].Value)
+	assert.Equal(t, 0.0, *got["CgroupMemoryPressureFull"+nginx].Value)
+	assert.NotContains(t, got, "CgroupCPUUtilization"+nginx, "при первом сборе загрузка не вычисляется")
+	assert.NotContains(t, got, "CgroupCPUUsage"+nginx, "при первом сборе дельта не отдается")
+	assert.NotContains(t, got, `CgroupMemoryCurrent{cgroup="/user.slice"}`)
+
+	now = now.Add(10 * time.Second)
+	writeFiles("system.slice/nginx.service", map[string]string{
+		"cpu.stat":      "usage_usec 6000000\nuser_usec 3600000\nsystem_usec 2400000\nnr_throttled 1\nthrottled_usec 250\n",
+		"memory.events": "low 0\nhigh 0\nmax 3\noom 1\noom_kill 1\n",
+		"io.stat":       "8:0 rbytes=4000 wbytes=2000 rios=4 wios=2 dbytes=0 dios=0\n259:0 rbytes=500 wbytes=100 rios=1 wios=1 dbytes=0 dios=0\n",
+		"cpu.pressure":  "some avg10=2.00 avg60=0.80 avg300=0.20 total=8000\nfull avg10=0.00 avg60=0.00 avg300=0.00 total=0\n",
+	})
+
+	got = collect()
+	assert.InDelta(t, 50.0, *got["CgroupCPUUtilization"+nginx].Value, 1e-9)
+	assert.Equal(t, int64(5000000), *got["CgroupCPUUsage"+nginx].Delta)
+	assert.Equal(t, int64(250), *got["CgroupCPUThrottled"+nginx].Delta)
+	assert.Equal(t, int64(1), *got["CgroupMemoryOOMKills"+nginx].Delta)
+	assert.Equal(t, int64(3000), *got["CgroupIOReadBytes"+nginx].Delta)
+	assert.Equal(t, int64(100), *got["CgroupIOWriteBytes"+nginx].Delta)
+	assert.Equal(t, int64(3000), *got["CgroupCPUStallSome"+nginx].Delta)
+	assert.NotContains(t, got, "CgroupCPUUsage"+cron)
+}
+
+func TestCgroupCollector_Errors(t *testing.T) {
+	_, err := NewCgroupCollector(Settings{Name: CgroupCollectorName})
+	assert.Error(t, err, "список групп обязателен")
+
+	_, err = NewCgroupCollector(Settings{Name: CgroupCollectorName,
+		Options: map[string]any{"cgroups": []any{"../etc"}}})
+	assert.Error(t, err, "путь вне иерархии")
+
+	root := t.TempDir()
+	c, err := NewCgroupCollector(Settings{Name: CgroupCollectorName,
+		Options: map[string]any{"root": root, "cgroups": []any{"system.slice"}}})
+	require.NoError(t, err)
+
+	_, err = c.Collect(t.Context())
+	assert.Error(t, err, "каталог не является иерархией cgroup v2")
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "cgroup.controllers"), nil, 0o644))
+	_, err = c.Collect(t.Context())
+	assert.Error(t, err, "группа не найдена")
+}
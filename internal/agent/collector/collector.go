@@ -0,0 +1,202 @@
+// Пакет collector содержит интерфейс сборщика метрик агента, реестр сборщиков
+// и встроенные сборщики.
+//
+// Чтобы добавить собственный сборщик, достаточно реализовать интерфейс Collector,
+// написать фабрику типа Factory и зарегистрировать ее в реестре до запуска агента:
+//
+//	registry := collector.NewDefaultRegistry()
+//	registry.Register("my", mycollector.New, true)
+//	agent.Start(config, registry)
+//
+// Параметры сборщика (включен ли он, интервал, таймаут, выравнивание по часам, случайная задержка,
+// произвольные опции) задаются в секции collectors конфигурации агента по имени сборщика.
+// Агент вызывает Collect по расписанию сборщика с контекстом, который отменяется по истечении таймаута.
+package collector
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+	"go.uber.org/zap"
+
+	"github.com/galogen13/yandex-go-metrics/internal/config"
+	"github.com/galogen13/yandex-go-metrics/internal/logger"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+// Collector - источник метрик агента.
+type Collector interface {
+	// Name возвращает имя сборщика, под которым он зарегистрирован.
+	Name() string
+
+	// Interval возвращает интервал между сборами метрик.
+	Interval() time.Duration
+
+	// Collect собирает метрики. Метрики типа counter должны содержать
+	// дельту с момента предыдущего вызова Collect.
+	Collect(ctx context.Context) ([]*metrics.Metric, error)
+}
+
+// Runner - сборщик, которому нужна фоновая работа помимо периодического сбора,
+// например прием метрик по сети. Агент запускает Run в отдельной горутине
+// и отменяет контекст при остановке.
+type Runner interface {
+	Run(ctx context.Context) error
+}
+
+// Flusher - сборщик, часть метрик которого агрегируется за интервал отправки, а не за интервал
+// сбора, например статистика таймеров. Агент вызывает Flush при формировании каждого пакета
+// и добавляет метрики в пакет. Метрики типа counter должны содержать дельту с момента
+// предыдущего вызова Flush.
+type Flusher interface {
+	Flush() ([]*metrics.Metric, error)
+}
+
+// Settings - параметры, с которыми фабрика создает сборщик.
+type Settings struct {
+	Name     string         // имя сборщика
+	Interval time.Duration  // интервал между сборами
+	Options  map[string]any // произвольные параметры из конфигурации
+}
+
+// Factory создает сборщик по параметрам.
+type Factory func(settings Settings) (Collector, error)
+
+type registration struct {
+	factory          Factory
+	enabledByDefault bool
+}
+
+// Registry - реестр фабрик сборщиков.
+type Registry struct {
+	mux           sync.RWMutex
+	registrations map[string]registration
+	names         []string // имена в порядке регистрации
+}
+
+// NewRegistry создает пустой реестр сборщиков.
+func NewRegistry() *Registry {
+	return &Registry{
+		registrations: map[string]registration{},
+		names:         []string{},
+	}
+}
+
+// NewDefaultRegistry создает реестр со встроенными сборщиками агента.
+func NewDefaultRegistry() *Registry {
+	registry := NewRegistry()
+	registry.Register(RuntimeCollectorName, NewRuntimeCollector, true)
+	registry.Register(PSCollectorName, NewPSCollector, true)
+	registry.Register(DiskCollectorName, NewDiskCollector, false)
+	registry.Register(NetCollectorName, NewNetCollector, false)
+	registry.Register(LoadCollectorName, NewLoadCollector, false)
+	registry.Register(ProcessCollectorName, NewProcessCollector, false)
+	registry.Register(StatsDCollectorName, NewStatsDCollector, false)
+	registry.Register(ExecCollectorName, NewExecCollector, false)
+	registry.Register(TextfileCollectorName, NewTextfileCollector, false)
+	registry.Register(ScrapeCollectorName, NewScrapeCollector, false)
+	registry.Register(CgroupCollectorName, NewCgroupCollector, false)
+	return registry
+}
+
+// Register регистрирует фабрику сборщика под именем name.
+// enabledByDefault определяет, запускается ли сборщик, если в конфигурации не указано иное.
+// Повторная регистрация под тем же именем заменяет фабрику.
+func (r *Registry) Register(name string, factory Factory, enabledByDefault bool) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if _, ok := r.registrations[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.registrations[name] = registration{factory: factory, enabledByDefault: enabledByDefault}
+}
+
+// Build создает включенные в конфигурации сборщики в порядке регистрации.
+func (r *Registry) Build(agentConfig config.AgentConfig) ([]Collector, error) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	for name := range agentConfig.Collectors {
+		if _, ok := r.registrations[name]; !ok {
+			logger.Log.Warn("configuration for unknown collector ignored", zap.String("collector", name))
+		}
+	}
+
+	collectors := make([]Collector, 0, len(r.names))
+
+	for _, name := range r.names {
+		reg := r.registrations[name]
+		collectorConfig := agentConfig.Collectors[name]
+
+		enabled := reg.enabledByDefault
+		if collectorConfig.Enabled != nil {
+			enabled = *collectorConfig.Enabled
+		}
+		if !enabled {
+			logger.Log.Info("collector disabled", zap.String("collector", name))
+			continue
+		}
+
+		interval := collectorConfig.Interval
+		if interval <= 0 {
+			interval = time.Duration(agentConfig.PollInterval) * time.Second
+		}
+
+		collector, err := reg.factory(Settings{
+			Name:     name,
+			Interval: interval,
+			Options:  collectorConfig.Options,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("cannot create collector %s: %w", name, err)
+		}
+
+		collectors = append(collectors, collector)
+	}
+
+	return collectors, nil
+}
+
+// DecodeOptions раскладывает произвольные параметры сборщика в структуру target.
+// Поля структуры сопоставляются по тегу mapstructure, строки вида "10s" приводятся к time.Duration.
+func DecodeOptions(options map[string]any, target any) error {
+	if len(options) == 0 {
+		return nil
+	}
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		WeaklyTypedInput: true,
+		Result:           target,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot create options decoder: %w", err)
+	}
+
+	if err := decoder.Decode(options); err != nil {
+		return fmt.Errorf("cannot decode collector options: %w", err)
+	}
+	return nil
+}
+
+// NewGaugeMetric создает метрику типа gauge с заданным значением.
+func NewGaugeMetric(mID string, value float64) (*metrics.Metric, error) {
+	metric := metrics.NewMetrics(mID, metrics.Gauge)
+	if err := metric.UpdateValue(value); err != nil {
+		return nil, fmt.Errorf("error adding new gauge metric ID: %s, mType: %s, value: %v, err: %w", metric.ID, metric.MType, value, err)
+	}
+	return metric, nil
+}
+
+// NewCounterMetric создает метрику типа counter с заданной дельтой.
+func NewCounterMetric(mID string, value int64) (*metrics.Metric, error) {
+	metric := metrics.NewMetrics(mID, metrics.Counter)
+	if err := metric.UpdateValue(value); err != nil {
+		return nil, fmt.Errorf("error adding new counter metric ID: %s, mType: %s, value: %v, err: %w", metric.ID, metric.MType, value, err)
+	}
+	return metric, nil
+}
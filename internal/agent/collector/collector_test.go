@@ -0,0 +1,95 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/galogen13/yandex-go-metrics/internal/config"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+type testCollector struct {
+	settings Settings
+}
+
+func (c *testCollector) Name() string {
+	return c.settings.Name
+}
+
+func (c *testCollector) Interval() time.Duration {
+	return c.settings.Interval
+}
+
+func (c *testCollector) Collect(_ context.Context) ([]*metrics.Metric, error) {
+	return nil, nil
+}
+
+func newTestCollector(settings Settings) (Collector, error) {
+	return &testCollector{settings: settings}, nil
+}
+
+func TestRegistry_Build(t *testing.T) {
+	enabled := true
+	disabled := false
+
+	tests := []struct {
+		name       string
+		collectors map[string]config.CollectorConfig
+		want       map[string]time.Duration
+	}{
+		{name: "Сборщики по умолчанию",
+			collectors: nil,
+			want:       map[string]time.Duration{"first": 2 * time.Second}},
+		{name: "Включение и выключение в конфигурации",
+			collectors: map[string]config.CollectorConfig{
+				"first":  {Enabled: &disabled},
+				"second": {Enabled: &enabled, Interval: 10 * time.Second},
+			},
+			want: map[string]time.Duration{"second": 10 * time.Second}},
+		{name: "Неизвестный сборщик игнорируется",
+			collectors: map[string]config.CollectorConfig{
+				"unknown": {Enabled: &enabled},
+			},
+			want: map[string]time.Duration{"first": 2 * time.Second}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewRegistry()
+			registry.Register("first", newTestCollector, true)
+			registry.Register("second", newTestCollector, false)
+
+			collectors, err := registry.Build(config.AgentConfig{PollInterval: 2, Collectors: tt.collectors})
+			require.NoError(t, err)
+
+			got := make(map[string]time.Duration, len(collectors))
+			for _, c := range collectors {
+				got[c.Name()] = c.Interval()
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDecodeOptions(t *testing.T) {
+	var options struct {
+		Paths   []string      `mapstructure:"paths"`
+		Timeout time.Duration `mapstructure:"timeout"`
+		Limit   int           `mapstructure:"limit"`
+	}
+
+	err := DecodeOptions(map[string]any{
+		"paths":   []any{"/", "/var"},
+		"timeout": "5s",
+		"limit":   "3",
+	}, &options)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"/", "/var"}, options.Paths)
+	assert.Equal(t, 5*time.Second, options.Timeout)
+	assert.Equal(t, 3, options.Limit)
+}
@@ -0,0 +1,155 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/disk"
+
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+// DiskCollectorName - имя сборщика метрик дисков
+const DiskCollectorName = "disk"
+
+const (
+	// diskMountpointLabel - метка с точкой монтирования
+	diskMountpointLabel = "mountpoint"
+	// diskDeviceLabel - метка с именем блочного устройства
+	diskDeviceLabel = "device"
+)
+
+// diskOptions - параметры сборщика метрик дисков
+type diskOptions struct {
+	// AllPartitions включает виртуальные файловые системы (proc, sysfs и т.п.)
+	AllPartitions  bool     `mapstructure:"all_partitions"`
+	MountsInclude  []string `mapstructure:"mounts_include"`
+	MountsExclude  []string `mapstructure:"mounts_exclude"`
+	DevicesInclude []string `mapstructure:"devices_include"`
+	DevicesExclude []string `mapstructure:"devices_exclude"`
+}
+
+// diskCollector собирает заполненность точек монтирования (gauge, метка mountpoint)
+// и счетчики ввода-вывода блочных устройств (counter, метка device).
+type diskCollector struct {
+	name          string
+	interval      time.Duration
+	allPartitions bool
+	mounts        nameFilter
+	devices       nameFilter
+	io            *labeledDeltaTracker
+}
+
+// NewDiskCollector создает сборщик метрик дисков.
+//
+// Опции:
+//   - all_partitions - учитывать все файловые системы, а не только физические
+//   - mounts_include, mounts_exclude - шаблоны точек монтирования
+//   - devices_include, devices_exclude - шаблоны имен устройств (sda, nvme0n1 и т.п.)
+func NewDiskCollector(settings Settings) (Collector, error) {
+	var options diskOptions
+	if err := DecodeOptions(settings.Options, &options); err != nil {
+		return nil, err
+	}
+
+	mounts, err := newNameFilter(options.MountsInclude, options.MountsExclude)
+	if err != nil {
+		return nil, err
+	}
+	devices, err := newNameFilter(options.DevicesInclude, options.DevicesExclude)
+	if err != nil {
+		return nil, err
+	}
+
+	return &diskCollector{
+		name:          settings.Name,
+		interval:      settings.Interval,
+		allPartitions: options.AllPartitions,
+		mounts:        mounts,
+		devices:       devices,
+		io:            newLabeledDeltaTracker(diskDeviceLabel),
+	}, nil
+}
+
+func (c *diskCollector) Name() string {
+	return c.name
+}
+
+func (c *diskCollector) Interval() time.Duration {
+	return c.interval
+}
+
+func (c *diskCollector) Collect(ctx context.Context) ([]*metrics.Metric, error) {
+
+	result, usageErr := c.collectUsage(ctx)
+
+	counters, ioErr := c.collectIO(ctx)
+	result = append(result, counters...)
+
+	return result, errors.Join(usageErr, ioErr)
+}
+
+func (c *diskCollector) collectUsage(ctx context.Context) ([]*metrics.Metric, error) {
+	partitions, err := disk.PartitionsWithContext(ctx, c.allPartitions)
+	if err != nil {
+		return nil, fmt.Errorf("error getting disk partitions: %w", err)
+	}
+
+	result := []*metrics.Metric{}
+	errs := []error{}
+	seen := map[string]struct{}{}
+
+	for _, partition := range partitions {
+		mountpoint := partition.Mountpoint
+		if _, ok := seen[mountpoint]; ok || !c.mounts.match(mountpoint) {
+			continue
+		}
+		seen[mountpoint] = struct{}{}
+
+		usage, err := disk.UsageWithContext(ctx, mountpoint)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error getting disk usage of %s: %w", mountpoint, err))
+			continue
+		}
+
+		usageMetrics, err := gaugeMetrics([]gaugeValue{
+			{"DiskTotal", float64(usage.Total)},
+			{"DiskUsed", float64(usage.Used)},
+			{"DiskFree", float64(usage.Free)},
+			{"DiskUsedPercent", usage.UsedPercent},
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, metric := range usageMetrics {
+			metric.Labels = map[string]string{diskMountpointLabel: mountpoint}
+		}
+		result = append(result, usageMetrics...)
+	}
+
+	return result, errors.Join(errs...)
+}
+
+func (c *diskCollector) collectIO(ctx context.Context) ([]*metrics.Metric, error) {
+	ioCounters, err := disk.IOCountersWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting disk io counters: %w", err)
+	}
+
+	current := map[string]map[string]uint64{}
+	for device, stat := range ioCounters {
+		if !c.devices.match(device) {
+			continue
+		}
+		current[device] = map[string]uint64{
+			"DiskReadBytes":  stat.ReadBytes,
+			"DiskWriteBytes": stat.WriteBytes,
+			"DiskReadCount":  stat.ReadCount,
+			"DiskWriteCount": stat.WriteCount,
+		}
+	}
+
+	return c.io.metrics(current)
+}
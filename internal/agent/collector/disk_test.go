@@ -0,0 +1,47 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+func TestDiskCollector(t *testing.T) {
+	c, err := NewDiskCollector(Settings{
+		Name:    DiskCollectorName,
+		Options: map[string]any{"mounts_include": []any{"/"}},
+	})
+	require.NoError(t, err)
+
+	collected, err := c.Collect(context.Background())
+	require.NoError(t, err)
+
+	gauges := map[string]*metrics.Metric{}
+	for _, metric := range collected {
+		require.Equal(t, metrics.Gauge, metric.MType, "первый сбор только запоминает счетчики ввода-вывода")
+		gauges[metric.Key()] = metric
+	}
+	require.Contains(t, gauges, `DiskTotal{mountpoint="/"}`, "точка монтирования передается меткой")
+	assert.Contains(t, gauges, `DiskUsedPercent{mountpoint="/"}`)
+	assert.Positive(t, *gauges[`DiskTotal{mountpoint="/"}`].Value)
+
+	collected, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	for _, metric := range collected {
+		if metric.MType == metrics.Counter {
+			assert.NotEmpty(t, metric.Labels[diskDeviceLabel], "устройство передается меткой")
+		}
+	}
+}
+
+func TestNewDiskCollector_InvalidOptions(t *testing.T) {
+	_, err := NewDiskCollector(Settings{
+		Name:    DiskCollectorName,
+		Options: map[string]any{"devices_include": []any{"[sd"}},
+	})
+	assert.Error(t, err)
+}
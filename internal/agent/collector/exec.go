@@ -0,0 +1,431 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/galogen13/yandex-go-metrics/internal/logger"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+// ExecCollectorName - имя сборщика, запускающего внешние команды
+const ExecCollectorName = "exec"
+
+// Форматы вывода команд
+const (
+	ExecFormatText = "text" // строки вида "<type> <id> <value>"
+	ExecFormatJSON = "json" // массив метрик в формате metrics.Metric
+)
+
+const (
+	// execWaitDelay - время ожидания закрытия вывода после завершения команды по таймауту
+	execWaitDelay = time.Second
+	// execMaxStderr - сколько байт stderr команды попадает в лог
+	execMaxStderr = 512
+)
+
+// execOptions - параметры сборщика exec
+type execOptions struct {
+	Commands []execCommandOptions `mapstructure:"commands"`
+}
+
+// execCommandOptions - описание запускаемой команды
+type execCommandOptions struct {
+	Name     string        `mapstructure:"name"`     // имя, под которым команда попадает в идентификаторы собственных метрик
+	Command  []string      `mapstructure:"command"`  // программа и ее аргументы; оболочка не используется
+	Format   string        `mapstructure:"format"`   // формат вывода: text (по умолчанию) или json
+	Interval time.Duration `mapstructure:"interval"` // интервал между запусками; по умолчанию - интервал сборщика
+	Timeout  time.Duration `mapstructure:"timeout"`  // максимальное время работы; по умолчанию равно интервалу
+}
+
+type execCommand struct {
+	name     string
+	id       string // часть идентификаторов собственных метрик команды
+	args     []string
+	format   string
+	interval time.Duration
+	timeout  time.Duration
+}
+
+// execResult - результат последнего запуска команды
+type execResult struct {
+	gauges   []*metrics.Metric
+	success  bool
+	exitCode int
+	duration time.Duration
+}
+
+// execCollector периодически запускает внешние команды и превращает их вывод в метрики.
+// Каждая команда запускается в своей горутине со своим интервалом и таймаутом,
+// сборщик отдает последние значения gauge и накопленные с прошлого сбора дельты counter.
+//
+// Формат text - по одной метрике в строке: "gauge Temperature 36.6", "counter Errors 3".
+// Пустые строки и строки, начинающиеся с #, пропускаются. Формат json - массив метрик
+// (или одна метрика) в формате metrics.Metric, метки метрик сохраняются.
+//
+// Для каждой команды отдаются собственные метрики:
+//   - ExecSuccess<Name> (gauge) - 1, если последний запуск завершился успешно, иначе 0;
+//   - ExecExitCode<Name> (gauge) - код завершения последнего запуска (-1, если команда не запустилась или прервана);
+//   - ExecDuration<Name> (gauge) - длительность последнего запуска в секундах;
+//   - ExecFailures<Name>, ExecTimeouts<Name>, ExecParseErrors<Name> (counter) - неудачные запуски,
+//     запуски, прерванные по таймауту, и строки вывода, которые не удалось разобрать.
+//
+// Запуск считается неудачным, если команда не запустилась, завершилась с ненулевым кодом
+// или по таймауту. Вывод команды, завершившейся с ненулевым кодом, все равно разбирается.
+type execCollector struct {
+	name     string
+	interval time.Duration
+	commands []execCommand
+
+	mux      sync.Mutex
+	results  map[string]execResult // ключ - имя команды
+	counters counterSet
+}
+
+// NewExecCollector создает сборщик, запускающий внешние команды.
+//
+// Опции:
+//   - commands - список команд с полями name, command, format, interval, timeout
+func NewExecCollector(settings Settings) (Collector, error) {
+	var options execOptions
+	if err := DecodeOptions(settings.Options, &options); err != nil {
+		return nil, err
+	}
+
+	commands := make([]execCommand, 0, len(options.Commands))
+	names := map[string]bool{}
+	for _, commandOptions := range options.Commands {
+		command, err := newExecCommand(commandOptions, settings.Interval)
+		if err != nil {
+			return nil, err
+		}
+		if names[command.id] {
+			return nil, fmt.Errorf("duplicate exec command name: %s", command.name)
+		}
+		names[command.id] = true
+		commands = append(commands, command)
+	}
+
+	return &execCollector{
+		name:     settings.Name,
+		interval: settings.Interval,
+		commands: commands,
+		results:  map[string]execResult{},
+		counters: counterSet{},
+	}, nil
+}
+
+func newExecCommand(options execCommandOptions, defaultInterval time.Duration) (execCommand, error) {
+	if options.Name == "" {
+		return execCommand{}, errors.New("exec command name is not filled")
+	}
+	if len(options.Command) == 0 || options.Command[0] == "" {
+		return execCommand{}, fmt.Errorf("exec command %s: command is not filled", options.Name)
+	}
+
+	format := strings.ToLower(options.Format)
+	switch format {
+	case "":
+		format = ExecFormatText
+	case ExecFormatText, ExecFormatJSON:
+	default:
+		return execCommand{}, fmt.Errorf("exec command %s: unknown format %s", options.Name, options.Format)
+	}
+
+	command := execCommand{
+		name:     options.Name,
+		id:       MetricID("", options.Name),
+		args:     options.Command,
+		format:   format,
+		interval: options.Interval,
+		timeout:  options.Timeout,
+	}
+	if command.interval <= 0 {
+		command.interval = defaultInterval
+	}
+	if command.interval <= 0 {
+		return execCommand{}, fmt.Errorf("exec command %s: interval is not filled", options.Name)
+	}
+	if command.timeout <= 0 {
+		command.timeout = command.interval
+	}
+
+	return command, nil
+}
+
+func (c *execCollector) Name() string {
+	return c.name
+}
+
+func (c *execCollector) Interval() time.Duration {
+	return c.interval
+}
+
+// Run запускает команды по их интервалам до отмены контекста.
+func (c *execCollector) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, command := range c.commands {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.schedule(ctx, command)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+func (c *execCollector) schedule(ctx context.Context, command execCommand) {
+	ticker := time.NewTicker(command.interval)
+	defer ticker.Stop()
+
+	for {
+		c.runCommand(ctx, command)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runCommand запускает команду один раз и запоминает результат
+func (c *execCollector) runCommand(ctx context.Context, command execCommand) {
+	cmdCtx, cancel := context.WithTimeout(ctx, command.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(cmdCtx, command.args[0], command.args[1:]...)
+	cmd.WaitDelay = execWaitDelay
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	output, err := cmd.Output()
+	duration := time.Since(start)
+
+	if ctx.Err() != nil {
+		// агент останавливается - прерванный запуск не учитывается
+		return
+	}
+
+	result := execResult{success: err == nil, exitCode: -1, duration: duration}
+	counters := counterSet{}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		result.exitCode = 0
+	case errors.Is(cmdCtx.Err(), context.DeadlineExceeded):
+		counters.add(MetricID("ExecTimeouts", command.id), nil, 1)
+		logger.Log.Warn("exec command timed out", zap.String("command", command.name), zap.Duration("timeout", command.timeout))
+	case errors.As(err, &exitErr):
+		result.exitCode = exitErr.ExitCode()
+		logger.Log.Warn("exec command failed",
+			zap.String("command", command.name),
+			zap.Int("exit code", result.exitCode),
+			zap.String("stderr", truncate(stderr.String(), execMaxStderr)))
+	default:
+		logger.Log.Error("cannot run exec command", zap.String("command", command.name), zap.Error(err))
+	}
+
+	if !result.success {
+		counters.add(MetricID("ExecFailures", command.id), nil, 1)
+	}
+
+	if result.exitCode >= 0 {
+		collected, parseErrs := parseExecOutput(command.format, output)
+		for _, parseErr := range parseErrs {
+			logger.Log.Warn("cannot parse exec command output", zap.String("command", command.name), zap.Error(parseErr))
+		}
+		if len(parseErrs) > 0 {
+			counters.add(MetricID("ExecParseErrors", command.id), nil, int64(len(parseErrs)))
+		}
+
+		for _, metric := range collected {
+			if metric.MType == metrics.Counter {
+				counters.add(metric.ID, metric.Labels, *metric.Delta)
+				continue
+			}
+			result.gauges = append(result.gauges, metric)
+		}
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.results[command.name] = result
+	for _, counter := range counters {
+		c.counters.add(counter.ID, counter.Labels, *counter.Delta)
+	}
+}
+
+// Collect отдает результаты последних запусков команд и дельты счетчиков с прошлого сбора.
+func (c *execCollector) Collect(_ context.Context) ([]*metrics.Metric, error) {
+	c.mux.Lock()
+	results := maps.Clone(c.results)
+	counters := c.counters
+	c.counters = counterSet{}
+	c.mux.Unlock()
+
+	result := []*metrics.Metric{}
+	errs := []error{}
+
+	for _, command := range c.commands {
+		commandResult, ok := results[command.name]
+		if !ok {
+			continue
+		}
+		result = append(result, commandResult.gauges...)
+
+		success := 0.0
+		if commandResult.success {
+			success = 1
+		}
+		state, err := gaugeMetrics([]gaugeValue{
+			{prefix: "ExecSuccess", value: success},
+			{prefix: "ExecExitCode", value: float64(commandResult.exitCode)},
+			{prefix: "ExecDuration", value: commandResult.duration.Seconds()},
+		}, command.id)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		result = append(result, state...)
+	}
+
+	result = append(result, counters.metrics()...)
+
+	return result, errors.Join(errs...)
+}
+
+// parseExecOutput разбирает вывод команды в заданном формате.
+// Возвращает разобранные метрики и ошибки по строкам (метрикам), которые не удалось разобрать.
+func parseExecOutput(format string, output []byte) ([]*metrics.Metric, []error) {
+	if format == ExecFormatJSON {
+		return parseMetricsJSON(output)
+	}
+	return parseMetricsText(output)
+}
+
+// parseMetricsText разбирает метрики в формате "<type> <id> <value>", по одной в строке
+func parseMetricsText(data []byte) ([]*metrics.Metric, []error) {
+	result := []*metrics.Metric{}
+	errs := []error{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		metric, err := parseMetricLine(line)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", lineNum, err))
+			continue
+		}
+		result = append(result, metric)
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return result, errs
+}
+
+func parseMetricLine(line string) (*metrics.Metric, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("expected \"<type> <id> <value>\", got %q", line)
+	}
+
+	metric := metrics.NewMetrics(fields[1], metrics.MetricType(strings.ToLower(fields[0])))
+	switch metric.MType {
+	case metrics.Gauge:
+		value, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gauge value %q: %w", fields[2], err)
+		}
+		metric.UpdateValue(value)
+	case metrics.Counter:
+		delta, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid counter value %q: %w", fields[2], err)
+		}
+		metric.UpdateValue(delta)
+	}
+
+	if err := metric.Check(true); err != nil {
+		return nil, err
+	}
+	return metric, nil
+}
+
+// parseMetricsJSON разбирает массив метрик (или одну метрику) в формате metrics.Metric
+func parseMetricsJSON(data []byte) ([]*metrics.Metric, []error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return []*metrics.Metric{}, nil
+	}
+
+	parsed := []*metrics.Metric{}
+	if data[0] == '{' {
+		metric := &metrics.Metric{}
+		if err := json.Unmarshal(data, metric); err != nil {
+			return nil, []error{fmt.Errorf("invalid JSON: %w", err)}
+		}
+		parsed = append(parsed, metric)
+	} else if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, []error{fmt.Errorf("invalid JSON: %w", err)}
+	}
+
+	result := make([]*metrics.Metric, 0, len(parsed))
+	errs := []error{}
+	for i, metric := range parsed {
+		if metric == nil {
+			errs = append(errs, fmt.Errorf("metric %d: null", i))
+			continue
+		}
+		if err := metric.Check(true); err != nil {
+			errs = append(errs, fmt.Errorf("metric %d: %w", i, err))
+			continue
+		}
+
+		// метрика пересоздается, чтобы заполнить строковое представление значения
+		var (
+			normalized *metrics.Metric
+			err        error
+		)
+		if metric.MType == metrics.Gauge {
+			normalized, err = NewGaugeMetric(metric.ID, *metric.Value)
+		} else {
+			normalized, err = NewCounterMetric(metric.ID, *metric.Delta)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("metric %d: %w", i, err))
+			continue
+		}
+		normalized.Labels = metric.Labels
+		result = append(result, normalized)
+	}
+	return result, errs
+}
+
+func truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	return s[:limit] + "..."
+}
@@ -0,0 +1,163 @@
+package collector
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+func TestParseMetricsText(t *testing.T) {
+	output := []byte(`# результаты проверки
+gauge Temperature 36.6
+COUNTER Errors 3
+
+gauge Broken
+counter Errors 1.5
+gauge 1Bad 1
+`)
+
+	collected, errs := parseMetricsText(output)
+	assert.Len(t, errs, 3)
+	require.Len(t, collected, 2)
+
+	assert.Equal(t, "Temperature", collected[0].ID)
+	assert.Equal(t, 36.6, *collected[0].Value)
+	assert.Equal(t, metrics.Counter, collected[1].MType)
+	assert.Equal(t, int64(3), *collected[1].Delta)
+	assert.Equal(t, "3", collected[1].ValueStr)
+}
+
+func TestParseMetricsJSON(t *testing.T) {
+	collected, errs := parseMetricsJSON([]byte(`[
+		{"id": "Queue", "type": "gauge", "value": 5, "labels": {"queue": "mail"}},
+		{"id": "Sent", "type": "counter", "delta": 2},
+		{"id": "Bad", "type": "gauge"}
+	]`))
+	assert.Len(t, errs, 1)
+	require.Len(t, collected, 2)
+	assert.Equal(t, map[string]string{"queue": "mail"}, collected[0].Labels)
+	assert.Equal(t, "5", collected[0].ValueStr)
+	assert.Equal(t, int64(2), *collected[1].Delta)
+
+	collected, errs = parseMetricsJSON([]byte(`{"id": "Queue", "type": "gauge", "value": 1}`))
+	assert.Empty(t, errs)
+	assert.Len(t, collected, 1)
+
+	_, errs = parseMetricsJSON([]byte(`not json`))
+	assert.Len(t, errs, 1)
+}
+
+func TestExecCollector(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	c, err := NewExecCollector(Settings{
+		Name:     ExecCollectorName,
+		Interval: time.Minute,
+		Options: map[string]any{
+			"commands": []any{
+				map[string]any{"name": "ok", "command": []any{"sh", "-c", "echo gauge Temperature 36.6; echo counter Checks 1"}},
+				map[string]any{"name": "fail", "command": []any{"sh", "-c", "echo gauge Partial 1; exit 3"}},
+				map[string]any{"name": "slow", "command": []any{"sleep", "5"}, "timeout": "100ms"},
+				map[string]any{"name": "missing", "command": []any{"/nonexistent/command"}},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	execC := c.(*execCollector)
+	ctx := context.Background()
+	for _, command := range execC.commands {
+		execC.runCommand(ctx, command)
+	}
+	// повторный запуск накапливает дельты счетчиков
+	execC.runCommand(ctx, execC.commands[0])
+
+	got := collectByID(t, c)
+
+	assert.Equal(t, 36.6, *got["Temperature"].Value)
+	assert.Equal(t, int64(2), *got["Checks"].Delta)
+	assert.Equal(t, 1.0, *got["ExecSuccessOk"].Value)
+	assert.Equal(t, 0.0, *got["ExecExitCodeOk"].Value)
+
+	assert.Equal(t, 1.0, *got["Partial"].Value, "вывод команды с ненулевым кодом разбирается")
+	assert.Equal(t, 0.0, *got["ExecSuccessFail"].Value)
+	assert.Equal(t, 3.0, *got["ExecExitCodeFail"].Value)
+	assert.Equal(t, int64(1), *got["ExecFailuresFail"].Delta)
+
+	assert.Equal(t, int64(1), *got["ExecTimeoutsSlow"].Delta)
+	assert.Equal(t, int64(1), *got["ExecFailuresSlow"].Delta)
+	assert.Equal(t, -1.0, *got["ExecExitCodeSlow"].Value)
+	assert.Less(t, *got["ExecDurationSlow"].Value, 5.0)
+
+	assert.Equal(t, int64(1), *got["ExecFailuresMissing"].Delta)
+	assert.Equal(t, -1.0, *got["ExecExitCodeMissing"].Value)
+
+	// дельты отдаются один раз
+	got = collectByID(t, c)
+	assert.NotContains(t, got, "Checks")
+	assert.Contains(t, got, "Temperature")
+}
+
+func TestExecCollector_CounterLabels(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	output := `[{"id": "Requests", "type": "counter", "delta": 2, "labels": {"code": "200"}},` +
+		`{"id": "Requests", "type": "counter", "delta": 3, "labels": {"code": "500"}}]`
+	c, err := NewExecCollector(Settings{
+		Name:     ExecCollectorName,
+		Interval: time.Minute,
+		Options: map[string]any{
+			"commands": []any{
+				map[string]any{"name": "requests", "format": "json", "command": []any{"echo", output}},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	execC := c.(*execCollector)
+	execC.runCommand(context.Background(), execC.commands[0])
+	execC.runCommand(context.Background(), execC.commands[0])
+
+	collected, err := c.Collect(context.Background())
+	require.NoError(t, err)
+
+	deltas := map[string]int64{}
+	for _, metric := range collected {
+		if metric.ID == "Requests" {
+			deltas[metric.Key()] = *metric.Delta
+		}
+	}
+	assert.Equal(t, map[string]int64{`Requests{code="200"}`: 4, `Requests{code="500"}`: 6}, deltas,
+		"счетчики с разными метками накапливаются раздельно")
+}
+
+func TestNewExecCollector_Validation(t *testing.T) {
+	for name, options := range map[string]map[string]any{
+		"нет имени":          {"name": "", "command": []any{"true"}},
+		"нет команды":        {"name": "check"},
+		"неизвестный формат": {"name": "check", "command": []any{"true"}, "format": "xml"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewExecCollector(Settings{Name: ExecCollectorName, Interval: time.Second,
+				Options: map[string]any{"commands": []any{options}}})
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := NewExecCollector(Settings{Name: ExecCollectorName, Interval: time.Second,
+		Options: map[string]any{"commands": []any{
+			map[string]any{"name": "check", "command": []any{"true"}},
+			map[string]any{"name": "Check", "command": []any{"true"}},
+		}}})
+	assert.Error(t, err, "имена команд дают одинаковые идентификаторы")
+}
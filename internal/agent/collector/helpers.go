@@ -0,0 +1,202 @@
+package collector
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+// MetricID составляет идентификатор метрики из префикса и произвольных частей имени
+// (точки монтирования, имени устройства или интерфейса).
+// Символы, недопустимые в идентификаторе, отбрасываются, а слова между ними
+// пишутся с заглавной буквы: ("DiskFree", "/var/lib") -> "DiskFreeVarLib".
+// Часть, не содержащая допустимых символов (например, "/"), заменяется на "Root".
+// Разные части могут дать один идентификатор ("/var/lib" и "/var-lib"), поэтому значения,
+// которые нужно различать (точки монтирования, устройства), передаются в метках.
+func MetricID(prefix string, parts ...string) string {
+	var b strings.Builder
+	b.WriteString(prefix)
+
+	for _, part := range parts {
+		words := strings.FieldsFunc(part, func(r rune) bool {
+			return r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r))
+		})
+		if len(words) == 0 {
+			b.WriteString("Root")
+			continue
+		}
+		for _, word := range words {
+			b.WriteString(strings.ToUpper(word[:1]))
+			b.WriteString(word[1:])
+		}
+	}
+
+	return b.String()
+}
+
+// nameFilter отбирает имена по спискам шаблонов filepath.Match.
+// Пустой список include пропускает все имена, совпадение с exclude имеет приоритет.
+type nameFilter struct {
+	include []string
+	exclude []string
+}
+
+func newNameFilter(include, exclude []string) (nameFilter, error) {
+	for _, pattern := range append(append([]string{}, include...), exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nameFilter{}, fmt.Errorf("invalid filter pattern %q: %w", pattern, err)
+		}
+	}
+	return nameFilter{include: include, exclude: exclude}, nil
+}
+
+func (f nameFilter) match(name string) bool {
+	if matchAny(f.exclude, name) {
+		return false
+	}
+	return len(f.include) == 0 || matchAny(f.include, name)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// deltaTracker превращает накопительные счетчики ядра в дельты для метрик типа counter.
+type deltaTracker struct {
+	previous map[string]uint64
+}
+
+func newDeltaTracker() *deltaTracker {
+	return &deltaTracker{previous: map[string]uint64{}}
+}
+
+// deltas возвращает приращения текущих значений относительно предыдущего вызова.
+// Для счетчика, увиденного впервые, дельта не возвращается. Если значение уменьшилось
+// (счетчик сброшен, например, при пересоздании устройства), дельтой считается текущее значение.
+// Счетчики, отсутствующие в current, забываются.
+func (t *deltaTracker) deltas(current map[string]uint64) map[string]int64 {
+	result := make(map[string]int64, len(current))
+
+	for key, value := range current {
+		prev, ok := t.previous[key]
+		if !ok {
+			continue
+		}
+		if value < prev {
+			result[key] = int64(value)
+			continue
+		}
+		result[key] = int64(value - prev)
+	}
+
+	t.previous = current
+	return result
+}
+
+// labeledDeltaTracker превращает накопительные счетчики объектов (устройств, интерфейсов)
+// в дельты метрик типа counter с меткой label, значение которой - имя объекта.
+type labeledDeltaTracker struct {
+	label    string
+	trackers map[string]*deltaTracker
+}
+
+func newLabeledDeltaTracker(label string) *labeledDeltaTracker {
+	return &labeledDeltaTracker{label: label, trackers: map[string]*deltaTracker{}}
+}
+
+// metrics возвращает метрики с приращениями счетчиков current (имя объекта -> идентификатор -> значение),
+// упорядоченные по имени объекта и идентификатору. Объекты, отсутствующие в current, забываются.
+func (t *labeledDeltaTracker) metrics(current map[string]map[string]uint64) ([]*metrics.Metric, error) {
+	trackers := make(map[string]*deltaTracker, len(current))
+	result := []*metrics.Metric{}
+
+	for _, name := range slices.Sorted(maps.Keys(current)) {
+		tracker, ok := t.trackers[name]
+		if !ok {
+			tracker = newDeltaTracker()
+		}
+		trackers[name] = tracker
+
+		counters, err := counterMetrics(tracker.deltas(current[name]))
+		if err != nil {
+			return nil, err
+		}
+		for _, metric := range counters {
+			metric.Labels = map[string]string{t.label: name}
+		}
+		result = append(result, counters...)
+	}
+
+	t.trackers = trackers
+	return result, nil
+}
+
+// counterMetrics создает метрики типа counter из дельт, упорядоченные по идентификатору.
+func counterMetrics(deltas map[string]int64) ([]*metrics.Metric, error) {
+	result := make([]*metrics.Metric, 0, len(deltas))
+	for _, mID := range slices.Sorted(maps.Keys(deltas)) {
+		metric, err := NewCounterMetric(mID, deltas[mID])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, metric)
+	}
+	return result, nil
+}
+
+// counterSet накапливает дельты метрик типа counter, ключ - metrics.Metric.Key():
+// метрики с одним идентификатором и разными метками накапливаются раздельно.
+type counterSet map[string]*metrics.Metric
+
+// add прибавляет delta к метрике с идентификатором mID и метками labels
+func (s counterSet) add(mID string, labels map[string]string, delta int64) {
+	metric := metrics.NewMetrics(mID, metrics.Counter)
+	metric.Labels = labels
+	key := metric.Key()
+
+	if counter, ok := s[key]; ok {
+		counter.UpdateValue(delta)
+		return
+	}
+	metric.Labels = maps.Clone(labels)
+	metric.UpdateValue(delta)
+	s[key] = metric
+}
+
+// metrics возвращает накопленные метрики, упорядоченные по ключу
+func (s counterSet) metrics() []*metrics.Metric {
+	result := make([]*metrics.Metric, 0, len(s))
+	for _, key := range slices.Sorted(maps.Keys(s)) {
+		result = append(result, s[key])
+	}
+	return result
+}
+
+// gaugeValue - значение метрики типа gauge, идентификатор которой составляется функцией MetricID
+type gaugeValue struct {
+	prefix string
+	value  float64
+}
+
+// gaugeMetrics создает метрики типа gauge с идентификаторами MetricID(prefix, parts...).
+func gaugeMetrics(values []gaugeValue, parts ...string) ([]*metrics.Metric, error) {
+	result := make([]*metrics.Metric, 0, len(values))
+	for _, v := range values {
+		metric, err := NewGaugeMetric(MetricID(v.prefix, parts...), v.value)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, metric)
+	}
+	return result, nil
+}
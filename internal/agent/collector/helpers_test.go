@@ -0,0 +1,103 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricID(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		parts  []string
+		want   string
+	}{
+		{name: "Корневая точка монтирования", prefix: "DiskFree", parts: []string{"/"}, want: "DiskFreeRoot"},
+		{name: "Вложенная точка монтирования", prefix: "DiskFree", parts: []string{"/var/lib-docker"}, want: "DiskFreeVarLibDocker"},
+		{name: "Имя устройства", prefix: "DiskReadBytes", parts: []string{"nvme0n1"}, want: "DiskReadBytesNvme0n1"},
+		{name: "Недопустимые символы", prefix: "NetBytesSent", parts: []string{"вх.eth0"}, want: "NetBytesSentEth0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mID := MetricID(tt.prefix, tt.parts...)
+			assert.Equal(t, tt.want, mID)
+			assert.Regexp(t, `^[a-zA-Z][a-zA-Z0-9]*$`, mID)
+		})
+	}
+}
+
+func TestNameFilter(t *testing.T) {
+	filter, err := newNameFilter([]string{"eth*", "lo"}, []string{"eth9"})
+	require.NoError(t, err)
+
+	assert.True(t, filter.match("eth0"))
+	assert.True(t, filter.match("lo"))
+	assert.False(t, filter.match("eth9"))
+	assert.False(t, filter.match("docker0"))
+
+	all, err := newNameFilter(nil, []string{"loop*"})
+	require.NoError(t, err)
+	assert.True(t, all.match("sda"))
+	assert.False(t, all.match("loop0"))
+
+	_, err = newNameFilter([]string{"[sd"}, nil)
+	assert.Error(t, err)
+}
+
+func TestDeltaTracker(t *testing.T) {
+	tracker := newDeltaTracker()
+
+	// первый сбор только запоминает значения
+	assert.Empty(t, tracker.deltas(map[string]uint64{"a": 100, "b": 50}))
+
+	assert.Equal(t, map[string]int64{"a": 20, "b": 0},
+		tracker.deltas(map[string]uint64{"a": 120, "b": 50}))
+
+	// счетчик b сброшен, появился новый счетчик c
+	assert.Equal(t, map[string]int64{"a": 5, "b": 7},
+		tracker.deltas(map[string]uint64{"a": 125, "b": 7, "c": 1}))
+
+	// счетчик a пропал и при повторном появлении считается новым
+	tracker.deltas(map[string]uint64{"b": 7, "c": 1})
+	assert.Equal(t, map[string]int64{"b": 0, "c": 1},
+		tracker.deltas(map[string]uint64{"a": 500, "b": 7, "c": 2}))
+}
+
+func TestLabeledDeltaTracker(t *testing.T) {
+	tracker := newLabeledDeltaTracker("mountpoint")
+
+	deltas := func(current map[string]map[string]uint64) map[string]int64 {
+		collected, err := tracker.metrics(current)
+		require.NoError(t, err)
+		result := map[string]int64{}
+		for _, metric := range collected {
+			result[metric.Key()] = *metric.Delta
+		}
+		return result
+	}
+
+	assert.Empty(t, deltas(map[string]map[string]uint64{
+		"/var/lib": {"DiskReadBytes": 100},
+		"/var-lib": {"DiskReadBytes": 10},
+	}))
+
+	// объекты, имена которых дают один идентификатор MetricID, не смешиваются
+	assert.Equal(t, map[string]int64{
+		`DiskReadBytes{mountpoint="/var/lib"}`: 5,
+		`DiskReadBytes{mountpoint="/var-lib"}`: 1,
+	}, deltas(map[string]map[string]uint64{
+		"/var/lib": {"DiskReadBytes": 105},
+		"/var-lib": {"DiskReadBytes": 11},
+	}))
+
+	// пропавший объект забывается
+	deltas(map[string]map[string]uint64{"/var/lib": {"DiskReadBytes": 105}})
+	assert.Equal(t, map[string]int64{`DiskReadBytes{mountpoint="/var/lib"}`: 0},
+		deltas(map[string]map[string]uint64{
+			"/var/lib": {"DiskReadBytes": 105},
+			"/var-lib": {"DiskReadBytes": 50},
+		}))
+}
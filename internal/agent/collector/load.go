@@ -0,0 +1,61 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/load"
+
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+// LoadCollectorName - имя сборщика средней загрузки системы
+const LoadCollectorName = "load"
+
+// loadCollector собирает среднюю загрузку системы за 1, 5 и 15 минут.
+type loadCollector struct {
+	name     string
+	interval time.Duration
+}
+
+// NewLoadCollector создает сборщик средней загрузки системы.
+func NewLoadCollector(settings Settings) (Collector, error) {
+	return &loadCollector{name: settings.Name, interval: settings.Interval}, nil
+}
+
+func (c *loadCollector) Name() string {
+	return c.name
+}
+
+func (c *loadCollector) Interval() time.Duration {
+	return c.interval
+}
+
+func (c *loadCollector) Collect(ctx context.Context) ([]*metrics.Metric, error) {
+
+	avg, err := load.AvgWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting load average: %w", err)
+	}
+
+	values := []struct {
+		mID   string
+		value float64
+	}{
+		{"Load1", avg.Load1},
+		{"Load5", avg.Load5},
+		{"Load15", avg.Load15},
+	}
+
+	result := make([]*metrics.Metric, 0, len(values))
+	for _, v := range values {
+		metric, err := NewGaugeMetric(v.mID, v.value)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, metric)
+	}
+
+	return result, nil
+}
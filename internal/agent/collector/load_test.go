@@ -0,0 +1,24 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadCollector(t *testing.T) {
+	c, err := NewLoadCollector(Settings{Name: LoadCollectorName})
+	require.NoError(t, err)
+
+	collected, err := c.Collect(context.Background())
+	require.NoError(t, err)
+
+	ids := []string{}
+	for _, metric := range collected {
+		assert.GreaterOrEqual(t, *metric.Value, 0.0)
+		ids = append(ids, metric.ID)
+	}
+	assert.Equal(t, []string{"Load1", "Load5", "Load15"}, ids)
+}
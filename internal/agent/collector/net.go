@@ -0,0 +1,87 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/net"
+
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+// NetCollectorName - имя сборщика сетевых метрик
+const NetCollectorName = "net"
+
+// netInterfaceLabel - метка с именем сетевого интерфейса
+const netInterfaceLabel = "interface"
+
+// netOptions - параметры сборщика сетевых метрик
+type netOptions struct {
+	InterfacesInclude []string `mapstructure:"interfaces_include"`
+	InterfacesExclude []string `mapstructure:"interfaces_exclude"`
+}
+
+// netCollector собирает счетчики байтов, пакетов и ошибок сетевых интерфейсов (counter, метка interface).
+type netCollector struct {
+	name       string
+	interval   time.Duration
+	interfaces nameFilter
+	io         *labeledDeltaTracker
+}
+
+// NewNetCollector создает сборщик сетевых метрик.
+//
+// Опции:
+//   - interfaces_include, interfaces_exclude - шаблоны имен интерфейсов (eth*, lo и т.п.)
+func NewNetCollector(settings Settings) (Collector, error) {
+	var options netOptions
+	if err := DecodeOptions(settings.Options, &options); err != nil {
+		return nil, err
+	}
+
+	interfaces, err := newNameFilter(options.InterfacesInclude, options.InterfacesExclude)
+	if err != nil {
+		return nil, err
+	}
+
+	return &netCollector{
+		name:       settings.Name,
+		interval:   settings.Interval,
+		interfaces: interfaces,
+		io:         newLabeledDeltaTracker(netInterfaceLabel),
+	}, nil
+}
+
+func (c *netCollector) Name() string {
+	return c.name
+}
+
+func (c *netCollector) Interval() time.Duration {
+	return c.interval
+}
+
+func (c *netCollector) Collect(ctx context.Context) ([]*metrics.Metric, error) {
+
+	ioCounters, err := net.IOCountersWithContext(ctx, true)
+	if err != nil {
+		return nil, fmt.Errorf("error getting network io counters: %w", err)
+	}
+
+	current := map[string]map[string]uint64{}
+	for _, stat := range ioCounters {
+		if !c.interfaces.match(stat.Name) {
+			continue
+		}
+		current[stat.Name] = map[string]uint64{
+			"NetBytesSent":   stat.BytesSent,
+			"NetBytesRecv":   stat.BytesRecv,
+			"NetPacketsSent": stat.PacketsSent,
+			"NetPacketsRecv": stat.PacketsRecv,
+			"NetErrin":       stat.Errin,
+			"NetErrout":      stat.Errout,
+		}
+	}
+
+	return c.io.metrics(current)
+}
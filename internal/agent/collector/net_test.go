@@ -0,0 +1,34 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+func TestNetCollector(t *testing.T) {
+	c, err := NewNetCollector(Settings{
+		Name:    NetCollectorName,
+		Options: map[string]any{"interfaces_include": []any{"lo"}},
+	})
+	require.NoError(t, err)
+
+	collected, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, collected, "первый сбор только запоминает счетчики")
+
+	collected, err = c.Collect(context.Background())
+	require.NoError(t, err)
+
+	keys := []string{}
+	for _, metric := range collected {
+		assert.Equal(t, metrics.Counter, metric.MType)
+		keys = append(keys, metric.Key())
+	}
+	assert.Contains(t, keys, `NetBytesSent{interface="lo"}`, "интерфейс передается меткой")
+	assert.Contains(t, keys, `NetErrout{interface="lo"}`)
+}
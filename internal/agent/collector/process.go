@@ -0,0 +1,299 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/process"
+
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+// ProcessCollectorName - имя сборщика метрик отдельных процессов
+const ProcessCollectorName = "process"
+
+// processOptions - параметры сборщика метрик процессов
+type processOptions struct {
+	Processes []processTargetOptions `mapstructure:"processes"`
+}
+
+// processTargetOptions - описание отслеживаемого процесса.
+// Должен быть задан ровно один из способов поиска: pid_file, process_name или cmdline.
+type processTargetOptions struct {
+	Name        string `mapstructure:"name"`         // имя, под которым процесс попадает в идентификаторы метрик
+	PIDFile     string `mapstructure:"pid_file"`     // путь к файлу с PID процесса
+	ProcessName string `mapstructure:"process_name"` // точное имя процесса
+	Cmdline     string `mapstructure:"cmdline"`      // регулярное выражение для командной строки процесса
+}
+
+type processTarget struct {
+	name        string
+	pidFile     string
+	processName string
+	cmdline     *regexp.Regexp
+}
+
+// processKey идентифицирует процесс с учетом повторного использования PID
+type processKey struct {
+	pid        int32
+	createTime int64
+}
+
+// cpuSample - время процессора, затраченное процессом к моменту замера
+type cpuSample struct {
+	total float64
+	at    time.Time
+}
+
+// processCollector собирает для каждого отслеживаемого процесса загрузку CPU в процентах,
+// резидентную память, число открытых файловых дескрипторов, число потоков и время работы.
+// Если под описание подходит несколько процессов, значения суммируются,
+// а время работы берется по самому старому процессу.
+type processCollector struct {
+	name     string
+	interval time.Duration
+	targets  []processTarget
+	cpu      map[processKey]cpuSample
+}
+
+// NewProcessCollector создает сборщик метрик процессов.
+//
+// Опции:
+//   - processes - список процессов с полями name, pid_file, process_name, cmdline
+func NewProcessCollector(settings Settings) (Collector, error) {
+	var options processOptions
+	if err := DecodeOptions(settings.Options, &options); err != nil {
+		return nil, err
+	}
+
+	targets := make([]processTarget, 0, len(options.Processes))
+	for _, targetOptions := range options.Processes {
+		target, err := newProcessTarget(targetOptions)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+
+	return &processCollector{
+		name:     settings.Name,
+		interval: settings.Interval,
+		targets:  targets,
+		cpu:      map[processKey]cpuSample{},
+	}, nil
+}
+
+func newProcessTarget(options processTargetOptions) (processTarget, error) {
+	if options.Name == "" {
+		return processTarget{}, errors.New("process name is not filled")
+	}
+
+	selectors := 0
+	for _, selector := range []string{options.PIDFile, options.ProcessName, options.Cmdline} {
+		if selector != "" {
+			selectors++
+		}
+	}
+	if selectors != 1 {
+		return processTarget{}, fmt.Errorf("process %s: exactly one of pid_file, process_name, cmdline must be filled", options.Name)
+	}
+
+	target := processTarget{
+		name:        options.Name,
+		pidFile:     options.PIDFile,
+		processName: options.ProcessName,
+	}
+
+	if options.Cmdline != "" {
+		cmdline, err := regexp.Compile(options.Cmdline)
+		if err != nil {
+			return processTarget{}, fmt.Errorf("process %s: invalid cmdline regexp: %w", options.Name, err)
+		}
+		target.cmdline = cmdline
+	}
+
+	return target, nil
+}
+
+func (c *processCollector) Name() string {
+	return c.name
+}
+
+func (c *processCollector) Interval() time.Duration {
+	return c.interval
+}
+
+func (c *processCollector) Collect(ctx context.Context) ([]*metrics.Metric, error) {
+
+	var (
+		all     []*process.Process
+		listErr error
+		scanned bool
+	)
+
+	result := []*metrics.Metric{}
+	errs := []error{}
+	now := time.Now()
+	cpu := make(map[processKey]cpuSample, len(c.cpu))
+
+	for _, target := range c.targets {
+		var (
+			procs []*process.Process
+			err   error
+		)
+
+		if target.pidFile != "" {
+			procs, err = processFromPIDFile(ctx, target.pidFile)
+		} else {
+			if !scanned {
+				all, listErr = process.ProcessesWithContext(ctx)
+				if listErr != nil {
+					errs = append(errs, fmt.Errorf("error listing processes: %w", listErr))
+				}
+				scanned = true
+			}
+			if listErr != nil {
+				continue
+			}
+			procs = target.filter(ctx, all)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("process %s: %w", target.name, err))
+		}
+
+		targetMetrics, err := c.collectTarget(ctx, target, procs, now, cpu)
+		result = append(result, targetMetrics...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("process %s: %w", target.name, err))
+		}
+	}
+
+	// завершившиеся процессы забываются
+	c.cpu = cpu
+
+	return result, errors.Join(errs...)
+}
+
+func (c *processCollector) collectTarget(ctx context.Context, target processTarget, procs []*process.Process,
+	now time.Time, cpu map[processKey]cpuSample) ([]*metrics.Metric, error) {
+
+	var (
+		rss, fds, threads, cpuPercent float64
+		oldest                        int64
+		cpuKnown                      bool
+		// count - количество процессов, значения которых учтены в метриках
+		count int
+	)
+	errs := []error{}
+
+	for _, proc := range procs {
+		createTime, err := proc.CreateTimeWithContext(ctx)
+		if err != nil {
+			// процесс мог завершиться между поиском и замером: он не учитывается ни в одной метрике
+			continue
+		}
+		count++
+		if oldest == 0 || createTime < oldest {
+			oldest = createTime
+		}
+
+		if memInfo, err := proc.MemoryInfoWithContext(ctx); err == nil {
+			rss += float64(memInfo.RSS)
+		} else {
+			errs = append(errs, fmt.Errorf("error getting memory info of pid %d: %w", proc.Pid, err))
+		}
+
+		if numFDs, err := proc.NumFDsWithContext(ctx); err == nil {
+			fds += float64(numFDs)
+		} else {
+			errs = append(errs, fmt.Errorf("error getting open files of pid %d: %w", proc.Pid, err))
+		}
+
+		if numThreads, err := proc.NumThreadsWithContext(ctx); err == nil {
+			threads += float64(numThreads)
+		} else {
+			errs = append(errs, fmt.Errorf("error getting threads of pid %d: %w", proc.Pid, err))
+		}
+
+		times, err := proc.TimesWithContext(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error getting cpu times of pid %d: %w", proc.Pid, err))
+			continue
+		}
+		key := processKey{pid: proc.Pid, createTime: createTime}
+		sample := cpuSample{total: times.User + times.System, at: now}
+		if prev, ok := c.cpu[key]; ok && sample.at.After(prev.at) {
+			cpuPercent += 100 * (sample.total - prev.total) / sample.at.Sub(prev.at).Seconds()
+			cpuKnown = true
+		}
+		cpu[key] = sample
+	}
+
+	values := []gaugeValue{{"ProcessCount", 0}}
+	if oldest != 0 {
+		values = []gaugeValue{
+			{"ProcessCount", float64(count)},
+			{"ProcessRSS", rss},
+			{"ProcessOpenFDs", fds},
+			{"ProcessThreads", threads},
+			{"ProcessUptime", now.Sub(time.UnixMilli(oldest)).Seconds()},
+		}
+	}
+	if cpuKnown {
+		values = append(values, gaugeValue{"ProcessCPUPercent", cpuPercent})
+	}
+
+	result, err := gaugeMetrics(values, target.name)
+	return result, errors.Join(append(errs, err)...)
+}
+
+// filter отбирает процессы по точному имени или по командной строке
+func (t processTarget) filter(ctx context.Context, all []*process.Process) []*process.Process {
+	result := []*process.Process{}
+
+	for _, proc := range all {
+		if t.processName != "" {
+			name, err := proc.NameWithContext(ctx)
+			if err == nil && name == t.processName {
+				result = append(result, proc)
+			}
+			continue
+		}
+
+		cmdline, err := proc.CmdlineWithContext(ctx)
+		if err == nil && cmdline != "" && t.cmdline.MatchString(cmdline) {
+			result = append(result, proc)
+		}
+	}
+
+	return result
+}
+
+func processFromPIDFile(ctx context.Context, pidFile string) ([]*process.Process, error) {
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read pid file: %w", err)
+	}
+
+	pid, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pid file %s: %w", pidFile, err)
+	}
+
+	proc, err := process.NewProcessWithContext(ctx, int32(pid))
+	if errors.Is(err, process.ErrorProcessNotRunning) {
+		// процесс не запущен: метрики покажут ProcessCount = 0
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot open process %d: %w", pid, err)
+	}
+
+	return []*process.Process{proc}, nil
+}
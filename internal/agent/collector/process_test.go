@@ -0,0 +1,100 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/process"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+func TestNewProcessCollector_InvalidOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		process map[string]any
+	}{
+		{name: "Не задано имя", process: map[string]any{"pid_file": "/run/app.pid"}},
+		{name: "Не задан способ поиска", process: map[string]any{"name": "app"}},
+		{name: "Несколько способов поиска", process: map[string]any{"name": "app", "pid_file": "/run/app.pid", "process_name": "app"}},
+		{name: "Некорректное регулярное выражение", process: map[string]any{"name": "app", "cmdline": "("}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewProcessCollector(Settings{
+				Name:    ProcessCollectorName,
+				Options: map[string]any{"processes": []any{tt.process}},
+			})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestProcessCollector_PIDFile(t *testing.T) {
+	dir := t.TempDir()
+
+	pidFile := filepath.Join(dir, "self.pid")
+	require.NoError(t, os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0600))
+
+	c, err := NewProcessCollector(Settings{
+		Name: ProcessCollectorName,
+		Options: map[string]any{"processes": []any{
+			map[string]any{"name": "self", "pid_file": pidFile},
+			map[string]any{"name": "missing", "pid_file": filepath.Join(dir, "missing.pid")},
+		}},
+	})
+	require.NoError(t, err)
+
+	collectGauges := func() (map[string]float64, error) {
+		collected, err := c.Collect(context.Background())
+		gauges := map[string]float64{}
+		for _, metric := range collected {
+			require.Equal(t, metrics.Gauge, metric.MType)
+			gauges[metric.ID] = *metric.Value
+		}
+		return gauges, err
+	}
+
+	gauges, err := collectGauges()
+	assert.Error(t, err, "ошибка чтения отсутствующего pid-файла")
+	assert.Equal(t, 1.0, gauges["ProcessCountSelf"])
+	assert.Greater(t, gauges["ProcessRSSSelf"], 0.0)
+	assert.Greater(t, gauges["ProcessThreadsSelf"], 0.0)
+	assert.Contains(t, gauges, "ProcessUptimeSelf")
+	assert.NotContains(t, gauges, "ProcessCPUPercentSelf", "для загрузки CPU нужны два замера")
+	assert.Equal(t, 0.0, gauges["ProcessCountMissing"])
+
+	gauges, _ = collectGauges()
+	assert.Contains(t, gauges, "ProcessCPUPercentSelf")
+}
+
+func TestProcessCollector_CountsMeasuredProcesses(t *testing.T) {
+	c, err := NewProcessCollector(Settings{
+		Name:    ProcessCollectorName,
+		Options: map[string]any{"processes": []any{map[string]any{"name": "app", "process_name": "app"}}},
+	})
+	require.NoError(t, err)
+	collector := c.(*processCollector)
+
+	// завершившийся процесс: время его запуска получить нельзя
+	exited := exec.Command("true")
+	require.NoError(t, exited.Run())
+
+	procs := []*process.Process{{Pid: int32(os.Getpid())}, {Pid: int32(exited.Process.Pid)}}
+	collected, _ := collector.collectTarget(context.Background(), collector.targets[0], procs,
+		time.Now(), map[processKey]cpuSample{})
+
+	gauges := map[string]float64{}
+	for _, metric := range collected {
+		gauges[metric.ID] = *metric.Value
+	}
+	assert.Equal(t, 1.0, gauges["ProcessCountApp"], "учитываются только процессы, попавшие в метрики")
+}
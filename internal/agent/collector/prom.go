@@ -0,0 +1,255 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+// Типы семейств метрик текстового формата Prometheus
+const (
+	promCounter   = "counter"
+	promGauge     = "gauge"
+	promHistogram = "histogram"
+	promSummary   = "summary"
+	promUntyped   = "untyped"
+)
+
+// promSample - значение метрики с накопительной семантикой счетчиков:
+// значение counter - это итог с момента запуска источника, а не дельта.
+type promSample struct {
+	id     string // идентификатор метрики агента
+	name   string // исходное имя метрики (с суффиксом _bucket, _sum, _count)
+	kind   string // тип семейства: counter, gauge, histogram, summary, untyped
+	labels map[string]string
+	value  float64
+}
+
+// parsePromText разбирает текстовый формат Prometheus (exposition format 0.0.4).
+// Тип семейства берется из строки # TYPE, метки сохраняются, метка времени отбрасывается.
+// Возвращает разобранные значения и ошибки по строкам, которые не удалось разобрать.
+func parsePromText(data []byte) ([]promSample, []error) {
+	result := []promSample{}
+	errs := []error{}
+	types := map[string]string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			fields := strings.Fields(line)
+			if len(fields) >= 4 && fields[1] == "TYPE" {
+				types[fields[2]] = strings.ToLower(fields[3])
+			}
+			continue
+		}
+
+		sample, err := parsePromLine(line)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", lineNum, err))
+			continue
+		}
+		sample.kind = promKind(types, sample.name)
+		sample.id = MetricID("", sample.name)
+		result = append(result, sample)
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return result, errs
+}
+
+// promKind определяет тип семейства, к которому относится значение с именем name
+func promKind(types map[string]string, name string) string {
+	if kind, ok := types[name]; ok {
+		return kind
+	}
+	if kind := types[strings.TrimSuffix(name, "_total")]; kind == promCounter {
+		return kind
+	}
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		family, ok := strings.CutSuffix(name, suffix)
+		if !ok {
+			continue
+		}
+		if kind := types[family]; kind == promHistogram || kind == promSummary {
+			return kind
+		}
+	}
+	return promUntyped
+}
+
+// parsePromLine разбирает строку вида name{label="value",...} value [timestamp]
+func parsePromLine(line string) (promSample, error) {
+	end := strings.IndexAny(line, "{ \t")
+	if end <= 0 {
+		return promSample{}, fmt.Errorf("invalid sample: %q", line)
+	}
+	sample := promSample{name: line[:end]}
+	rest := line[end:]
+
+	if strings.HasPrefix(rest, "{") {
+		labels, tail, err := parsePromLabels(rest[1:])
+		if err != nil {
+			return promSample{}, err
+		}
+		sample.labels = labels
+		rest = tail
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) < 1 || len(fields) > 2 {
+		return promSample{}, fmt.Errorf("invalid sample value: %q", line)
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return promSample{}, fmt.Errorf("invalid sample value %q: %w", fields[0], err)
+	}
+	sample.value = value
+
+	return sample, nil
+}
+
+// parsePromLabels разбирает метки после открывающей скобки и возвращает остаток строки после закрывающей
+func parsePromLabels(s string) (map[string]string, string, error) {
+	labels := map[string]string{}
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if strings.HasPrefix(s, "}") {
+			return labels, s[1:], nil
+		}
+
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return nil, "", errors.New("invalid label: missing '='")
+		}
+		name := strings.TrimSpace(s[:eq])
+		if !metrics.IsValidLabelName(name) {
+			return nil, "", fmt.Errorf("invalid label name: %q", name)
+		}
+
+		s = strings.TrimLeft(s[eq+1:], " \t")
+		if !strings.HasPrefix(s, `"`) {
+			return nil, "", fmt.Errorf("label %s: value is not quoted", name)
+		}
+
+		var value strings.Builder
+		i := 1
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(s[i])
+				}
+				continue
+			}
+			value.WriteByte(s[i])
+		}
+		if i >= len(s) {
+			return nil, "", fmt.Errorf("label %s: unterminated value", name)
+		}
+		labels[name] = value.String()
+
+		s = strings.TrimLeft(s[i+1:], " \t")
+		s = strings.TrimPrefix(s, ",")
+	}
+}
+
+// cumulativeConverter превращает значения с накопительной семантикой в метрики агента:
+//   - gauge и untyped - метрика типа gauge;
+//   - counter - метрика типа counter с дельтой относительно предыдущего сбора;
+//   - histogram - _bucket (с меткой le) и _count как counter с дельтой, _sum как gauge;
+//   - summary - квантили (с меткой quantile) и _sum как gauge, _count как counter с дельтой.
+//
+// При первом появлении счетчика дельта не отдается. Если значение уменьшилось
+// (источник перезапущен), дельтой считается новое значение. Дробные счетчики
+// отдаются целой частью изменения. Нечисловые значения (NaN, ±Inf) пропускаются.
+type cumulativeConverter struct {
+	previous map[string]float64 // ключ - metrics.Metric.Key()
+}
+
+func newCumulativeConverter() *cumulativeConverter {
+	return &cumulativeConverter{previous: map[string]float64{}}
+}
+
+func (c *cumulativeConverter) convert(samples []promSample) ([]*metrics.Metric, error) {
+	result := make([]*metrics.Metric, 0, len(samples))
+	errs := []error{}
+	current := make(map[string]float64, len(c.previous))
+
+	for _, sample := range samples {
+		if math.IsNaN(sample.value) || math.IsInf(sample.value, 0) {
+			continue
+		}
+
+		var (
+			metric *metrics.Metric
+			err    error
+		)
+		if isCumulative(sample) {
+			metric = metrics.NewMetrics(sample.id, metrics.Counter)
+			metric.Labels = sample.labels
+			key := metric.Key()
+			current[key] = sample.value
+
+			previous, seen := c.previous[key]
+			if !seen {
+				continue
+			}
+			delta := math.Floor(sample.value) - math.Floor(previous)
+			if sample.value < previous {
+				delta = math.Floor(sample.value)
+			}
+			if delta == 0 {
+				continue
+			}
+			metric, err = NewCounterMetric(sample.id, int64(delta))
+		} else {
+			metric, err = NewGaugeMetric(sample.id, sample.value)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		metric.Labels = sample.labels
+		if err := metric.Check(true); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		result = append(result, metric)
+	}
+
+	// пропавшие счетчики забываются
+	c.previous = current
+
+	return result, errors.Join(errs...)
+}
+
+// isCumulative сообщает, отдается ли значение как counter с дельтой
+func isCumulative(sample promSample) bool {
+	switch sample.kind {
+	case promCounter:
+		return true
+	case promHistogram:
+		return strings.HasSuffix(sample.name, "_bucket") || strings.HasSuffix(sample.name, "_count")
+	case promSummary:
+		return strings.HasSuffix(sample.name, "_count")
+	}
+	return false
+}
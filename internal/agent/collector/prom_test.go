@@ -0,0 +1,89 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+func TestParsePromText(t *testing.T) {
+	samples, errs := parsePromText([]byte(`# HELP backup_size_bytes Size of the last backup.
+# TYPE backup_size_bytes gauge
+backup_size_bytes{db="main"} 1.5e+06
+# TYPE jobs_total counter
+jobs_total{status="ok",msg="a \"quoted\" \\ value"} 42 1700000000000
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.5"} 3
+latency_seconds_bucket{le="+Inf"} 5
+latency_seconds_sum 1.7
+latency_seconds_count 5
+untyped_value 7
+broken{le="1" 1
+bad value
+`))
+
+	assert.Len(t, errs, 2)
+	require.Len(t, samples, 7)
+
+	assert.Equal(t, promSample{id: "BackupSizeBytes", name: "backup_size_bytes", kind: promGauge,
+		labels: map[string]string{"db": "main"}, value: 1.5e6}, samples[0])
+	assert.Equal(t, promCounter, samples[1].kind)
+	assert.Equal(t, `a "quoted" \ value`, samples[1].labels["msg"])
+	assert.Equal(t, "LatencySecondsBucket", samples[2].id)
+	assert.Equal(t, promHistogram, samples[3].kind)
+	assert.Equal(t, "+Inf", samples[3].labels["le"])
+	assert.Equal(t, promHistogram, samples[4].kind)
+	assert.Equal(t, promUntyped, samples[6].kind)
+}
+
+func TestCumulativeConverter(t *testing.T) {
+	converter := newCumulativeConverter()
+
+	convert := func(data string) map[string]*metrics.Metric {
+		t.Helper()
+		samples, errs := parsePromText([]byte(data))
+		require.Empty(t, errs)
+		converted, err := converter.convert(samples)
+		require.NoError(t, err)
+
+		result := map[string]*metrics.Metric{}
+		for _, metric := range converted {
+			result[metric.Key()] = metric
+		}
+		return result
+	}
+
+	const family = "# TYPE jobs_total counter\n# TYPE latency histogram\n"
+
+	got := convert(family + `jobs_total{status="ok"} 10
+latency_bucket{le="1"} 2
+latency_sum 0.5
+latency_count 2
+temperature NaN
+`)
+	// при первом чтении счетчики запоминаются без дельты
+	assert.Len(t, got, 1)
+	assert.Equal(t, 0.5, *got["LatencySum"].Value)
+
+	got = convert(family + `jobs_total{status="ok"} 15
+jobs_total{status="failed"} 1
+latency_bucket{le="1"} 3
+latency_sum 0.9
+latency_count 3
+`)
+	assert.Equal(t, int64(5), *got[`JobsTotal{status="ok"}`].Delta)
+	assert.NotContains(t, got, `JobsTotal{status="failed"}`)
+	assert.Equal(t, int64(1), *got[`LatencyBucket{le="1"}`].Delta)
+	assert.Equal(t, int64(1), *got["LatencyCount"].Delta)
+	assert.Equal(t, 0.9, *got["LatencySum"].Value)
+
+	// сброс счетчика источником
+	got = convert(family + `jobs_total{status="ok"} 4
+jobs_total{status="failed"} 1
+`)
+	assert.Equal(t, int64(4), *got[`JobsTotal{status="ok"}`].Delta)
+	assert.NotContains(t, got, `JobsTotal{status="failed"}`, "нулевая дельта не отдается")
+}
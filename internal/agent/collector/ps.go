@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/shirou/gopsutil/v4/mem"
+	"go.uber.org/zap"
+
+	"github.com/galogen13/yandex-go-metrics/internal/logger"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+// PSCollectorName - имя сборщика метрик операционной системы (gopsutil)
+const PSCollectorName = "ps"
+
+// psCollector собирает метрики памяти и загрузки процессоров.
+type psCollector struct {
+	name     string
+	interval time.Duration
+}
+
+// NewPSCollector создает сборщик метрик операционной системы.
+func NewPSCollector(settings Settings) (Collector, error) {
+	return &psCollector{name: settings.Name, interval: settings.Interval}, nil
+}
+
+func (c *psCollector) Name() string {
+	return c.name
+}
+
+func (c *psCollector) Interval() time.Duration {
+	return c.interval
+}
+
+func (c *psCollector) Collect(ctx context.Context) ([]*metrics.Metric, error) {
+
+	result := []*metrics.Metric{}
+
+	if vmStat, err := mem.VirtualMemoryWithContext(ctx); err == nil {
+
+		if metric, err := NewGaugeMetric("TotalMemory", float64(vmStat.Total)); err != nil {
+			logger.Log.Error("error updating agent metric values", zap.Error(err))
+		} else {
+			result = append(result, metric)
+		}
+
+		if metric, err := NewGaugeMetric("FreeMemory", float64(vmStat.Free)); err != nil {
+			logger.Log.Error("error updating agent metric values", zap.Error(err))
+		} else {
+			result = append(result, metric)
+		}
+
+	} else {
+		logger.Log.Error("error getting virtual memory metrics", zap.Error(err))
+	}
+
+	if cpuPercent, err := cpu.PercentWithContext(ctx, 0, true); err == nil {
+		for cpuNum, usage := range cpuPercent {
+			if metric, err := NewGaugeMetric(fmt.Sprintf("CPUutilization%d", cpuNum), float64(usage)); err != nil {
+				logger.Log.Error("error updating agent metric values", zap.Error(err))
+			} else {
+				result = append(result, metric)
+			}
+		}
+	} else {
+		logger.Log.Error("error getting cpu metrics", zap.Error(err))
+	}
+
+	return result, nil
+}
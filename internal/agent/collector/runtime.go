@@ -0,0 +1,393 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"path"
+	"runtime/debug"
+	rtmetrics "runtime/metrics"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+// RuntimeCollectorName - имя сборщика метрик пакета runtime
+const RuntimeCollectorName = "runtime"
+
+// runtimeQuantileLabel - метка с квантилем у метрик, составленных из гистограмм runtime/metrics
+const runtimeQuantileLabel = "quantile"
+
+// runtimeDefaultQuantiles - квантили гистограмм по умолчанию
+var runtimeDefaultQuantiles = []float64{0.5, 0.9, 0.99}
+
+// Метрики runtime/metrics, из которых составляются метрики с именами полей runtime.MemStats
+const (
+	rtHeapObjectsBytes   = "/memory/classes/heap/objects:bytes"
+	rtHeapUnusedBytes    = "/memory/classes/heap/unused:bytes"
+	rtHeapFreeBytes      = "/memory/classes/heap/free:bytes"
+	rtHeapReleasedBytes  = "/memory/classes/heap/released:bytes"
+	rtHeapStacksBytes    = "/memory/classes/heap/stacks:bytes"
+	rtOSStacksBytes      = "/memory/classes/os-stacks:bytes"
+	rtMSpanInuseBytes    = "/memory/classes/metadata/mspan/inuse:bytes"
+	rtMSpanFreeBytes     = "/memory/classes/metadata/mspan/free:bytes"
+	rtMCacheInuseBytes   = "/memory/classes/metadata/mcache/inuse:bytes"
+	rtMCacheFreeBytes    = "/memory/classes/metadata/mcache/free:bytes"
+	rtMetadataOtherBytes = "/memory/classes/metadata/other:bytes"
+	rtProfilingBytes     = "/memory/classes/profiling/buckets:bytes"
+	rtOtherBytes         = "/memory/classes/other:bytes"
+	rtTotalBytes         = "/memory/classes/total:bytes"
+	rtAllocsBytes        = "/gc/heap/allocs:bytes"
+	rtAllocsObjects      = "/gc/heap/allocs:objects"
+	rtFreesObjects       = "/gc/heap/frees:objects"
+	rtTinyAllocsObjects  = "/gc/heap/tiny/allocs:objects"
+	rtHeapObjects        = "/gc/heap/objects:objects"
+	rtHeapGoalBytes      = "/gc/heap/goal:bytes"
+	rtGCCycles           = "/gc/cycles/total:gc-cycles"
+	rtGCForcedCycles     = "/gc/cycles/forced:gc-cycles"
+	rtGCCPUSeconds       = "/cpu/classes/gc/total:cpu-seconds"
+	rtTotalCPUSeconds    = "/cpu/classes/total:cpu-seconds"
+)
+
+// runtimeMemStatsSamples - метрики runtime/metrics, нужные для метрик с именами полей runtime.MemStats
+var runtimeMemStatsSamples = []string{
+	rtHeapObjectsBytes, rtHeapUnusedBytes, rtHeapFreeBytes, rtHeapReleasedBytes, rtHeapStacksBytes,
+	rtOSStacksBytes, rtMSpanInuseBytes, rtMSpanFreeBytes, rtMCacheInuseBytes, rtMCacheFreeBytes,
+	rtMetadataOtherBytes, rtProfilingBytes, rtOtherBytes, rtTotalBytes, rtAllocsBytes, rtAllocsObjects,
+	rtFreesObjects, rtTinyAllocsObjects, rtHeapObjects, rtHeapGoalBytes, rtGCCycles, rtGCForcedCycles,
+	rtGCCPUSeconds, rtTotalCPUSeconds,
+}
+
+// runtimeOptions - параметры сборщика runtime
+type runtimeOptions struct {
+	MemStats  *bool     `mapstructure:"memstats"`  // отдавать метрики с именами полей runtime.MemStats; по умолчанию - да
+	Samples   []string  `mapstructure:"samples"`   // имена или шаблоны path.Match метрик runtime/metrics
+	Quantiles []float64 `mapstructure:"quantiles"` // квантили гистограмм
+}
+
+// runtimeCollector собирает метрики среды исполнения Go из пакета runtime/metrics, который,
+// в отличие от runtime.ReadMemStats, не останавливает программу.
+//
+// По умолчанию отдаются метрики типа gauge с именами полей runtime.MemStats (Alloc, HeapInuse, NumGC и т.д.),
+// вычисленные по метрикам runtime/metrics, и метрика RandomValue.
+//
+// Метрики runtime/metrics из опции samples отдаются с идентификаторами MetricID("Go", имя):
+// /sched/goroutines:goroutines -> GoSchedGoroutinesGoroutines. Накопительные целочисленные метрики
+// отдаются как counter с дельтой с прошлого сбора, остальные - как gauge. Для гистограмм
+// (например, /gc/pauses:seconds, /sched/latencies:seconds) отдаются квантили распределения
+// за время с прошлого сбора - gauge с меткой quantile, и количество значений - counter <ID>Count.
+type runtimeCollector struct {
+	name      string
+	interval  time.Duration
+	memStats  bool
+	samples   []rtmetrics.Sample
+	index     map[string]int // индекс метрики в samples по имени
+	exported  []rtmetrics.Description
+	quantiles []float64
+	counters  *deltaTracker
+	// histograms - количества значений в интервалах гистограмм на прошлом сборе, ключ - имя метрики
+	histograms map[string][]uint64
+}
+
+// NewRuntimeCollector создает сборщик метрик пакета runtime.
+//
+// Опции:
+//   - memstats - отдавать метрики с именами полей runtime.MemStats; по умолчанию true
+//   - samples - имена или шаблоны path.Match метрик runtime/metrics, например "/sched/latencies:seconds", "/gc/*"
+//   - quantiles - квантили гистограмм; по умолчанию [0.5, 0.9, 0.99]
+func NewRuntimeCollector(settings Settings) (Collector, error) {
+	var options runtimeOptions
+	if err := DecodeOptions(settings.Options, &options); err != nil {
+		return nil, err
+	}
+
+	quantiles := options.Quantiles
+	if len(quantiles) == 0 {
+		quantiles = runtimeDefaultQuantiles
+	}
+	for _, q := range quantiles {
+		if q < 0 || q > 1 {
+			return nil, fmt.Errorf("invalid runtime quantile: %v", q)
+		}
+	}
+
+	exported, err := runtimeDescriptions(options.Samples)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &runtimeCollector{
+		name:       settings.Name,
+		interval:   settings.Interval,
+		memStats:   options.MemStats == nil || *options.MemStats,
+		index:      map[string]int{},
+		exported:   exported,
+		quantiles:  quantiles,
+		counters:   newDeltaTracker(),
+		histograms: map[string][]uint64{},
+	}
+
+	names := []string{}
+	if c.memStats {
+		names = append(names, runtimeMemStatsSamples...)
+	}
+	for _, desc := range exported {
+		names = append(names, desc.Name)
+	}
+	for _, name := range names {
+		if _, ok := c.index[name]; ok {
+			continue
+		}
+		c.index[name] = len(c.samples)
+		c.samples = append(c.samples, rtmetrics.Sample{Name: name})
+	}
+
+	return c, nil
+}
+
+// runtimeDescriptions возвращает описания метрик runtime/metrics, имена которых совпадают с шаблонами patterns.
+// Шаблон без совпадений - ошибка: метрика могла быть переименована в другой версии Go.
+func runtimeDescriptions(patterns []string) ([]rtmetrics.Description, error) {
+	all := rtmetrics.All()
+	result := []rtmetrics.Description{}
+
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid runtime sample pattern %q: %w", pattern, err)
+		}
+
+		found := false
+		for _, desc := range all {
+			if ok, _ := path.Match(pattern, desc.Name); !ok || desc.Kind == rtmetrics.KindBad {
+				continue
+			}
+			found = true
+			if !slices.ContainsFunc(result, func(d rtmetrics.Description) bool { return d.Name == desc.Name }) {
+				result = append(result, desc)
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unsupported runtime sample: %s", pattern)
+		}
+	}
+	return result, nil
+}
+
+func (c *runtimeCollector) Name() string {
+	return c.name
+}
+
+func (c *runtimeCollector) Interval() time.Duration {
+	return c.interval
+}
+
+func (c *runtimeCollector) Collect(_ context.Context) ([]*metrics.Metric, error) {
+	rtmetrics.Read(c.samples)
+
+	result := []*metrics.Metric{}
+	errs := []error{}
+
+	if c.memStats {
+		memStats, err := c.memStatsMetrics()
+		result = append(result, memStats...)
+		errs = append(errs, err)
+	}
+
+	exported, err := c.exportedMetrics()
+	result = append(result, exported...)
+	errs = append(errs, err)
+
+	if metric, err := NewGaugeMetric("RandomValue", rand.Float64()); err != nil {
+		errs = append(errs, err)
+	} else {
+		result = append(result, metric)
+	}
+
+	return result, errors.Join(errs...)
+}
+
+// value возвращает значение прочитанной метрики runtime/metrics с числовым значением
+func (c *runtimeCollector) value(name string) float64 {
+	sample := c.samples[c.index[name]]
+	switch sample.Value.Kind() {
+	case rtmetrics.KindUint64:
+		return float64(sample.Value.Uint64())
+	case rtmetrics.KindFloat64:
+		return sample.Value.Float64()
+	}
+	return 0
+}
+
+// memStatsMetrics составляет метрики с именами полей runtime.MemStats так же, как их заполняет
+// runtime.ReadMemStats. LastGC и PauseTotalNs берутся из debug.ReadGCStats, Lookups всегда равен 0.
+func (c *runtimeCollector) memStatsMetrics() ([]*metrics.Metric, error) {
+	v := c.value
+
+	var gcStats debug.GCStats
+	debug.ReadGCStats(&gcStats)
+	lastGC := 0.0
+	if !gcStats.LastGC.IsZero() {
+		lastGC = float64(gcStats.LastGC.UnixNano())
+	}
+
+	gcCPUFraction := 0.0
+	if total := v(rtTotalCPUSeconds); total > 0 {
+		gcCPUFraction = v(rtGCCPUSeconds) / total
+	}
+
+	heapInuse := v(rtHeapObjectsBytes) + v(rtHeapUnusedBytes)
+	heapIdle := v(rtHeapFreeBytes) + v(rtHeapReleasedBytes)
+
+	values := []gaugeValue{
+		{prefix: "Alloc", value: v(rtHeapObjectsBytes)},
+		{prefix: "BuckHashSys", value: v(rtProfilingBytes)},
+		{prefix: "Frees", value: v(rtFreesObjects) + v(rtTinyAllocsObjects)},
+		{prefix: "GCCPUFraction", value: gcCPUFraction},
+		{prefix: "GCSys", value: v(rtMetadataOtherBytes)},
+		{prefix: "HeapAlloc", value: v(rtHeapObjectsBytes)},
+		{prefix: "HeapIdle", value: heapIdle},
+		{prefix: "HeapInuse", value: heapInuse},
+		{prefix: "HeapObjects", value: v(rtHeapObjects)},
+		{prefix: "HeapReleased", value: v(rtHeapReleasedBytes)},
+		{prefix: "HeapSys", value: heapInuse + heapIdle},
+		{prefix: "LastGC", value: lastGC},
+		{prefix: "Lookups", value: 0},
+		{prefix: "MCacheInuse", value: v(rtMCacheInuseBytes)},
+		{prefix: "MCacheSys", value: v(rtMCacheInuseBytes) + v(rtMCacheFreeBytes)},
+		{prefix: "MSpanInuse", value: v(rtMSpanInuseBytes)},
+		{prefix: "MSpanSys", value: v(rtMSpanInuseBytes) + v(rtMSpanFreeBytes)},
+		{prefix: "Mallocs", value: v(rtAllocsObjects) + v(rtTinyAllocsObjects)},
+		{prefix: "NextGC", value: v(rtHeapGoalBytes)},
+		{prefix: "NumForcedGC", value: v(rtGCForcedCycles)},
+		{prefix: "NumGC", value: v(rtGCCycles)},
+		{prefix: "OtherSys", value: v(rtOtherBytes)},
+		{prefix: "PauseTotalNs", value: float64(gcStats.PauseTotal.Nanoseconds())},
+		{prefix: "StackInuse", value: v(rtHeapStacksBytes)},
+		{prefix: "StackSys", value: v(rtHeapStacksBytes) + v(rtOSStacksBytes)},
+		{prefix: "Sys", value: v(rtTotalBytes)},
+		{prefix: "TotalAlloc", value: v(rtAllocsBytes)},
+	}
+	return gaugeMetrics(values)
+}
+
+// exportedMetrics составляет метрики из метрик runtime/metrics, заданных в опции samples
+func (c *runtimeCollector) exportedMetrics() ([]*metrics.Metric, error) {
+	result := []*metrics.Metric{}
+	errs := []error{}
+	cumulative := map[string]uint64{}
+
+	for _, desc := range c.exported {
+		mID := MetricID("Go", desc.Name)
+		sample := c.samples[c.index[desc.Name]]
+
+		switch sample.Value.Kind() {
+		case rtmetrics.KindUint64:
+			if desc.Cumulative {
+				cumulative[mID] = sample.Value.Uint64()
+				continue
+			}
+			metric, err := NewGaugeMetric(mID, float64(sample.Value.Uint64()))
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			result = append(result, metric)
+		case rtmetrics.KindFloat64:
+			metric, err := NewGaugeMetric(mID, sample.Value.Float64())
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			result = append(result, metric)
+		case rtmetrics.KindFloat64Histogram:
+			histogram := sample.Value.Float64Histogram()
+			quantiles, err := c.histogramMetrics(mID, desc, histogram)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			result = append(result, quantiles...)
+			cumulative[mID+"Count"] = histogramCount(histogram.Counts)
+		}
+	}
+
+	counters, err := counterMetrics(c.counters.deltas(cumulative))
+	if err != nil {
+		errs = append(errs, err)
+	}
+	result = append(result, counters...)
+
+	return result, errors.Join(errs...)
+}
+
+// histogramMetrics возвращает квантили распределения значений гистограммы. Для накопительной гистограммы
+// квантили считаются по значениям, добавленным с прошлого сбора; если новых значений нет, метрики не отдаются.
+func (c *runtimeCollector) histogramMetrics(mID string, desc rtmetrics.Description, histogram *rtmetrics.Float64Histogram) ([]*metrics.Metric, error) {
+	counts := histogram.Counts
+	if desc.Cumulative {
+		previous := c.histograms[desc.Name]
+		c.histograms[desc.Name] = slices.Clone(histogram.Counts)
+		if len(previous) == len(counts) {
+			counts = make([]uint64, len(histogram.Counts))
+			for i := range counts {
+				counts[i] = histogram.Counts[i] - previous[i]
+			}
+		}
+	}
+
+	if histogramCount(counts) == 0 {
+		return nil, nil
+	}
+
+	result := make([]*metrics.Metric, 0, len(c.quantiles))
+	for _, q := range c.quantiles {
+		metric, err := NewGaugeMetric(mID, histogramQuantile(counts, histogram.Buckets, q))
+		if err != nil {
+			return result, err
+		}
+		metric.Labels = map[string]string{runtimeQuantileLabel: strconv.FormatFloat(q, 'g', -1, 64)}
+		result = append(result, metric)
+	}
+	return result, nil
+}
+
+// histogramCount возвращает количество значений в гистограмме
+func histogramCount(counts []uint64) uint64 {
+	var total uint64
+	for _, count := range counts {
+		total += count
+	}
+	return total
+}
+
+// histogramQuantile оценивает квантиль q распределения по гистограмме: counts[i] - количество значений
+// в интервале [buckets[i], buckets[i+1]). Внутри интервала значения считаются распределенными равномерно,
+// для бесконечного интервала берется его конечная граница.
+func histogramQuantile(counts []uint64, buckets []float64, q float64) float64 {
+	total := histogramCount(counts)
+	rank := q * float64(total)
+
+	var seen float64
+	for i, count := range counts {
+		if count == 0 {
+			continue
+		}
+		if seen+float64(count) < rank {
+			seen += float64(count)
+			continue
+		}
+
+		low, high := buckets[i], buckets[i+1]
+		switch {
+		case math.IsInf(low, -1):
+			return high
+		case math.IsInf(high, 1):
+			return low
+		}
+		return low + (high-low)*(rank-seen)/float64(count)
+	}
+	return buckets[len(buckets)-1]
+}
@@ -0,0 +1,106 @@
+package collector
+
+import (
+	"math"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+func TestRuntimeCollector_MemStats(t *testing.T) {
+	c, err := NewRuntimeCollector(Settings{Name: RuntimeCollectorName})
+	require.NoError(t, err)
+
+	runtime.GC()
+	collected, err := c.Collect(t.Context())
+	require.NoError(t, err)
+
+	got := map[string]float64{}
+	for _, metric := range collected {
+		require.Equal(t, metrics.Gauge, metric.MType)
+		got[metric.ID] = *metric.Value
+	}
+
+	for _, mID := range []string{
+		"Alloc", "BuckHashSys", "Frees", "GCCPUFraction", "GCSys", "HeapAlloc", "HeapIdle", "HeapInuse",
+		"HeapObjects", "HeapReleased", "HeapSys", "LastGC", "Lookups", "MCacheInuse", "MCacheSys",
+		"MSpanInuse", "MSpanSys", "Mallocs", "NextGC", "NumForcedGC", "NumGC", "OtherSys",
+		"PauseTotalNs", "StackInuse", "StackSys", "Sys", "TotalAlloc", "RandomValue",
+	} {
+		assert.Contains(t, got, mID)
+	}
+	assert.Len(t, got, 28, "по умолчанию отдаются только метрики с именами полей runtime.MemStats")
+
+	assert.Positive(t, got["HeapAlloc"])
+	assert.GreaterOrEqual(t, got["NumForcedGC"], 1.0)
+	assert.Positive(t, got["LastGC"])
+	assert.Equal(t, got["HeapInuse"]+got["HeapIdle"], got["HeapSys"])
+}
+
+func TestRuntimeCollector_Samples(t *testing.T) {
+	c, err := NewRuntimeCollector(Settings{Name: RuntimeCollectorName, Options: map[string]any{
+		"memstats":  false,
+		"samples":   []string{"/sched/goroutines:goroutines", "/gc/cycles/*", "/sched/pauses/total/gc:seconds"},
+		"quantiles": []float64{0.5, 0.99},
+	}})
+	require.NoError(t, err)
+
+	_, err = c.Collect(t.Context())
+	require.NoError(t, err)
+
+	runtime.GC()
+	runtime.GC()
+	collected, err := c.Collect(t.Context())
+	require.NoError(t, err)
+
+	gauges := map[string]*metrics.Metric{}
+	counters := map[string]int64{}
+	quantiles := []string{}
+	for _, metric := range collected {
+		switch {
+		case metric.ID == "GoSchedPausesTotalGcSeconds":
+			require.Equal(t, metrics.Gauge, metric.MType)
+			quantiles = append(quantiles, metric.Labels[runtimeQuantileLabel])
+		case metric.MType == metrics.Counter:
+			counters[metric.ID] = *metric.Delta
+		default:
+			gauges[metric.ID] = metric
+		}
+	}
+
+	assert.Contains(t, gauges, "GoSchedGoroutinesGoroutines")
+	assert.Contains(t, gauges, "RandomValue")
+	assert.NotContains(t, gauges, "HeapAlloc")
+	assert.GreaterOrEqual(t, counters["GoGcCyclesForcedGcCycles"], int64(2), "накопительные метрики отдаются дельтой")
+	assert.GreaterOrEqual(t, counters["GoGcCyclesTotalGcCycles"], int64(2))
+	assert.Positive(t, counters["GoSchedPausesTotalGcSecondsCount"])
+	assert.Equal(t, []string{"0.5", "0.99"}, quantiles, "квантили пауз за время с прошлого сбора")
+}
+
+func TestNewRuntimeCollector_Errors(t *testing.T) {
+	_, err := NewRuntimeCollector(Settings{Options: map[string]any{"samples": []string{"/no/such:metric"}}})
+	assert.Error(t, err)
+
+	_, err = NewRuntimeCollector(Settings{Options: map[string]any{"samples": []string{"/gc/["}}})
+	assert.Error(t, err)
+
+	_, err = NewRuntimeCollector(Settings{Options: map[string]any{"quantiles": []float64{1.5}}})
+	assert.Error(t, err)
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	buckets := []float64{0, 1, 2, 4, 8}
+	counts := []uint64{0, 10, 0, 10}
+
+	assert.InDelta(t, 1.5, histogramQuantile(counts, buckets, 0.25), 1e-9)
+	assert.InDelta(t, 2, histogramQuantile(counts, buckets, 0.5), 1e-9)
+	assert.InDelta(t, 8, histogramQuantile(counts, buckets, 1), 1e-9)
+
+	assert.InDelta(t, 1, histogramQuantile([]uint64{0, 5}, []float64{0, 1, math.Inf(1)}, 0.9), 1e-9,
+		"для бесконечного интервала берется конечная граница")
+	assert.InDelta(t, 1, histogramQuantile([]uint64{5, 0}, []float64{math.Inf(-1), 1, 2}, 0.9), 1e-9)
+}
@@ -0,0 +1,237 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+// ScrapeCollectorName - имя сборщика, опрашивающего эндпоинты в формате Prometheus
+const ScrapeCollectorName = "scrape"
+
+const (
+	defaultScrapeTimeout = 10 * time.Second
+	// scrapeMaxBodySize - максимальный размер ответа эндпоинта
+	scrapeMaxBodySize = 16 << 20
+	scrapeAccept      = "text/plain;version=0.0.4;q=1,*/*;q=0.1"
+)
+
+// scrapeJobLabel - метка с именем цели у всех метрик, полученных опросом
+const scrapeJobLabel = "job"
+
+// scrapeOptions - параметры сборщика scrape
+type scrapeOptions struct {
+	Targets []scrapeTargetOptions `mapstructure:"targets"`
+}
+
+// scrapeTargetOptions - описание опрашиваемого эндпоинта
+type scrapeTargetOptions struct {
+	Name    string        `mapstructure:"name"`    // значение метки job; по умолчанию - адрес хоста из url
+	URL     string        `mapstructure:"url"`     // адрес эндпоинта, например http://localhost:9090/metrics
+	Timeout time.Duration `mapstructure:"timeout"` // таймаут опроса; по умолчанию - интервал сборщика, но не более 10s
+	Include []string      `mapstructure:"include"` // шаблоны имен метрик Prometheus (с суффиксами _bucket, _sum, _count), которые нужно отдавать
+	Exclude []string      `mapstructure:"exclude"` // шаблоны имен метрик Prometheus, которые нужно пропускать
+}
+
+type scrapeTarget struct {
+	name      string
+	url       string
+	timeout   time.Duration
+	filter    nameFilter
+	converter *cumulativeConverter
+}
+
+// scrapeCollector опрашивает HTTP-эндпоинты, отдающие метрики в текстовом формате Prometheus,
+// и преобразует их в метрики агента по правилам cumulativeConverter: gauge - в gauge, counter -
+// в дельты между опросами, гистограммы - в счетчики <Name>Bucket с меткой le, <Name>Count и gauge <Name>Sum.
+// Идентификаторы строятся из имен Prometheus: go_gc_duration_seconds -> GoGcDurationSeconds.
+//
+// Ко всем метрикам цели добавляется метка job с именем цели, чтобы одинаковые метрики
+// разных сервисов не смешивались. Для каждой цели отдаются собственные метрики типа gauge с меткой job:
+//   - ScrapeUp - 1, если последний опрос успешен, иначе 0;
+//   - ScrapeDuration - длительность опроса в секундах;
+//   - ScrapeSamples - количество отданных метрик.
+type scrapeCollector struct {
+	name     string
+	interval time.Duration
+	targets  []*scrapeTarget
+	client   *http.Client
+}
+
+// NewScrapeCollector создает сборщик, опрашивающий эндпоинты в формате Prometheus.
+//
+// Опции:
+//   - targets - список целей с полями name, url, timeout, include, exclude
+func NewScrapeCollector(settings Settings) (Collector, error) {
+	var options scrapeOptions
+	if err := DecodeOptions(settings.Options, &options); err != nil {
+		return nil, err
+	}
+
+	defaultTimeout := defaultScrapeTimeout
+	if settings.Interval > 0 {
+		defaultTimeout = min(settings.Interval, defaultScrapeTimeout)
+	}
+
+	targets := make([]*scrapeTarget, 0, len(options.Targets))
+	names := map[string]bool{}
+	for _, targetOptions := range options.Targets {
+		target, err := newScrapeTarget(targetOptions, defaultTimeout)
+		if err != nil {
+			return nil, err
+		}
+		if names[target.name] {
+			return nil, fmt.Errorf("duplicate scrape target name: %s", target.name)
+		}
+		names[target.name] = true
+		targets = append(targets, target)
+	}
+
+	return &scrapeCollector{
+		name:     settings.Name,
+		interval: settings.Interval,
+		targets:  targets,
+		client:   &http.Client{},
+	}, nil
+}
+
+func newScrapeTarget(options scrapeTargetOptions, defaultTimeout time.Duration) (*scrapeTarget, error) {
+	targetURL, err := url.Parse(options.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scrape url %q: %w", options.URL, err)
+	}
+	if (targetURL.Scheme != "http" && targetURL.Scheme != "https") || targetURL.Host == "" {
+		return nil, fmt.Errorf("invalid scrape url %q: http or https address expected", options.URL)
+	}
+
+	filter, err := newNameFilter(options.Include, options.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("scrape target %s: %w", options.URL, err)
+	}
+
+	target := &scrapeTarget{
+		name:      options.Name,
+		url:       options.URL,
+		timeout:   options.Timeout,
+		filter:    filter,
+		converter: newCumulativeConverter(),
+	}
+	if target.name == "" {
+		target.name = targetURL.Host
+	}
+	if target.timeout <= 0 {
+		target.timeout = defaultTimeout
+	}
+
+	return target, nil
+}
+
+func (c *scrapeCollector) Name() string {
+	return c.name
+}
+
+func (c *scrapeCollector) Interval() time.Duration {
+	return c.interval
+}
+
+// Collect опрашивает все цели параллельно.
+func (c *scrapeCollector) Collect(ctx context.Context) ([]*metrics.Metric, error) {
+	results := make([][]*metrics.Metric, len(c.targets))
+	errs := make([]error, len(c.targets))
+
+	var wg sync.WaitGroup
+	for i, target := range c.targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = c.collectTarget(ctx, target)
+		}()
+	}
+	wg.Wait()
+
+	result := []*metrics.Metric{}
+	for _, targetMetrics := range results {
+		result = append(result, targetMetrics...)
+	}
+	return result, errors.Join(errs...)
+}
+
+// collectTarget опрашивает цель и возвращает ее метрики вместе с метриками состояния опроса
+func (c *scrapeCollector) collectTarget(ctx context.Context, target *scrapeTarget) ([]*metrics.Metric, error) {
+	start := time.Now()
+	collected, parseErr, scrapeErr := c.scrape(ctx, target)
+	duration := time.Since(start)
+
+	up := 1.0
+	if scrapeErr != nil {
+		up = 0
+	}
+
+	state, err := gaugeMetrics([]gaugeValue{
+		{prefix: "ScrapeUp", value: up},
+		{prefix: "ScrapeDuration", value: duration.Seconds()},
+		{prefix: "ScrapeSamples", value: float64(len(collected))},
+	})
+
+	result := append(collected, state...)
+	for _, metric := range result {
+		if metric.Labels == nil {
+			metric.Labels = map[string]string{}
+		}
+		metric.Labels[scrapeJobLabel] = target.name
+	}
+
+	if err := errors.Join(scrapeErr, parseErr, err); err != nil {
+		return result, fmt.Errorf("scrape target %s: %w", target.name, err)
+	}
+	return result, nil
+}
+
+// scrape опрашивает цель. Ошибки разбора отдельных строк возвращаются в parseErr
+// и не делают опрос неуспешным.
+func (c *scrapeCollector) scrape(ctx context.Context, target *scrapeTarget) (collected []*metrics.Metric, parseErr, err error) {
+	ctx, cancel := context.WithTimeout(ctx, target.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", scrapeAccept)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, scrapeMaxBodySize+1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot read response: %w", err)
+	}
+	if len(body) > scrapeMaxBodySize {
+		return nil, nil, fmt.Errorf("response is larger than %d bytes", scrapeMaxBodySize)
+	}
+
+	samples, parseErrs := parsePromText(body)
+	filtered := samples[:0]
+	for _, sample := range samples {
+		if target.filter.match(sample.name) {
+			filtered = append(filtered, sample)
+		}
+	}
+
+	collected, err = target.converter.convert(filtered)
+	return collected, errors.Join(append(parseErrs, err)...), nil
+}
@@ -0,0 +1,89 @@
+package collector
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+func TestScrapeCollector(t *testing.T) {
+	var requests atomic.Int64
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		fmt.Fprintf(w, `# TYPE go_goroutines gauge
+go_goroutines 12
+# TYPE http_requests_total counter
+http_requests_total{code="200"} %d
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{le="0.1"} %d
+http_request_duration_seconds_bucket{le="+Inf"} %d
+http_request_duration_seconds_sum 1.25
+http_request_duration_seconds_count %d
+process_open_fds 9
+`, 100*n, 2*n, 3*n, 3*n)
+	}))
+	defer service.Close()
+
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer broken.Close()
+
+	c, err := NewScrapeCollector(Settings{Name: ScrapeCollectorName, Interval: time.Second,
+		Options: map[string]any{"targets": []any{
+			map[string]any{"name": "api", "url": service.URL + "/metrics", "exclude": []any{"process_*"}},
+			map[string]any{"url": broken.URL},
+		}}})
+	require.NoError(t, err)
+
+	collect := func() (map[string]*metrics.Metric, error) {
+		collected, err := c.Collect(t.Context())
+		result := map[string]*metrics.Metric{}
+		for _, metric := range collected {
+			result[metric.Key()] = metric
+		}
+		return result, err
+	}
+
+	got, err := collect()
+	assert.Error(t, err, "ошибка недоступной цели возвращается")
+	assert.Equal(t, 12.0, *got[`GoGoroutines{job="api"}`].Value)
+	assert.Equal(t, 1.25, *got[`HttpRequestDurationSecondsSum{job="api"}`].Value)
+	assert.NotContains(t, got, `ProcessOpenFds{job="api"}`)
+	assert.NotContains(t, got, `HttpRequestsTotal{code="200",job="api"}`, "при первом опросе дельта не отдается")
+	assert.Equal(t, 1.0, *got[`ScrapeUp{job="api"}`].Value)
+
+	brokenJob := broken.Listener.Addr().String()
+	assert.Equal(t, 0.0, *got[fmt.Sprintf(`ScrapeUp{job=%q}`, brokenJob)].Value)
+
+	got, _ = collect()
+	assert.Equal(t, int64(100), *got[`HttpRequestsTotal{code="200",job="api"}`].Delta)
+	assert.Equal(t, int64(2), *got[`HttpRequestDurationSecondsBucket{job="api",le="0.1"}`].Delta)
+	assert.Equal(t, int64(3), *got[`HttpRequestDurationSecondsBucket{job="api",le="+Inf"}`].Delta)
+	assert.Equal(t, int64(3), *got[`HttpRequestDurationSecondsCount{job="api"}`].Delta)
+}
+
+func TestNewScrapeCollector_Validation(t *testing.T) {
+	for name, targets := range map[string][]any{
+		"нет адреса":             {map[string]any{"name": "api"}},
+		"неподдерживаемая схема": {map[string]any{"url": "ftp://localhost/metrics"}},
+		"одинаковые имена": {
+			map[string]any{"name": "api", "url": "http://localhost:1/metrics"},
+			map[string]any{"name": "api", "url": "http://localhost:2/metrics"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewScrapeCollector(Settings{Name: ScrapeCollectorName, Interval: time.Second,
+				Options: map[string]any{"targets": targets}})
+			assert.Error(t, err)
+		})
+	}
+}
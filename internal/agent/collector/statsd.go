@@ -0,0 +1,310 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/galogen13/yandex-go-metrics/internal/logger"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+// StatsDCollectorName - имя сборщика, принимающего метрики по протоколу StatsD
+const StatsDCollectorName = "statsd"
+
+const (
+	defaultStatsDAddress = "127.0.0.1:8125"
+	statsDMaxPacketSize  = 65535
+)
+
+// Типы метрик протокола StatsD
+const (
+	statsDCounter = "c"
+	statsDGauge   = "g"
+	statsDTimer   = "ms"
+	statsDHisto   = "h" // синоним ms
+)
+
+// statsDOptions - параметры сборщика StatsD
+type statsDOptions struct {
+	Address string `mapstructure:"address"` // адрес UDP, на котором принимаются метрики
+	Prefix  string `mapstructure:"prefix"`  // префикс идентификаторов принятых метрик
+}
+
+// statsDSample - разобранная строка протокола StatsD
+type statsDSample struct {
+	name       string
+	value      float64
+	mType      string
+	sampleRate float64
+	relative   bool // значение gauge задано со знаком и изменяет текущее значение
+}
+
+type timerStats struct {
+	count float64 // количество замеров с учетом частоты выборки
+	sum   float64
+	min   float64
+	max   float64
+	seen  int // количество фактически полученных замеров
+}
+
+// statsDCollector принимает метрики приложений по UDP в формате StatsD
+// (name:value|c|@rate, name:value|g, name:value|ms|@rate) и агрегирует их между сборами:
+//   - c - дельты суммируются с учетом частоты выборки и отдаются как counter;
+//   - g - хранится последнее значение (значения со знаком +/- изменяют его), отдается как gauge
+//     при каждом сборе;
+//   - ms - за интервал между отправками пакетов отдаются gauge <Name>Min, <Name>Max, <Name>Mean
+//     и counter <Name>Count; статистика таймеров отдается не Collect, а Flush (см. Flusher).
+//
+// Имена метрик StatsD приводятся к допустимым идентификаторам: "api.requests" -> "ApiRequests".
+// Строки с некорректным форматом или именем, из которого не получается идентификатор, пропускаются.
+type statsDCollector struct {
+	name     string
+	interval time.Duration
+	address  string
+	prefix   string
+
+	mux      sync.Mutex
+	counters map[string]float64
+	gauges   map[string]float64
+	timers   map[string]*timerStats
+}
+
+// NewStatsDCollector создает сборщик, принимающий метрики по протоколу StatsD.
+//
+// Опции:
+//   - address - адрес UDP для приема метрик, по умолчанию 127.0.0.1:8125
+//   - prefix - префикс идентификаторов принятых метрик
+func NewStatsDCollector(settings Settings) (Collector, error) {
+	options := statsDOptions{Address: defaultStatsDAddress}
+	if err := DecodeOptions(settings.Options, &options); err != nil {
+		return nil, err
+	}
+
+	if _, err := net.ResolveUDPAddr("udp", options.Address); err != nil {
+		return nil, fmt.Errorf("invalid statsd address %s: %w", options.Address, err)
+	}
+
+	return &statsDCollector{
+		name:     settings.Name,
+		interval: settings.Interval,
+		address:  options.Address,
+		prefix:   options.Prefix,
+		counters: map[string]float64{},
+		gauges:   map[string]float64{},
+		timers:   map[string]*timerStats{},
+	}, nil
+}
+
+func (c *statsDCollector) Name() string {
+	return c.name
+}
+
+func (c *statsDCollector) Interval() time.Duration {
+	return c.interval
+}
+
+// Run принимает пакеты StatsD до отмены контекста.
+func (c *statsDCollector) Run(ctx context.Context) error {
+	conn, err := net.ListenPacket("udp", c.address)
+	if err != nil {
+		return fmt.Errorf("cannot listen statsd address %s: %w", c.address, err)
+	}
+
+	logger.Log.Info("statsd listener started", zap.String("address", conn.LocalAddr().String()))
+
+	return c.serve(ctx, conn)
+}
+
+func (c *statsDCollector) serve(ctx context.Context, conn net.PacketConn) error {
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buf := make([]byte, statsDMaxPacketSize)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			logger.Log.Error("error reading statsd packet", zap.Error(err))
+			continue
+		}
+		c.handlePacket(string(buf[:n]))
+	}
+}
+
+func (c *statsDCollector) handlePacket(packet string) {
+	for _, line := range strings.Split(packet, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		sample, err := parseStatsDLine(line)
+		if err != nil {
+			logger.Log.Debug("invalid statsd line skipped", zap.String("line", line), zap.Error(err))
+			continue
+		}
+
+		mID := MetricID(c.prefix, sample.name)
+		if !metrics.IsValidID(mID) {
+			logger.Log.Debug("invalid statsd metric name skipped", zap.String("line", line), zap.String("id", mID))
+			continue
+		}
+		c.add(mID, sample)
+	}
+}
+
+// add добавляет значение к агрегату метрики с идентификатором mID
+func (c *statsDCollector) add(mID string, sample statsDSample) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	switch sample.mType {
+	case statsDCounter:
+		c.counters[mID] += sample.value / sample.sampleRate
+	case statsDGauge:
+		if sample.relative {
+			c.gauges[mID] += sample.value
+		} else {
+			c.gauges[mID] = sample.value
+		}
+	case statsDTimer, statsDHisto:
+		stats, ok := c.timers[mID]
+		if !ok {
+			stats = &timerStats{min: sample.value, max: sample.value}
+			c.timers[mID] = stats
+		}
+		stats.count += 1 / sample.sampleRate
+		stats.sum += sample.value
+		stats.min = math.Min(stats.min, sample.value)
+		stats.max = math.Max(stats.max, sample.value)
+		stats.seen++
+	}
+}
+
+func (c *statsDCollector) Collect(_ context.Context) ([]*metrics.Metric, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	result := []*metrics.Metric{}
+	deltas := map[string]int64{}
+	errs := []error{}
+
+	for mID, value := range c.counters {
+		// дробная часть (из-за частоты выборки) переносится на следующий сбор
+		delta := math.Trunc(value)
+		c.counters[mID] = value - delta
+		if delta != 0 {
+			deltas[mID] = int64(delta)
+		}
+	}
+
+	for mID, value := range c.gauges {
+		metric, err := NewGaugeMetric(mID, value)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		result = append(result, metric)
+	}
+
+	counters, err := counterMetrics(deltas)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	result = append(result, counters...)
+
+	return result, errors.Join(errs...)
+}
+
+// Flush отдает статистику таймеров, накопленную с предыдущего вызова Flush, и сбрасывает ее
+func (c *statsDCollector) Flush() ([]*metrics.Metric, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	result := []*metrics.Metric{}
+	deltas := map[string]int64{}
+	errs := []error{}
+
+	for mID, stats := range c.timers {
+		timerMetrics := map[string]float64{
+			mID + "Min":  stats.min,
+			mID + "Max":  stats.max,
+			mID + "Mean": stats.sum / float64(stats.seen),
+		}
+		for timerID, value := range timerMetrics {
+			metric, err := NewGaugeMetric(timerID, value)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			result = append(result, metric)
+		}
+		deltas[mID+"Count"] += int64(math.Round(stats.count))
+	}
+	c.timers = map[string]*timerStats{}
+
+	counters, err := counterMetrics(deltas)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	result = append(result, counters...)
+
+	return result, errors.Join(errs...)
+}
+
+// parseStatsDLine разбирает строку вида name:value|type[|@rate][|#tags]
+func parseStatsDLine(line string) (statsDSample, error) {
+	name, rest, ok := strings.Cut(line, ":")
+	if !ok || name == "" {
+		return statsDSample{}, errors.New("metric name is not filled")
+	}
+
+	fields := strings.Split(rest, "|")
+	if len(fields) < 2 {
+		return statsDSample{}, errors.New("metric type is not filled")
+	}
+
+	sample := statsDSample{name: name, mType: fields[1], sampleRate: 1}
+
+	switch sample.mType {
+	case statsDCounter, statsDGauge, statsDTimer, statsDHisto:
+	default:
+		return statsDSample{}, fmt.Errorf("unsupported metric type %q", sample.mType)
+	}
+
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return statsDSample{}, fmt.Errorf("invalid metric value %q", fields[0])
+	}
+	sample.value = value
+	sample.relative = sample.mType == statsDGauge && (strings.HasPrefix(fields[0], "+") || strings.HasPrefix(fields[0], "-"))
+
+	for _, field := range fields[2:] {
+		if !strings.HasPrefix(field, "@") {
+			continue // теги и прочие расширения игнорируются
+		}
+		rate, err := strconv.ParseFloat(field[1:], 64)
+		if err != nil || rate <= 0 || rate > 1 {
+			return statsDSample{}, fmt.Errorf("invalid sample rate %q", field)
+		}
+		sample.sampleRate = rate
+	}
+
+	return sample, nil
+}
@@ -0,0 +1,164 @@
+package collector
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+func TestParseStatsDLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    statsDSample
+		wantErr bool
+	}{
+		{name: "Счетчик", line: "api.requests:1|c",
+			want: statsDSample{name: "api.requests", value: 1, mType: "c", sampleRate: 1}},
+		{name: "Счетчик с частотой выборки и тегами", line: "api.requests:2|c|@0.5|#env:prod",
+			want: statsDSample{name: "api.requests", value: 2, mType: "c", sampleRate: 0.5}},
+		{name: "Gauge", line: "queue.size:15.5|g",
+			want: statsDSample{name: "queue.size", value: 15.5, mType: "g", sampleRate: 1}},
+		{name: "Относительный gauge", line: "queue.size:-3|g",
+			want: statsDSample{name: "queue.size", value: -3, mType: "g", sampleRate: 1, relative: true}},
+		{name: "Таймер", line: "db.query:320|ms|@0.1",
+			want: statsDSample{name: "db.query", value: 320, mType: "ms", sampleRate: 0.1}},
+		{name: "Нет типа", line: "api.requests:1", wantErr: true},
+		{name: "Нет имени", line: ":1|c", wantErr: true},
+		{name: "Неподдерживаемый тип", line: "users:42|s", wantErr: true},
+		{name: "Некорректное значение", line: "api.requests:abc|c", wantErr: true},
+		{name: "Некорректная частота", line: "api.requests:1|c|@2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sample, err := parseStatsDLine(tt.line)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, sample)
+		})
+	}
+}
+
+func collectByID(t *testing.T, c Collector) map[string]*metrics.Metric {
+	t.Helper()
+
+	collected, err := c.Collect(context.Background())
+	require.NoError(t, err)
+
+	result := map[string]*metrics.Metric{}
+	for _, metric := range collected {
+		result[metric.ID] = metric
+	}
+	return result
+}
+
+func TestStatsDCollector_Aggregation(t *testing.T) {
+	c, err := NewStatsDCollector(Settings{Name: StatsDCollectorName})
+	require.NoError(t, err)
+	statsd := c.(*statsDCollector)
+
+	statsd.handlePacket("api.requests:1|c\napi.requests:3|c|@0.5\n" +
+		"queue.size:10|g\nqueue.size:+5|g\n" +
+		"db.query:100|ms\ndb.query:300|ms|@0.5\n" +
+		"2xx:1|c\nbroken")
+
+	got := collectByID(t, c)
+
+	require.Contains(t, got, "ApiRequests")
+	assert.Equal(t, metrics.Counter, got["ApiRequests"].MType)
+	assert.Equal(t, int64(7), *got["ApiRequests"].Delta)
+
+	require.Contains(t, got, "QueueSize")
+	assert.Equal(t, 15.0, *got["QueueSize"].Value)
+
+	assert.NotContains(t, got, "DbQueryMin", "статистика таймеров отдается при отправке пакета")
+	assert.NotContains(t, got, "2xx", "имя, из которого не получается идентификатор, пропускается")
+
+	timers := flushByID(t, statsd)
+	assert.Equal(t, 100.0, *timers["DbQueryMin"].Value)
+	assert.Equal(t, 300.0, *timers["DbQueryMax"].Value)
+	assert.Equal(t, 200.0, *timers["DbQueryMean"].Value)
+	assert.Equal(t, int64(3), *timers["DbQueryCount"].Delta)
+
+	// gauge сохраняется между сборами, счетчики и таймеры - нет
+	statsd.handlePacket("api.requests:1|c|@0.4")
+	got = collectByID(t, c)
+	assert.Equal(t, int64(2), *got["ApiRequests"].Delta)
+	assert.Equal(t, 15.0, *got["QueueSize"].Value)
+	assert.Empty(t, flushByID(t, statsd))
+
+	// дробный остаток 0.5 переносится: 0.5 + 2.5 = 3
+	statsd.handlePacket("api.requests:1|c|@0.4")
+	got = collectByID(t, c)
+	assert.Equal(t, int64(3), *got["ApiRequests"].Delta)
+}
+
+func TestStatsDCollector_TimersSeveralPolls(t *testing.T) {
+	c, err := NewStatsDCollector(Settings{Name: StatsDCollectorName})
+	require.NoError(t, err)
+	statsd := c.(*statsDCollector)
+
+	// несколько сборов за один интервал отправки
+	statsd.handlePacket("db.query:50|ms\ndb.query:150|ms")
+	collectByID(t, c)
+	statsd.handlePacket("db.query:400|ms")
+	collectByID(t, c)
+	collectByID(t, c)
+
+	timers := flushByID(t, statsd)
+	assert.Equal(t, 50.0, *timers["DbQueryMin"].Value, "статистика не сбрасывается между сборами")
+	assert.Equal(t, 400.0, *timers["DbQueryMax"].Value)
+	assert.Equal(t, 200.0, *timers["DbQueryMean"].Value)
+	assert.Equal(t, int64(3), *timers["DbQueryCount"].Delta)
+}
+
+func flushByID(t *testing.T, c Flusher) map[string]*metrics.Metric {
+	t.Helper()
+
+	flushed, err := c.Flush()
+	require.NoError(t, err)
+
+	result := map[string]*metrics.Metric{}
+	for _, metric := range flushed {
+		result[metric.ID] = metric
+	}
+	return result
+}
+
+func TestStatsDCollector_UDP(t *testing.T) {
+	c, err := NewStatsDCollector(Settings{Name: StatsDCollectorName, Options: map[string]any{"prefix": "App"}})
+	require.NoError(t, err)
+	statsd := c.(*statsDCollector)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- statsd.serve(ctx, conn) }()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.Write([]byte("logins:4|c"))
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		got := collectByID(t, c)
+		return got["AppLogins"] != nil && *got["AppLogins"].Delta == 4
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
@@ -0,0 +1,198 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/galogen13/yandex-go-metrics/internal/logger"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+// TextfileCollectorName - имя сборщика, читающего метрики из файлов каталога
+const TextfileCollectorName = "textfile"
+
+// Расширения файлов, которые читает сборщик textfile
+const (
+	textfilePromExt = ".prom" // текстовый формат Prometheus
+	textfileJSONExt = ".json" // массив метрик в формате metrics.Metric
+)
+
+// textfileLabel - метка с именем файла у собственных метрик сборщика
+const textfileLabel = "file"
+
+// textfileOptions - параметры сборщика textfile
+type textfileOptions struct {
+	Directory string        `mapstructure:"directory"` // каталог с файлами метрик
+	MaxAge    time.Duration `mapstructure:"max_age"`   // файлы, измененные раньше, пропускаются; 0 - без ограничения
+}
+
+// textfileCollector при каждом сборе читает файлы *.prom и *.json из каталога и отдает метрики из них.
+// Файлы записываются внешними задачами (cron, пакетные задания), запись должна быть атомарной:
+// файл пишется под временным именем с другим расширением и переименовывается.
+//
+// Значения счетчиков в файлах *.prom - накопительные итоги (как в формате Prometheus), агент отправляет
+// их изменение с прошлого сбора; итоги отслеживаются для каждого файла отдельно. Правила преобразования
+// гистограмм и сводок описаны у cumulativeConverter.
+//
+// Поле delta счетчиков в файлах *.json - приращение, как в metrics.Metric: оно отправляется как есть,
+// один раз после каждого изменения файла. Файлы, измененные до создания сборщика, считаются уже учтенными.
+//
+// Для каждого файла отдаются собственные метрики типа gauge с меткой file:
+//   - TextfileMtime - время изменения файла (секунды Unix);
+//   - TextfileStale - 1, если файл старше max_age и пропущен, иначе 0;
+//   - TextfileParseErrors - количество строк (метрик), которые не удалось разобрать при последнем чтении.
+type textfileCollector struct {
+	name      string
+	interval  time.Duration
+	directory string
+	maxAge    time.Duration
+	files     map[string]*textfileState // ключ - имя файла
+	started   time.Time
+	now       func() time.Time
+}
+
+// textfileState - состояние чтения одного файла
+type textfileState struct {
+	converter *cumulativeConverter // накопительные итоги счетчиков файла *.prom
+	consumed  time.Time            // время изменения, до которого счетчики файла *.json уже отправлены
+}
+
+// NewTextfileCollector создает сборщик, читающий метрики из файлов каталога.
+//
+// Опции:
+//   - directory - каталог с файлами метрик (обязательная)
+//   - max_age - максимальный возраст файла, например "10m"; по умолчанию не ограничен
+func NewTextfileCollector(settings Settings) (Collector, error) {
+	var options textfileOptions
+	if err := DecodeOptions(settings.Options, &options); err != nil {
+		return nil, err
+	}
+	if options.Directory == "" {
+		return nil, errors.New("textfile directory is not filled")
+	}
+	if options.MaxAge < 0 {
+		return nil, fmt.Errorf("invalid textfile max_age: %s", options.MaxAge)
+	}
+
+	return &textfileCollector{
+		name:      settings.Name,
+		interval:  settings.Interval,
+		directory: options.Directory,
+		maxAge:    options.MaxAge,
+		files:     map[string]*textfileState{},
+		started:   time.Now(),
+		now:       time.Now,
+	}, nil
+}
+
+func (c *textfileCollector) Name() string {
+	return c.name
+}
+
+func (c *textfileCollector) Interval() time.Duration {
+	return c.interval
+}
+
+func (c *textfileCollector) Collect(_ context.Context) ([]*metrics.Metric, error) {
+	entries, err := os.ReadDir(c.directory)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read textfile directory: %w", err)
+	}
+
+	now := c.now()
+	result := []*metrics.Metric{}
+	state := []*metrics.Metric{}
+	files := make(map[string]*textfileState, len(c.files))
+	errs := []error{}
+
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != textfilePromExt && ext != textfileJSONExt) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			// файл мог быть удален после чтения каталога
+			continue
+		}
+
+		file, ok := c.files[entry.Name()]
+		if !ok {
+			file = &textfileState{converter: newCumulativeConverter(), consumed: c.started}
+		}
+		files[entry.Name()] = file
+
+		stale := c.maxAge > 0 && now.Sub(info.ModTime()) > c.maxAge
+		var parseErrs []error
+		if !stale {
+			var fileMetrics []*metrics.Metric
+			fileMetrics, parseErrs = file.read(filepath.Join(c.directory, entry.Name()), info.ModTime())
+			result = append(result, fileMetrics...)
+		}
+		for _, parseErr := range parseErrs {
+			logger.Log.Warn("cannot parse textfile", zap.String("file", entry.Name()), zap.Error(parseErr))
+		}
+
+		staleValue := 0.0
+		if stale {
+			staleValue = 1
+		}
+		fileState, err := gaugeMetrics([]gaugeValue{
+			{prefix: "TextfileMtime", value: float64(info.ModTime().Unix())},
+			{prefix: "TextfileStale", value: staleValue},
+			{prefix: "TextfileParseErrors", value: float64(len(parseErrs))},
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, metric := range fileState {
+			metric.Labels = map[string]string{textfileLabel: entry.Name()}
+		}
+		state = append(state, fileState...)
+	}
+
+	// состояние удаленных файлов забывается
+	c.files = files
+
+	return append(result, state...), errors.Join(errs...)
+}
+
+// read читает метрики из файла в формате, определяемом расширением.
+// Ошибки разбора отдельных метрик не прерывают чтение файла.
+func (f *textfileState) read(path string, modTime time.Time) ([]*metrics.Metric, []error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	if filepath.Ext(path) == textfileJSONExt {
+		collected, errs := parseMetricsJSON(data)
+		changed := modTime.After(f.consumed)
+		if changed {
+			f.consumed = modTime
+		}
+
+		result := make([]*metrics.Metric, 0, len(collected))
+		for _, metric := range collected {
+			if metric.MType == metrics.Counter && !changed {
+				continue
+			}
+			result = append(result, metric)
+		}
+		return result, errs
+	}
+
+	samples, errs := parsePromText(data)
+	result, err := f.converter.convert(samples)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return result, errs
+}
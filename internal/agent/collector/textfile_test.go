@@ -0,0 +1,146 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+func TestTextfileCollector(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	writeFile := func(name, content string, modTime time.Time) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+
+	writeFile("backup.prom", "# TYPE backup_runs_total counter\nbackup_runs_total 3\nbackup_last_success 1\n", now)
+	writeFile("queue.json", `[{"id": "QueueSize", "type": "gauge", "value": 12}, {"id": "Bad", "type": "gauge"}]`, now)
+	writeFile("old.prom", "old_value 1\n", now.Add(-2*time.Hour))
+	writeFile("notes.txt", "ignored 1\n", now)
+	writeFile("broken.prom", "broken{\n", now)
+
+	c, err := NewTextfileCollector(Settings{Name: TextfileCollectorName, Interval: time.Second,
+		Options: map[string]any{"directory": dir, "max_age": "1h"}})
+	require.NoError(t, err)
+
+	collect := func() map[string]*metrics.Metric {
+		t.Helper()
+		collected, err := c.Collect(t.Context())
+		require.NoError(t, err)
+		result := map[string]*metrics.Metric{}
+		for _, metric := range collected {
+			result[metric.Key()] = metric
+		}
+		return result
+	}
+
+	got := collect()
+	assert.Equal(t, 1.0, *got["BackupLastSuccess"].Value)
+	assert.Equal(t, 12.0, *got["QueueSize"].Value)
+	assert.NotContains(t, got, "OldValue")
+	assert.NotContains(t, got, "Ignored")
+	assert.NotContains(t, got, "BackupRunsTotal", "при первом чтении дельта счетчика не отдается")
+
+	assert.Equal(t, 1.0, *got[`TextfileStale{file="old.prom"}`].Value)
+	assert.Equal(t, 0.0, *got[`TextfileStale{file="backup.prom"}`].Value)
+	assert.Equal(t, 1.0, *got[`TextfileParseErrors{file="broken.prom"}`].Value)
+	assert.Equal(t, 1.0, *got[`TextfileParseErrors{file="queue.json"}`].Value)
+	assert.Equal(t, 0.0, *got[`TextfileParseErrors{file="backup.prom"}`].Value)
+	assert.Equal(t, float64(now.Unix()), *got[`TextfileMtime{file="backup.prom"}`].Value)
+
+	writeFile("backup.prom", "# TYPE backup_runs_total counter\nbackup_runs_total 4\n", now)
+	got = collect()
+	assert.Equal(t, int64(1), *got["BackupRunsTotal"].Delta)
+
+	_, err = NewTextfileCollector(Settings{Name: TextfileCollectorName, Interval: time.Second})
+	assert.Error(t, err, "каталог обязателен")
+}
+
+func TestTextfileCollector_JSONCounterDelta(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "job.json")
+	writeJob := func(modTime time.Time) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(path, []byte(`{"id": "Runs", "type": "counter", "delta": 5}`), 0o644))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+
+	// файл, записанный до создания сборщика, считается учтенным
+	writeJob(time.Now().Add(-time.Minute))
+
+	c, err := NewTextfileCollector(Settings{Name: TextfileCollectorName, Interval: time.Second,
+		Options: map[string]any{"directory": dir}})
+	require.NoError(t, err)
+
+	runs := func() *int64 {
+		t.Helper()
+		collected, err := c.Collect(t.Context())
+		require.NoError(t, err)
+		for _, metric := range collected {
+			if metric.ID == "Runs" {
+				return metric.Delta
+			}
+		}
+		return nil
+	}
+
+	assert.Nil(t, runs())
+
+	modTime := time.Now().Add(time.Second)
+	writeJob(modTime)
+	got := runs()
+	require.NotNil(t, got)
+	assert.Equal(t, int64(5), *got, "delta отправляется как есть")
+	assert.Nil(t, runs(), "неизмененный файл не учитывается повторно")
+
+	writeJob(modTime.Add(time.Second))
+	got = runs()
+	require.NotNil(t, got)
+	assert.Equal(t, int64(5), *got)
+}
+
+func TestTextfileCollector_PromCountersPerFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name string, value int) {
+		t.Helper()
+		content := fmt.Sprintf("# TYPE jobs_total counter\njobs_total %d\n", value)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+
+	writeFile("a.prom", 10)
+	writeFile("b.prom", 100)
+
+	c, err := NewTextfileCollector(Settings{Name: TextfileCollectorName, Interval: time.Second,
+		Options: map[string]any{"directory": dir}})
+	require.NoError(t, err)
+
+	deltas := func() []int64 {
+		t.Helper()
+		collected, err := c.Collect(t.Context())
+		require.NoError(t, err)
+		result := []int64{}
+		for _, metric := range collected {
+			if metric.ID == "JobsTotal" {
+				result = append(result, *metric.Delta)
+			}
+		}
+		return result
+	}
+
+	assert.Empty(t, deltas())
+
+	writeFile("a.prom", 12)
+	writeFile("b.prom", 103)
+	assert.ElementsMatch(t, []int64{2, 3}, deltas(), "итоги одного счетчика в разных файлах не смешиваются")
+}
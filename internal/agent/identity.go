@@ -0,0 +1,50 @@
+package agent
+
+import (
+	"context"
+	"crypto/rand"
+)
+
+// batchIdentity - идентификатор пакета метрик. Он присваивается пакету при формировании
+// и не меняется при повторных отправках, в том числе из очереди на диске, поэтому сервер
+// не применяет повторно пакет, ответ на который не дошел до агента.
+type batchIdentity struct {
+	agentID string // идентификатор экземпляра агента
+	id      string // идентификатор пакета
+	seq     uint64 // порядковый номер пакета у агента
+}
+
+// newAgentID возвращает случайный идентификатор экземпляра агента
+func newAgentID() string {
+	return rand.Text()
+}
+
+// newBatchIdentity создает идентификатор очередного пакета агента agentID
+func newBatchIdentity(agentID string, seq uint64) batchIdentity {
+	return batchIdentity{agentID: agentID, id: rand.Text(), seq: seq}
+}
+
+// shard возвращает идентификатор части пакета, отправляемой серверу shardID в режиме shard:
+// серверы с общим хранилищем должны различать части одного пакета.
+func (identity batchIdentity) shard(shardID string) batchIdentity {
+	identity.id += "-" + shardID
+	return identity
+}
+
+// nextBatchIdentity создает идентификатор очередного пакета агента
+func (agent *Agent) nextBatchIdentity() batchIdentity {
+	return newBatchIdentity(agent.agentID, agent.batchSeq.Add(1))
+}
+
+type batchIdentityKey struct{}
+
+// withBatchIdentity возвращает контекст отправки пакета с идентификатором identity
+func withBatchIdentity(ctx context.Context, identity batchIdentity) context.Context {
+	return context.WithValue(ctx, batchIdentityKey{}, identity)
+}
+
+// batchIdentityFrom возвращает идентификатор отправляемого пакета из контекста
+func batchIdentityFrom(ctx context.Context) (batchIdentity, bool) {
+	identity, ok := ctx.Value(batchIdentityKey{}).(batchIdentity)
+	return identity, ok && identity.id != ""
+}
@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/galogen13/yandex-go-metrics/internal/agent/collector"
+	"github.com/galogen13/yandex-go-metrics/internal/config"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+// identitySender запоминает идентификаторы отправленных пакетов
+type identitySender struct {
+	identities []batchIdentity
+	err        error
+}
+
+func (s *identitySender) Send(ctx context.Context, _ []*metrics.Metric) error {
+	identity, _ := batchIdentityFrom(ctx)
+	s.identities = append(s.identities, identity)
+	return s.err
+}
+
+func (s *identitySender) Close() error {
+	return nil
+}
+
+func TestAgent_SpoolKeepsBatchIdentity(t *testing.T) {
+	agentConfig := config.AgentConfig{Host: "localhost:8080", SpoolDir: t.TempDir()}
+	agent, err := NewAgent(agentConfig, collector.NewRegistry())
+	require.NoError(t, err)
+
+	unavailable := &identitySender{err: fmt.Errorf("%w: timeout", ErrServerUnavailable)}
+	agent.sender = unavailable
+	agent.addCounter("Requests", 5)
+	agent.sendMetrics()
+
+	require.Len(t, unavailable.identities, 1)
+	first := unavailable.identities[0]
+	assert.NotEmpty(t, first.agentID)
+	assert.NotEmpty(t, first.id)
+	assert.Equal(t, uint64(1), first.seq)
+
+	available := &identitySender{}
+	agent.sender = available
+	agent.replaySpool()
+
+	require.Len(t, available.identities, 1)
+	assert.Equal(t, first, available.identities[0], "пакет из очереди отправляется с тем же идентификатором")
+
+	agent.addCounter("Requests", 1)
+	agent.sendMetrics()
+	require.Len(t, available.identities, 2)
+	assert.NotEqual(t, first.id, available.identities[1].id)
+	assert.Equal(t, uint64(2), available.identities[1].seq)
+}
+
+func TestUpstreamSender_ShardBatchIdentity(t *testing.T) {
+	var identities [2]*identitySender
+	senders := make([]sender, len(identities))
+	for i := range identities {
+		identities[i] = &identitySender{}
+		senders[i] = identities[i]
+	}
+	s := newUpstreamSenderWith(UpstreamShard, []string{"a:8080", "b:8080"}, senders)
+
+	identity := newBatchIdentity("agent", 1)
+	require.NoError(t, s.Send(withBatchIdentity(t.Context(), identity), testMetricsBatch(t, 50)))
+
+	require.Len(t, identities[0].identities, 1)
+	require.Len(t, identities[1].identities, 1)
+	assert.NotEqual(t, identities[0].identities[0].id, identities[1].identities[0].id,
+		"части пакета для разных серверов различаются")
+	assert.Equal(t, identity.seq, identities[0].identities[0].seq)
+}
@@ -0,0 +1,66 @@
+package agent
+
+import (
+	"fmt"
+	"maps"
+	"os"
+
+	"go.uber.org/zap"
+
+	"github.com/galogen13/yandex-go-metrics/internal/config"
+	"github.com/galogen13/yandex-go-metrics/internal/logger"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+// Метки, которые агент добавляет ко всем метрикам
+const (
+	LabelHost     = "host"     // имя хоста агента
+	LabelInstance = "instance" // экземпляр агента; по умолчанию совпадает с именем хоста
+)
+
+// agentLabels возвращает метки всех метрик агента: host, instance и дополнительные метки из конфигурации.
+// Дополнительные метки с именами host и instance замещают автоматические.
+func agentLabels(agentConfig config.AgentConfig) (map[string]string, error) {
+	labels := map[string]string{}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		logger.Log.Warn("cannot get host name, host label is not set", zap.Error(err))
+	} else {
+		labels[LabelHost] = hostname
+	}
+
+	instance := agentConfig.Instance
+	if instance == "" {
+		instance = hostname
+	}
+	if instance != "" {
+		labels[LabelInstance] = instance
+	}
+
+	for name, value := range agentConfig.Labels {
+		if !metrics.IsValidLabelName(name) {
+			return nil, fmt.Errorf("invalid label name: %q", name)
+		}
+		labels[name] = value
+	}
+
+	return labels, nil
+}
+
+// withLabels возвращает копии метрик пакета с метками агента.
+// Метки, уже заданные у метрики сборщиком, не замещаются.
+func withLabels(batch []*metrics.Metric, labels map[string]string) []*metrics.Metric {
+	if len(labels) == 0 {
+		return batch
+	}
+
+	result := make([]*metrics.Metric, 0, len(batch))
+	for _, metric := range batch {
+		labeled := *metric
+		labeled.Labels = maps.Clone(labels)
+		maps.Copy(labeled.Labels, metric.Labels)
+		result = append(result, &labeled)
+	}
+	return result
+}
@@ -0,0 +1,182 @@
+package agent
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/galogen13/yandex-go-metrics/internal/agent/collector"
+	"github.com/galogen13/yandex-go-metrics/internal/config"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+func TestAgentLabels(t *testing.T) {
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+
+	labels, err := agentLabels(config.AgentConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{LabelHost: hostname, LabelInstance: hostname}, labels)
+
+	labels, err = agentLabels(config.AgentConfig{Instance: "agent2", Labels: map[string]string{"env": "prod"}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{LabelHost: hostname, LabelInstance: "agent2", "env": "prod"}, labels)
+
+	_, err = agentLabels(config.AgentConfig{Labels: map[string]string{"bad-name": "x"}})
+	assert.Error(t, err)
+}
+
+func TestWithLabels(t *testing.T) {
+	plain := metrics.NewMetrics("Alloc", metrics.Gauge)
+	require.NoError(t, plain.UpdateValue(1.5))
+	own := metrics.NewMetrics("Requests", metrics.Counter)
+	require.NoError(t, own.UpdateValue(int64(2)))
+	own.Labels = map[string]string{"instance": "app", "path": "/"}
+
+	batch := withLabels([]*metrics.Metric{plain, own}, map[string]string{"host": "h1", "instance": "h1"})
+
+	assert.Equal(t, map[string]string{"host": "h1", "instance": "h1"}, batch[0].Labels)
+	assert.Equal(t, map[string]string{"host": "h1", "instance": "app", "path": "/"}, batch[1].Labels)
+	assert.Equal(t, 1.5, *batch[0].Value)
+	assert.Nil(t, plain.Labels, "исходная метрика не изменяется")
+}
+
+// counterDeltas возвращает накопленные агентом дельты счетчиков, просуммированные по идентификатору метрики
+func counterDeltas(agent *Agent) map[string]int64 {
+	deltas := map[string]int64{}
+	for _, counter := range agent.counterMetrics() {
+		deltas[counter.ID] += *counter.Delta
+	}
+	return deltas
+}
+
+// labeledCounter возвращает метрику типа counter с метками labels
+func labeledCounter(t *testing.T, mID string, delta int64, labels map[string]string) *metrics.Metric {
+	t.Helper()
+
+	metric := testCounter(t, mID, delta)
+	metric.Labels = labels
+	return metric
+}
+
+func TestAgent_LabeledCounters(t *testing.T) {
+	agent, err := NewAgent(config.AgentConfig{Host: "localhost:8080", Instance: "test"}, collector.NewRegistry())
+	require.NoError(t, err)
+
+	var sent []*metrics.Metric
+	agent.sender = funcSender(func(batch []*metrics.Metric) error {
+		sent = batch
+		return nil
+	})
+
+	agent.storeResult(metricsResult{name: "app", metrics: []*metrics.Metric{
+		labeledCounter(t, "Requests", 5, map[string]string{"code": "200"}),
+		labeledCounter(t, "Requests", 7, map[string]string{"code": "500"}),
+	}})
+	agent.storeResult(metricsResult{name: "app", metrics: []*metrics.Metric{
+		labeledCounter(t, "Requests", 1, map[string]string{"code": "200"}),
+	}})
+
+	batch, err := agent.snapshot()
+	require.NoError(t, err)
+
+	deltas := map[string]int64{}
+	for _, metric := range batch {
+		if metric.ID == "Requests" {
+			assert.Equal(t, "test", metric.Labels[LabelInstance])
+			deltas[metric.Labels["code"]] = *metric.Delta
+		}
+	}
+	assert.Equal(t, map[string]int64{"200": 6, "500": 7}, deltas, "счетчики с разными метками не суммируются")
+
+	agent.sendBatch(batch, agent.nextBatchIdentity())
+	require.Equal(t, batch, sent)
+	assert.Equal(t, int64(0), counterDeltas(agent)["Requests"], "доставленные дельты вычитаются по ключу метрики")
+}
+
+func TestAgent_CgroupCountersKeepLabels(t *testing.T) {
+	root := t.TempDir()
+	writeFile := func(path, content string) {
+		t.Helper()
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(root, path)), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, path), []byte(content), 0o644))
+	}
+	writeFile("cgroup.controllers", "io\n")
+	writeFile("system.slice/a.service/io.stat", "8:0 rbytes=1000 wbytes=0 rios=1 wios=0 dbytes=0 dios=0\n")
+	writeFile("system.slice/b.service/io.stat", "8:0 rbytes=1000 wbytes=0 rios=1 wios=0 dbytes=0 dios=0\n")
+
+	cgroups, err := collector.NewCgroupCollector(collector.Settings{Name: collector.CgroupCollectorName,
+		Options: map[string]any{"root": root, "cgroups": []any{"system.slice/*.service"}}})
+	require.NoError(t, err)
+
+	agent, err := NewAgent(config.AgentConfig{Host: "localhost:8080", Instance: "test"}, collector.NewRegistry())
+	require.NoError(t, err)
+
+	collect := func() {
+		t.Helper()
+		collected, err := cgroups.Collect(t.Context())
+		require.NoError(t, err)
+		agent.storeResult(metricsResult{name: collector.CgroupCollectorName, metrics: collected})
+	}
+
+	collect()
+	writeFile("system.slice/a.service/io.stat", "8:0 rbytes=1005 wbytes=0 rios=1 wios=0 dbytes=0 dios=0\n")
+	writeFile("system.slice/b.service/io.stat", "8:0 rbytes=1007 wbytes=0 rios=1 wios=0 dbytes=0 dios=0\n")
+	collect()
+
+	batch, err := agent.snapshot()
+	require.NoError(t, err)
+
+	readBytes := map[string]int64{}
+	for _, metric := range batch {
+		if metric.ID == "CgroupIOReadBytes" {
+			assert.Equal(t, "test", metric.Labels[LabelInstance])
+			readBytes[metric.Labels["cgroup"]] += *metric.Delta
+		}
+	}
+	assert.Equal(t, map[string]int64{"/system.slice/a.service": 5, "/system.slice/b.service": 7}, readBytes,
+		"счетчики контрольных групп доходят до сервера раздельно")
+}
+
+func TestAgent_ScrapeCountersKeepLabels(t *testing.T) {
+	var requests atomic.Int64
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		fmt.Fprintf(w, "# TYPE http_requests_total counter\n"+
+			"http_requests_total{code=\"200\"} %d\nhttp_requests_total{code=\"500\"} %d\n", 10*n, n)
+	}))
+	defer service.Close()
+
+	scrape, err := collector.NewScrapeCollector(collector.Settings{Name: collector.ScrapeCollectorName,
+		Options: map[string]any{"targets": []any{map[string]any{"name": "api", "url": service.URL}}}})
+	require.NoError(t, err)
+
+	agent, err := NewAgent(config.AgentConfig{Host: "localhost:8080", Instance: "test"}, collector.NewRegistry())
+	require.NoError(t, err)
+
+	for range 2 {
+		collected, err := scrape.Collect(t.Context())
+		require.NoError(t, err)
+		agent.storeResult(metricsResult{name: collector.ScrapeCollectorName, metrics: collected})
+	}
+
+	batch, err := agent.snapshot()
+	require.NoError(t, err)
+
+	deltas := map[string]int64{}
+	for _, metric := range batch {
+		if metric.ID == "HttpRequestsTotal" {
+			assert.Equal(t, "api", metric.Labels["job"])
+			assert.Equal(t, "test", metric.Labels[LabelInstance])
+			deltas[metric.Labels["code"]] += *metric.Delta
+		}
+	}
+	assert.Equal(t, map[string]int64{"200": 10, "500": 1}, deltas, "счетчики Prometheus с разными метками не суммируются")
+}
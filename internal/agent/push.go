@@ -0,0 +1,105 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/galogen13/yandex-go-metrics/internal/config"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+// Push однократно отправляет пакет метрик на сервер так же, как агент отправляет собранные метрики:
+// тем же протоколом, со сжатием, шифрованием и подписью из конфигурации и с метками агента.
+// Используется командой agent push для отправки метрик из скриптов развертывания и CI.
+func Push(ctx context.Context, agentConfig config.AgentConfig, batch []*metrics.Metric) error {
+	if len(batch) == 0 {
+		return errors.New("nothing to push")
+	}
+	for _, metric := range batch {
+		if err := metric.Check(true); err != nil {
+			return err
+		}
+	}
+
+	labels, err := agentLabels(agentConfig)
+	if err != nil {
+		return fmt.Errorf("cannot initialize labels: %w", err)
+	}
+
+	metricsSender, err := newSender(agentConfig, nil)
+	if err != nil {
+		return fmt.Errorf("cannot initialize sender: %w", err)
+	}
+	defer metricsSender.Close()
+
+	// повторные попытки отправки не должны применяться сервером дважды
+	ctx = withBatchIdentity(ctx, newBatchIdentity(newAgentID(), 1))
+	if err := metricsSender.Send(ctx, withLabels(batch, labels)); err != nil {
+		return fmt.Errorf("cannot push metrics: %w", err)
+	}
+	return nil
+}
+
+// NewPushMetric создает метрику из параметров командной строки.
+// Значение gauge - число с плавающей точкой, значение counter - целое число (дельта).
+func NewPushMetric(mType, mID, value string, labels map[string]string) (*metrics.Metric, error) {
+	metric := metrics.NewMetrics(mID, metrics.MetricType(mType))
+	metric.Labels = labels
+
+	switch metric.MType {
+	case metrics.Gauge:
+		gaugeValue, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gauge value %q: %w", value, err)
+		}
+		metric.Value = &gaugeValue
+	case metrics.Counter:
+		counterValue, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid counter value %q: %w", value, err)
+		}
+		metric.Delta = &counterValue
+	}
+
+	if err := metric.Check(true); err != nil {
+		return nil, err
+	}
+	return metric, nil
+}
+
+// ReadPushBatch читает пакет метрик в формате тела запроса /updates:
+// массив метрик или одну метрику в формате JSON.
+func ReadPushBatch(r io.Reader) ([]*metrics.Metric, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read metrics batch: %w", err)
+	}
+
+	data = bytes.TrimSpace(data)
+	var batch []*metrics.Metric
+	if len(data) > 0 && data[0] == '{' {
+		var metric metrics.Metric
+		err = json.Unmarshal(data, &metric)
+		batch = []*metrics.Metric{&metric}
+	} else {
+		err = json.Unmarshal(data, &batch)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode metrics batch: %w", err)
+	}
+
+	for i, metric := range batch {
+		if metric == nil {
+			return nil, fmt.Errorf("metric %d is null", i)
+		}
+		if err := metric.Check(true); err != nil {
+			return nil, fmt.Errorf("metric %d: %w", i, err)
+		}
+	}
+	return batch, nil
+}
@@ -0,0 +1,156 @@
+package agent
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/galogen13/yandex-go-metrics/internal/config"
+	"github.com/galogen13/yandex-go-metrics/internal/crypto"
+	addinfo "github.com/galogen13/yandex-go-metrics/internal/service/additional-info"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+	"github.com/galogen13/yandex-go-metrics/internal/validation"
+)
+
+func TestPush(t *testing.T) {
+	var received []*metrics.Metric
+	var hashValid bool
+	var batchID string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/updates", r.URL.Path)
+		assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		hashValid = r.Header.Get("HashSHA256") == validation.CalculateHMAC(body, "secret")
+		batchID = r.Header.Get(addinfo.HeaderBatchID)
+
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		require.NoError(t, err)
+		require.NoError(t, json.NewDecoder(reader).Decode(&received))
+	}))
+	defer server.Close()
+
+	agentConfig := config.AgentConfig{
+		Host:     strings.TrimPrefix(server.URL, "http://"),
+		Key:      "secret",
+		Instance: "deploy",
+	}
+
+	metric, err := NewPushMetric("gauge", "DeployDuration", "1.5", map[string]string{"env": "prod"})
+	require.NoError(t, err)
+	require.NoError(t, Push(t.Context(), agentConfig, []*metrics.Metric{metric}))
+
+	require.Len(t, received, 1)
+	assert.Equal(t, 1.5, *received[0].Value)
+	assert.Equal(t, "prod", received[0].Labels["env"])
+	assert.Equal(t, "deploy", received[0].Labels[LabelInstance])
+	assert.True(t, hashValid, "пакет подписан ключом из конфигурации")
+	assert.NotEmpty(t, batchID, "пакет передается с идентификатором")
+
+	assert.Error(t, Push(t.Context(), agentConfig, nil), "пустой пакет")
+
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer rejecting.Close()
+	agentConfig.Host = strings.TrimPrefix(rejecting.URL, "http://")
+	assert.Error(t, Push(t.Context(), agentConfig, []*metrics.Metric{metric}), "ошибка сервера возвращается")
+}
+
+func TestPush_HTTPS(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(data), 0600))
+		return path
+	}
+
+	caCert, caKey, err := crypto.GenerateCA("test CA", time.Hour)
+	require.NoError(t, err)
+	serverCert, serverKey, err := crypto.GenerateCertificate(caCert, caKey, "server", []string{"127.0.0.1"}, crypto.ServerCert, time.Hour)
+	require.NoError(t, err)
+	clientCert, clientKey, err := crypto.GenerateCertificate(caCert, caKey, "agent", nil, crypto.ClientCert, time.Hour)
+	require.NoError(t, err)
+
+	caPath := write("ca.pem", caCert)
+	serverConfig, err := crypto.ServerTLSConfig(write("server.pem", serverCert), write("server-key.pem", serverKey), caPath)
+	require.NoError(t, err)
+
+	var client string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client = r.TLS.PeerCertificates[0].Subject.CommonName
+	}))
+	server.TLS = serverConfig
+	server.StartTLS()
+	defer server.Close()
+
+	agentConfig := config.AgentConfig{
+		Host:        strings.TrimPrefix(server.URL, "https://"),
+		Transport:   TransportHTTPS,
+		TLSCAPath:   caPath,
+		TLSCertPath: write("agent.pem", clientCert),
+		TLSKeyPath:  write("agent-key.pem", clientKey),
+	}
+
+	metric, err := NewPushMetric("counter", "Deploys", "1", nil)
+	require.NoError(t, err)
+	require.NoError(t, Push(t.Context(), agentConfig, []*metrics.Metric{metric}))
+	assert.Equal(t, "agent", client, "агент предъявил клиентский сертификат")
+
+	agentConfig.Transport = TransportHTTP
+	assert.Error(t, Push(t.Context(), agentConfig, []*metrics.Metric{metric}), "сервер принимает только TLS")
+}
+
+func TestNewPushMetric(t *testing.T) {
+	tests := []struct {
+		name    string
+		mType   string
+		value   string
+		wantErr bool
+	}{
+		{name: "gauge", mType: "gauge", value: "1.5"},
+		{name: "counter", mType: "counter", value: "3"},
+		{name: "Дробное значение counter", mType: "counter", value: "1.5", wantErr: true},
+		{name: "Нечисловое значение", mType: "gauge", value: "abc", wantErr: true},
+		{name: "Неизвестный тип", mType: "histogram", value: "1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metric, err := NewPushMetric(tt.mType, "Foo", tt.value, nil)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, metrics.MetricType(tt.mType), metric.MType)
+		})
+	}
+}
+
+func TestReadPushBatch(t *testing.T) {
+	batch, err := ReadPushBatch(strings.NewReader(`[{"id": "Foo", "type": "gauge", "value": 1.5}, {"id": "Bar", "type": "counter", "delta": 2}]`))
+	require.NoError(t, err)
+	assert.Len(t, batch, 2)
+
+	batch, err = ReadPushBatch(strings.NewReader(`{"id": "Foo", "type": "gauge", "value": 1.5}`))
+	require.NoError(t, err)
+	assert.Len(t, batch, 1)
+
+	_, err = ReadPushBatch(strings.NewReader(`[{"id": "Foo", "type": "gauge"}]`))
+	assert.Error(t, err, "нет значения")
+
+	_, err = ReadPushBatch(strings.NewReader(`not json`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,214 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+
+	"github.com/galogen13/yandex-go-metrics/internal/config"
+	"github.com/galogen13/yandex-go-metrics/internal/logger"
+)
+
+// configWatchDelay - задержка перед перечитыванием измененного файла конфигурации.
+// Редакторы и системы управления конфигурацией записывают файл в несколько операций,
+// поэтому события за это время объединяются в одно перечитывание.
+const configWatchDelay = time.Second
+
+// reload перечитывает конфигурацию и применяет ее к агенту, таймерам и горутинам отправки.
+func (agent *Agent) reload(workers *sendWorkerPool, tickerPoll, tickerReport *time.Ticker) {
+	logger.Log.Info("reloading configuration")
+
+	newConfig, err := config.ReloadAgentConfig()
+	if err != nil {
+		logger.Log.Error("cannot reload configuration", zap.Error(err))
+		return
+	}
+	if err := agent.applyConfig(newConfig); err != nil {
+		logger.Log.Error("cannot apply configuration", zap.Error(err))
+		return
+	}
+
+	tickerPoll.Reset(time.Duration(newConfig.PollInterval) * time.Second)
+	tickerReport.Reset(time.Duration(newConfig.ReportInterval) * time.Second)
+	workers.resize(newConfig.RateLimit)
+
+	logger.Log.Info("configuration reloaded",
+		zap.String("Host", newConfig.Host),
+		zap.Strings("Hosts", newConfig.Hosts),
+		zap.String("Transport", newConfig.Transport),
+		zap.Int("PollInterval", newConfig.PollInterval),
+		zap.Int("ReportInterval", newConfig.ReportInterval),
+		zap.Int("RateLimit", newConfig.RateLimit),
+	)
+}
+
+// applyConfig применяет к работающему агенту перечитанную конфигурацию.
+// Без перезапуска меняются интервалы опроса и отправки, количество отправляющих горутин,
+// ограничения размера частей пакета, режим отправки только изменившихся метрик, ключ подписи, ключ шифрования и адреса серверов (вместе с протоколом и режимом работы
+// с несколькими серверами). Остальные параметры применяются только после перезапуска.
+//
+// Отправитель заменяется после завершения текущих отправок; накопленные метрики
+// и очередь на диске сохраняются. При ошибке агент продолжает работать с прежней конфигурацией.
+func (agent *Agent) applyConfig(newConfig config.AgentConfig) error {
+	if newConfig.PollInterval <= 0 || newConfig.ReportInterval <= 0 {
+		return fmt.Errorf("invalid intervals: poll %d, report %d", newConfig.PollInterval, newConfig.ReportInterval)
+	}
+	if newConfig.RateLimit <= 0 {
+		return fmt.Errorf("invalid rate limit: %d", newConfig.RateLimit)
+	}
+
+	metricsSender, err := newSender(newConfig, agent.telemetry)
+	if err != nil {
+		return fmt.Errorf("cannot initialize sender: %w", err)
+	}
+
+	agent.muxSender.Lock()
+	previous := agent.sender
+	agent.sender = metricsSender
+	agent.muxSender.Unlock()
+
+	if err := previous.Close(); err != nil {
+		logger.Log.Warn("cannot close previous sender", zap.Error(err))
+	}
+	agent.carryPendingCounters(previous, metricsSender, newConfig)
+
+	agent.muxConfig.Lock()
+	defer agent.muxConfig.Unlock()
+	agent.config.Host = newConfig.Host
+	agent.config.Hosts = newConfig.Hosts
+	agent.config.UpstreamMode = newConfig.UpstreamMode
+	agent.config.Transport = newConfig.Transport
+	agent.config.PollInterval = newConfig.PollInterval
+	agent.config.ReportInterval = newConfig.ReportInterval
+	agent.config.RateLimit = newConfig.RateLimit
+	agent.config.BatchMaxMetrics = newConfig.BatchMaxMetrics
+	agent.config.BatchMaxSize = newConfig.BatchMaxSize
+	agent.config.SendChangedOnly = newConfig.SendChangedOnly
+	agent.config.HeartbeatEvery = newConfig.HeartbeatEvery
+	agent.config.Key = newConfig.Key
+	agent.config.CryptoKeyPath = newConfig.CryptoKeyPath
+	agent.config.TLSCAPath = newConfig.TLSCAPath
+	agent.config.TLSCertPath = newConfig.TLSCertPath
+	agent.config.TLSKeyPath = newConfig.TLSKeyPath
+
+	return nil
+}
+
+// carryPendingCounters передает новому отправителю дельты счетчиков, которые прежний отправитель
+// в режиме broadcast не доставил части серверов. Если новый отправитель работает в режиме broadcast,
+// дельты переходят к тем же серверам; если он отправляет пакеты одному серверу, дельты этого сервера
+// возвращаются к накопленным агентом. Остальные дельты нельзя доставить, не отправив их повторно
+// серверам, которые их уже приняли, поэтому они отбрасываются с предупреждением.
+func (agent *Agent) carryPendingCounters(previous, current sender, newConfig config.AgentConfig) {
+	group, ok := previous.(*upstreamSender)
+	if !ok {
+		return
+	}
+	pending := group.takeAllPending()
+
+	if currentGroup, ok := current.(*upstreamSender); ok {
+		pending = currentGroup.adoptPending(pending)
+	} else if hosts := senderHosts(newConfig); len(hosts) == 1 {
+		for _, counter := range pending[hosts[0]] {
+			agent.addCounterMetric(*counter)
+		}
+		delete(pending, hosts[0])
+	}
+
+	for host, counters := range pending {
+		logger.Log.Warn("undelivered counters of upstream server dropped",
+			zap.String("host", host), zap.Int("counters", len(counters)))
+	}
+}
+
+// currentConfig возвращает действующую конфигурацию агента
+func (agent *Agent) currentConfig() config.AgentConfig {
+	agent.muxConfig.RLock()
+	defer agent.muxConfig.RUnlock()
+	return agent.config
+}
+
+// sendWorkerPool - горутины, отправляющие метрики на сервер.
+// Количество горутин можно менять во время работы агента.
+type sendWorkerPool struct {
+	ctx     context.Context
+	wg      *sync.WaitGroup
+	jobs    <-chan sendJob
+	worker  func(ctx context.Context, wg *sync.WaitGroup, jobs <-chan sendJob)
+	cancels []context.CancelFunc
+}
+
+func newSendWorkerPool(ctx context.Context, wg *sync.WaitGroup, jobs <-chan sendJob,
+	worker func(ctx context.Context, wg *sync.WaitGroup, jobs <-chan sendJob)) *sendWorkerPool {
+	return &sendWorkerPool{ctx: ctx, wg: wg, jobs: jobs, worker: worker}
+}
+
+// resize запускает недостающие горутины или останавливает лишние.
+// Останавливаемая горутина завершает начатую отправку.
+func (p *sendWorkerPool) resize(size int) {
+	for len(p.cancels) < size {
+		ctx, cancel := context.WithCancel(p.ctx)
+		p.cancels = append(p.cancels, cancel)
+		p.wg.Add(1)
+		go p.worker(ctx, p.wg, p.jobs)
+	}
+	for len(p.cancels) > size {
+		last := len(p.cancels) - 1
+		p.cancels[last]()
+		p.cancels = p.cancels[:last]
+	}
+}
+
+// watchConfigFile сообщает в changed об изменениях файла конфигурации path.
+// Отслеживается каталог файла, чтобы не терять файл, замененный переименованием.
+func watchConfigFile(ctx context.Context, path string, changed chan<- struct{}) error {
+	if path == "" {
+		return errors.New("config file is not set")
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("cannot create config watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	path = filepath.Clean(path)
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("cannot watch config file: %w", err)
+	}
+
+	delay := time.NewTimer(configWatchDelay)
+	delay.Stop()
+	defer delay.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Clean(event.Name) == path && event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+				delay.Reset(configWatchDelay)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logger.Log.Warn("config watcher error", zap.Error(err))
+		case <-delay.C:
+			select {
+			case changed <- struct{}{}:
+			default:
+				// перечитывание уже запрошено
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
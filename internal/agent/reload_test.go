@@ -0,0 +1,156 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/galogen13/yandex-go-metrics/internal/agent/collector"
+	"github.com/galogen13/yandex-go-metrics/internal/config"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+func TestAgent_ApplyConfig(t *testing.T) {
+	agentConfig := config.AgentConfig{Host: "localhost:8080", PollInterval: 2, ReportInterval: 10, RateLimit: 1}
+	agent, err := NewAgent(agentConfig, collector.NewRegistry())
+	require.NoError(t, err)
+
+	previous := &fakeSender{}
+	agent.sender = previous
+	agent.addCounter("Requests", 5)
+
+	t.Run("Некорректная конфигурация не применяется", func(t *testing.T) {
+		invalid := agentConfig
+		invalid.ReportInterval = 0
+		assert.Error(t, agent.applyConfig(invalid))
+
+		invalid = agentConfig
+		invalid.Transport = "udp"
+		assert.Error(t, agent.applyConfig(invalid))
+
+		assert.Same(t, previous, agent.sender)
+		assert.Equal(t, agentConfig, agent.currentConfig())
+	})
+
+	t.Run("Новая конфигурация применяется", func(t *testing.T) {
+		updated := agentConfig
+		updated.Host = "metrics.local:8080"
+		updated.PollInterval = 1
+		updated.RateLimit = 4
+		updated.Key = "rotated"
+		updated.SpoolDir = "/var/spool/agent"
+		require.NoError(t, agent.applyConfig(updated))
+
+		assert.NotSame(t, previous, agent.sender)
+		current := agent.currentConfig()
+		assert.Equal(t, "metrics.local:8080", current.Host)
+		assert.Equal(t, 1, current.PollInterval)
+		assert.Equal(t, 4, current.RateLimit)
+		assert.Equal(t, "rotated", current.Key)
+		assert.Empty(t, current.SpoolDir, "очередь на диске меняется только при перезапуске")
+		assert.Equal(t, map[string]int64{"Requests": 5}, counterDeltas(agent), "накопленные дельты сохраняются")
+	})
+}
+
+func TestSendWorkerPool_Resize(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	var running atomic.Int64
+	var wg sync.WaitGroup
+	jobs := make(chan sendJob)
+	pool := newSendWorkerPool(ctx, &wg, jobs, func(ctx context.Context, wg *sync.WaitGroup, _ <-chan sendJob) {
+		defer wg.Done()
+		running.Add(1)
+		defer running.Add(-1)
+		<-ctx.Done()
+	})
+
+	pool.resize(3)
+	assert.Eventually(t, func() bool { return running.Load() == 3 }, time.Second, 10*time.Millisecond)
+
+	pool.resize(1)
+	assert.Eventually(t, func() bool { return running.Load() == 1 }, time.Second, 10*time.Millisecond)
+
+	cancel()
+	wg.Wait()
+	assert.Zero(t, running.Load())
+}
+
+func TestWatchConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "agent.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"poll_interval": "2s"}`), 0o644))
+
+	changed := make(chan struct{}, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- watchConfigFile(t.Context(), path, changed)
+	}()
+
+	// время на подписку на события каталога
+	time.Sleep(200 * time.Millisecond)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other.json"), []byte("{}"), 0o644))
+	select {
+	case <-changed:
+		t.Fatal("changes of other files must be ignored")
+	case <-time.After(2 * configWatchDelay):
+	}
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"poll_interval": "1s"}`), 0o644))
+	select {
+	case <-changed:
+	case err := <-done:
+		t.Fatalf("watcher stopped: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("config change is not detected")
+	}
+}
+
+func TestAgent_ApplyConfigCarriesPendingCounters(t *testing.T) {
+	agentConfig := config.AgentConfig{
+		Hosts:          []string{"server1:8080", "server2:8080"},
+		UpstreamMode:   UpstreamBroadcast,
+		PollInterval:   2,
+		ReportInterval: 10,
+		RateLimit:      1,
+	}
+	agent, err := NewAgent(agentConfig, collector.NewRegistry())
+	require.NoError(t, err)
+
+	// второй сервер не получил пакет, принятый первым
+	group, fakes := newTestUpstreams(t, UpstreamBroadcast, 2)
+	fakes[1].err = fmt.Errorf("%w: connection refused", ErrServerUnavailable)
+	require.NoError(t, group.Send(t.Context(), []*metrics.Metric{testCounter(t, "Requests", 5)}))
+	agent.sender = group
+
+	t.Run("Дельты переходят к тому же серверу группы", func(t *testing.T) {
+		require.NoError(t, agent.applyConfig(agentConfig))
+
+		carried, ok := agent.sender.(*upstreamSender)
+		require.True(t, ok)
+		pending := carried.takeAllPending()
+		require.Contains(t, pending, "server2:8080")
+		assert.Equal(t, int64(5), *pending["server2:8080"]["Requests"].Delta)
+		assert.Empty(t, counterDeltas(agent), "дельты не отправляются серверу, который их уже принял")
+
+		carried.adoptPending(pending)
+	})
+
+	t.Run("Дельты единственного сервера возвращаются к накопленным", func(t *testing.T) {
+		single := agentConfig
+		single.Hosts = []string{"server2:8080"}
+		require.NoError(t, agent.applyConfig(single))
+
+		assert.Equal(t, map[string]int64{"Requests": 5}, counterDeltas(agent))
+	})
+}
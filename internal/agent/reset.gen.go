@@ -4,8 +4,28 @@ package agent
 
 func (v *Agent) Reset() {
 
-	v.metrics = v.metrics[:0]
+	clear(v.gauges)
 
-	v.PollCount = 0
+	clear(v.counters)
+
+	v.collectors = v.collectors[:0]
+
+	v.sender = nil
+
+	if resetter, ok := any(v.aggregator).(interface{ Reset() }); ok && v.aggregator != nil {
+		resetter.Reset()
+	}
+
+	if resetter, ok := any(v.changes).(interface{ Reset() }); ok && v.changes != nil {
+		resetter.Reset()
+	}
+
+	if resetter, ok := any(v.telemetry).(interface{ Reset() }); ok && v.telemetry != nil {
+		resetter.Reset()
+	}
+
+	clear(v.labels)
+
+	v.agentID = ""
 
 }
@@ -0,0 +1,163 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/galogen13/yandex-go-metrics/internal/agent/collector"
+	"github.com/galogen13/yandex-go-metrics/internal/logger"
+)
+
+// collectorSchedule - расписание сбора метрик сборщиком
+type collectorSchedule struct {
+	interval time.Duration // интервал между сборами
+	timeout  time.Duration // максимальное время сбора
+	jitter   time.Duration // максимальная случайная задержка сбора
+	align    bool          // сборы выравниваются по границам интервала по часам
+}
+
+// collectorSchedule возвращает расписание сборщика c из действующей конфигурации,
+// поэтому изменения interval и poll_interval при перечитывании применяются без пересоздания сборщика.
+// Сборщики без собственного интервала собираются с интервалом poll_interval.
+func (agent *Agent) collectorSchedule(c collector.Collector) collectorSchedule {
+	agentConfig := agent.currentConfig()
+	collectorConfig := agentConfig.Collectors[c.Name()]
+
+	schedule := collectorSchedule{
+		interval: collectorConfig.Interval,
+		timeout:  collectorConfig.Timeout,
+		jitter:   collectorConfig.Jitter,
+		align:    collectorConfig.Align,
+	}
+	if collectorConfig.Interval <= 0 {
+		schedule.interval = time.Duration(agentConfig.PollInterval) * time.Second
+	}
+	if schedule.interval <= 0 {
+		schedule.interval = time.Second
+	}
+	if schedule.timeout <= 0 {
+		schedule.timeout = schedule.interval
+	}
+	return schedule
+}
+
+// first возвращает время первого сбора: сразу или, при выравнивании, на ближайшей границе интервала
+func (s collectorSchedule) first(now time.Time) time.Time {
+	if !s.align {
+		return now
+	}
+	boundary := now.Truncate(s.interval)
+	if boundary.Equal(now) {
+		return now
+	}
+	return boundary.Add(s.interval)
+}
+
+// next возвращает время сбора, следующего за сбором, запланированным на planned и закончившимся в now.
+// Пропущенные из-за долгого сбора сроки не наверстываются.
+func (s collectorSchedule) next(planned, now time.Time) time.Time {
+	if s.align {
+		return now.Truncate(s.interval).Add(s.interval)
+	}
+	next := planned.Add(s.interval)
+	if next.Before(now) {
+		return now
+	}
+	return next
+}
+
+// delay возвращает случайную задержку сбора, чтобы агенты парка не опрашивали источники одновременно
+func (s collectorSchedule) delay() time.Duration {
+	if s.jitter <= 0 {
+		return 0
+	}
+	return rand.N(s.jitter)
+}
+
+// startCollectors запускает сбор метрик: каждый сборщик работает в своей горутине по своему расписанию,
+// поэтому медленный сборщик не задерживает остальные. Когда все сборщики отработали хотя бы раз,
+// агент считается собравшим метрики (см. /readyz).
+func (agent *Agent) startCollectors(ctx context.Context, wg *sync.WaitGroup) {
+	pending := &atomic.Int64{}
+	pending.Store(int64(len(agent.collectors)))
+	if len(agent.collectors) == 0 {
+		agent.polled.Store(true)
+	}
+
+	for _, c := range agent.collectors {
+		schedule := agent.collectorSchedule(c)
+		logger.Log.Info("collector scheduled",
+			zap.String("collector", c.Name()),
+			zap.Duration("interval", schedule.interval),
+			zap.Duration("timeout", schedule.timeout),
+			zap.Duration("jitter", schedule.jitter),
+			zap.Bool("align", schedule.align),
+		)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			agent.runCollector(ctx, c, func() {
+				if pending.Add(-1) == 0 {
+					agent.polled.Store(true)
+				}
+			})
+		}()
+	}
+}
+
+// runCollector собирает метрики сборщиком c по расписанию до отмены ctx.
+// collected вызывается после первого сбора.
+func (agent *Agent) runCollector(ctx context.Context, c collector.Collector, collected func()) {
+	schedule := agent.collectorSchedule(c)
+	planned := schedule.first(time.Now())
+
+	timer := time.NewTimer(time.Until(planned) + schedule.delay())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return
+		}
+
+		start := time.Now()
+		agent.storeResult(agent.collect(ctx, c, schedule.timeout))
+		if collected != nil {
+			collected()
+			collected = nil
+		}
+
+		now := time.Now()
+		schedule = agent.collectorSchedule(c)
+		if now.Sub(start) > schedule.interval {
+			logger.Log.Warn("collector is slower than its interval",
+				zap.String("collector", c.Name()), zap.Duration("interval", schedule.interval))
+		}
+		planned = schedule.next(planned, now)
+		timer.Reset(time.Until(planned) + schedule.delay())
+	}
+}
+
+// collect собирает метрики сборщиком c. Сбор, не завершившийся за timeout, прерывается отменой контекста.
+func (agent *Agent) collect(ctx context.Context, c collector.Collector, timeout time.Duration) metricsResult {
+	collectCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	collected, err := c.Collect(collectCtx)
+	agent.telemetry.pollDuration(c.Name(), time.Since(start))
+
+	if errors.Is(collectCtx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("collector timed out after %s: %w", timeout, errors.Join(err, collectCtx.Err()))
+	}
+	return metricsResult{name: c.Name(), metrics: collected, err: err}
+}
@@ -0,0 +1,182 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/galogen13/yandex-go-metrics/internal/agent/collector"
+	"github.com/galogen13/yandex-go-metrics/internal/config"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+// funcCollector - сборщик, собирающий метрики функцией collect
+type funcCollector struct {
+	name     string
+	interval time.Duration
+	collect  func(ctx context.Context) ([]*metrics.Metric, error)
+}
+
+func (c *funcCollector) Name() string {
+	return c.name
+}
+
+func (c *funcCollector) Interval() time.Duration {
+	return c.interval
+}
+
+func (c *funcCollector) Collect(ctx context.Context) ([]*metrics.Metric, error) {
+	return c.collect(ctx)
+}
+
+func funcCollectorFactory(collect func(ctx context.Context) ([]*metrics.Metric, error)) collector.Factory {
+	return func(settings collector.Settings) (collector.Collector, error) {
+		return &funcCollector{name: settings.Name, interval: settings.Interval, collect: collect}, nil
+	}
+}
+
+func TestCollectorSchedule(t *testing.T) {
+	base := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		schedule  collectorSchedule
+		now       time.Time
+		wantFirst time.Time
+		planned   time.Time
+		finished  time.Time
+		wantNext  time.Time
+	}{
+		{
+			name:      "Без выравнивания",
+			schedule:  collectorSchedule{interval: 15 * time.Second},
+			now:       base.Add(7 * time.Second),
+			wantFirst: base.Add(7 * time.Second),
+			planned:   base.Add(7 * time.Second),
+			finished:  base.Add(8 * time.Second),
+			wantNext:  base.Add(22 * time.Second),
+		},
+		{
+			name:      "Сбор дольше интервала",
+			schedule:  collectorSchedule{interval: 15 * time.Second},
+			now:       base,
+			wantFirst: base,
+			planned:   base,
+			finished:  base.Add(40 * time.Second),
+			wantNext:  base.Add(40 * time.Second),
+		},
+		{
+			name:      "С выравниванием по часам",
+			schedule:  collectorSchedule{interval: 15 * time.Second, align: true},
+			now:       base.Add(7 * time.Second),
+			wantFirst: base.Add(15 * time.Second),
+			planned:   base.Add(15 * time.Second),
+			finished:  base.Add(17 * time.Second),
+			wantNext:  base.Add(30 * time.Second),
+		},
+		{
+			name:      "С выравниванием, сбор дольше интервала",
+			schedule:  collectorSchedule{interval: 15 * time.Second, align: true},
+			now:       base,
+			wantFirst: base,
+			planned:   base,
+			finished:  base.Add(20 * time.Second),
+			wantNext:  base.Add(30 * time.Second),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantFirst, tt.schedule.first(tt.now))
+			assert.Equal(t, tt.wantNext, tt.schedule.next(tt.planned, tt.finished))
+		})
+	}
+
+	schedule := collectorSchedule{interval: time.Minute, jitter: 5 * time.Second}
+	for range 100 {
+		delay := schedule.delay()
+		assert.True(t, delay >= 0 && delay < 5*time.Second)
+	}
+}
+
+func TestAgent_CollectorScheduleFromConfig(t *testing.T) {
+	agentConfig := config.AgentConfig{
+		Host:         "localhost:8080",
+		PollInterval: 2,
+		Collectors: map[string]config.CollectorConfig{
+			"slow": {Interval: 30 * time.Second, Timeout: 10 * time.Second, Align: true, Jitter: 3 * time.Second},
+		},
+	}
+	registry := collector.NewRegistry()
+	registry.Register("fast", funcCollectorFactory(nil), true)
+	registry.Register("slow", funcCollectorFactory(nil), true)
+	agent, err := NewAgent(agentConfig, registry)
+	require.NoError(t, err)
+	require.Len(t, agent.collectors, 2)
+
+	assert.Equal(t, collectorSchedule{interval: 2 * time.Second, timeout: 2 * time.Second},
+		agent.collectorSchedule(agent.collectors[0]), "по умолчанию интервал и таймаут равны poll_interval")
+	assert.Equal(t, collectorSchedule{interval: 30 * time.Second, timeout: 10 * time.Second, jitter: 3 * time.Second, align: true},
+		agent.collectorSchedule(agent.collectors[1]))
+
+	agent.config.PollInterval = 5
+	assert.Equal(t, 5*time.Second, agent.collectorSchedule(agent.collectors[0]).interval,
+		"интервал по умолчанию меняется вместе с poll_interval")
+
+	agent.config.Collectors = map[string]config.CollectorConfig{"slow": {Interval: 1500 * time.Millisecond}}
+	assert.Equal(t, 1500*time.Millisecond, agent.collectorSchedule(agent.collectors[1]).interval,
+		"интервал сборщика берется из перечитанной конфигурации")
+}
+
+func TestAgent_StartCollectors(t *testing.T) {
+	agentConfig := config.AgentConfig{
+		Host:         "localhost:8080",
+		PollInterval: 1,
+		Collectors: map[string]config.CollectorConfig{
+			"slow": {Interval: time.Minute, Timeout: time.Second},
+		},
+	}
+
+	var fastRuns atomic.Int64
+
+	registry := collector.NewRegistry()
+	registry.Register("slow", funcCollectorFactory(func(ctx context.Context) ([]*metrics.Metric, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}), true)
+	registry.Register("fast", funcCollectorFactory(func(ctx context.Context) ([]*metrics.Metric, error) {
+		fastRuns.Add(1)
+		metric, err := collector.NewGaugeMetric("Fast", 1)
+		return []*metrics.Metric{metric}, err
+	}), true)
+
+	agent, err := NewAgent(agentConfig, registry)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	var wg sync.WaitGroup
+	agent.startCollectors(ctx, &wg)
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	require.Eventually(t, func() bool { return fastRuns.Load() > 0 }, 500*time.Millisecond, 10*time.Millisecond,
+		"медленный сборщик не задерживает быстрый")
+	assert.False(t, agent.polled.Load(), "медленный сборщик еще не отработал")
+
+	require.Eventually(t, agent.polled.Load, 3*time.Second, 10*time.Millisecond,
+		"медленный сборщик прерван по таймауту")
+	require.Eventually(t, func() bool { return fastRuns.Load() > 1 }, 3*time.Second, 10*time.Millisecond)
+
+	result := agent.collect(ctx, agent.collectors[0], 10*time.Millisecond)
+	assert.ErrorIs(t, result.err, context.DeadlineExceeded)
+
+	snapshot := agent.currentSnapshot()
+	require.Len(t, snapshot.Gauges, 1)
+	assert.Equal(t, "Fast", snapshot.Gauges[0].ID)
+}
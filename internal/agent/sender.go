@@ -0,0 +1,80 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/galogen13/yandex-go-metrics/internal/config"
+	"github.com/galogen13/yandex-go-metrics/internal/logger"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+	"github.com/galogen13/yandex-go-metrics/internal/validation"
+)
+
+const (
+	// TransportHTTP - отправка метрик на эндпоинт /updates по HTTP
+	TransportHTTP = "http"
+	// TransportHTTPS - отправка метрик на эндпоинт /updates по HTTPS
+	TransportHTTPS = "https"
+	// TransportGRPC - отправка метрик gRPC-сервису metrics.v1.Metrics
+	TransportGRPC = "grpc"
+)
+
+// sender доставляет пакеты метрик на сервер.
+// Если сервер недоступен, Send возвращает ошибку, обернутую в ErrServerUnavailable.
+// Если доставлена только часть пакета, Send возвращает *PartialSendError.
+type sender interface {
+	Send(ctx context.Context, batch []*metrics.Metric) error
+	Close() error
+}
+
+// metricsReporter - компонент агента, сообщающий метрики своего состояния.
+// Метрики добавляются в каждый отправляемый пакет.
+type metricsReporter interface {
+	Metrics() []*metrics.Metric
+}
+
+// newSender создает отправителя на сервер из конфигурации или,
+// если задано несколько серверов, отправителя на группу серверов.
+// Статистика отправки учитывается в собственных метриках агента tel.
+func newSender(agentConfig config.AgentConfig, tel *telemetry) (sender, error) {
+	hosts := senderHosts(agentConfig)
+	if len(hosts) == 1 {
+		return newHostSender(agentConfig, hosts[0], tel)
+	}
+
+	return newUpstreamSender(agentConfig, hosts, tel)
+}
+
+// senderHosts возвращает адреса серверов, на которые отправляются метрики
+func senderHosts(agentConfig config.AgentConfig) []string {
+	if len(agentConfig.Hosts) == 0 {
+		return []string{agentConfig.Host}
+	}
+	return agentConfig.Hosts
+}
+
+func newHostSender(agentConfig config.AgentConfig, host string, tel *telemetry) (sender, error) {
+	switch agentConfig.Transport {
+	case TransportHTTP, "":
+		return newHTTPSender(agentConfig, host, tel)
+	case TransportHTTPS:
+		return newHTTPSSender(agentConfig, host, tel)
+	case TransportGRPC:
+		return newGRPCSender(agentConfig, host, tel)
+	}
+	return nil, fmt.Errorf("unknown transport: %s", agentConfig.Transport)
+}
+
+// realIP возвращает адрес интерфейса агента, через который идут запросы на сервер host.
+// Адрес передается серверу в X-Real-IP для проверки доверенной подсети.
+// Если адрес определить не удалось, возвращает пустую строку.
+func realIP(host string) string {
+	ip, err := validation.OutboundIP(host)
+	if err != nil {
+		logger.Log.Warn("cannot determine agent address for X-Real-IP", zap.String("host", host), zap.Error(err))
+		return ""
+	}
+	return ip
+}
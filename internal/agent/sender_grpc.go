@@ -0,0 +1,174 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/galogen13/yandex-go-metrics/internal/config"
+	"github.com/galogen13/yandex-go-metrics/internal/crypto"
+	"github.com/galogen13/yandex-go-metrics/internal/logger"
+	"github.com/galogen13/yandex-go-metrics/internal/proto/metricspb"
+	"github.com/galogen13/yandex-go-metrics/internal/retry"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+	"github.com/galogen13/yandex-go-metrics/internal/validation"
+)
+
+// grpcChunkSize - максимальное количество метрик в одном сообщении.
+// Пакеты большего размера отправляются потоком UpdateMetricsStream.
+const grpcChunkSize = 500
+
+// grpcSender отправляет пакеты метрик gRPC-сервису metrics.v1.Metrics.
+// Сообщения подписываются HMAC в метаданных hashsha256 (если задан ключ),
+// в метаданных x-real-ip передается адрес агента.
+type grpcSender struct {
+	host      string
+	key       string
+	realIP    string
+	conn      *grpc.ClientConn
+	client    metricspb.MetricsClient
+	telemetry *telemetry
+}
+
+// newGRPCSender создает отправителя gRPC-сервису. Если задан tls_ca или клиентский сертификат,
+// соединение устанавливается по TLS с теми же правилами проверки, что и для транспорта https.
+func newGRPCSender(agentConfig config.AgentConfig, host string, tel *telemetry) (*grpcSender, error) {
+	transportCredentials := insecure.NewCredentials()
+	if agentConfig.TLSCAPath != "" || agentConfig.TLSCertPath != "" {
+		tlsConfig, err := crypto.ClientTLSConfig(agentConfig.TLSCAPath, agentConfig.TLSCertPath, agentConfig.TLSKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("cannot initialize TLS: %w", err)
+		}
+		transportCredentials = credentials.NewTLS(tlsConfig)
+	}
+
+	conn, err := grpc.NewClient(host, grpc.WithTransportCredentials(transportCredentials))
+	if err != nil {
+		return nil, fmt.Errorf("cannot create gRPC client: %w", err)
+	}
+
+	return &grpcSender{
+		host:      host,
+		key:       agentConfig.Key,
+		realIP:    realIP(host),
+		conn:      conn,
+		client:    metricspb.NewMetricsClient(conn),
+		telemetry: tel,
+	}, nil
+}
+
+func (s *grpcSender) Close() error {
+	return s.conn.Close()
+}
+
+// Send отправляет пакет метрик на сервер: одним запросом UpdateMetrics
+// или потоком UpdateMetricsStream, если пакет не помещается в одно сообщение.
+func (s *grpcSender) Send(ctx context.Context, batch []*metrics.Metric) error {
+
+	logger.Log.Debug("prepairing to send metrics batch",
+		zap.Any("metrics", batch),
+	)
+
+	requests := chunkRequests(metricspb.FromMetrics(batch), grpcChunkSize)
+	if identity, ok := batchIdentityFrom(ctx); ok {
+		// сервер берет идентификатор пакета из первого сообщения
+		requests[0].AgentId = identity.agentID
+		requests[0].BatchId = identity.id
+		requests[0].BatchSeq = identity.seq
+	}
+
+	messages := make([]proto.Message, 0, len(requests))
+	payloadSize := 0
+	for _, req := range requests {
+		messages = append(messages, req)
+		payloadSize += proto.Size(req)
+	}
+	s.telemetry.payload(payloadSize, 0, 0)
+	hash, err := validation.CalculateMessagesHMAC(s.key, messages...)
+	if err != nil {
+		return fmt.Errorf("cannot sign metrics batch: %w", err)
+	}
+	if hash != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, validation.HashMetadataKey, hash)
+	}
+	if s.realIP != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, validation.RealIPMetadataKey, s.realIP)
+	}
+
+	attempts := 0
+	resp, err := retry.DoWithResult(
+		ctx,
+		func() (*metricspb.UpdateMetricsResponse, error) {
+			attempts++
+			if len(requests) == 1 {
+				return s.client.UpdateMetrics(ctx, requests[0])
+			}
+			return s.sendStream(ctx, requests)
+		},
+		NewAgentErrorClassifier())
+	s.telemetry.retries(attempts)
+
+	if err != nil {
+		if isUnavailableCode(status.Code(err)) {
+			return fmt.Errorf("%w: %w", ErrServerUnavailable, err)
+		}
+		return fmt.Errorf("metrics rejected: %w", err)
+	}
+
+	logger.Log.Info("data sent", zap.String("grpc", s.host), zap.Int32("updated", resp.GetUpdated()))
+
+	return nil
+}
+
+func (s *grpcSender) sendStream(ctx context.Context, requests []*metricspb.UpdateMetricsRequest) (*metricspb.UpdateMetricsResponse, error) {
+	stream, err := s.client.UpdateMetricsStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, req := range requests {
+		if err := stream.Send(req); err != nil {
+			// настоящая причина ошибки возвращается из CloseAndRecv
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+	}
+
+	return stream.CloseAndRecv()
+}
+
+func chunkRequests(pbMetrics []*metricspb.Metric, size int) []*metricspb.UpdateMetricsRequest {
+	requests := make([]*metricspb.UpdateMetricsRequest, 0, len(pbMetrics)/size+1)
+	for start := 0; start < len(pbMetrics); start += size {
+		end := min(start+size, len(pbMetrics))
+		requests = append(requests, &metricspb.UpdateMetricsRequest{Metrics: pbMetrics[start:end]})
+	}
+	if len(requests) == 0 {
+		requests = append(requests, &metricspb.UpdateMetricsRequest{})
+	}
+	return requests
+}
+
+func isUnavailableCode(code codes.Code) bool {
+	switch code {
+	case codes.Unavailable,
+		codes.DeadlineExceeded,
+		codes.ResourceExhausted,
+		codes.Aborted,
+		codes.Internal:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/go-resty/resty/v2"
+	"go.uber.org/zap"
+
+	"github.com/galogen13/yandex-go-metrics/internal/compression"
+	"github.com/galogen13/yandex-go-metrics/internal/config"
+	"github.com/galogen13/yandex-go-metrics/internal/crypto"
+	"github.com/galogen13/yandex-go-metrics/internal/logger"
+	"github.com/galogen13/yandex-go-metrics/internal/retry"
+	addinfo "github.com/galogen13/yandex-go-metrics/internal/service/additional-info"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+	"github.com/galogen13/yandex-go-metrics/internal/validation"
+)
+
+// httpSender отправляет пакеты метрик на эндпоинт /updates.
+// Тело запроса сжимается gzip, шифруется публичным ключом (если задан) и подписывается HMAC (если задан ключ),
+// в заголовке X-Real-IP передается адрес агента.
+type httpSender struct {
+	scheme    string
+	host      string
+	key       string
+	realIP    string
+	encryptor *crypto.Encryptor
+	client    *resty.Client
+	telemetry *telemetry
+}
+
+func newHTTPSender(agentConfig config.AgentConfig, host string, tel *telemetry) (*httpSender, error) {
+	encryptor, err := crypto.NewEncryptor(agentConfig.CryptoKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot initialize encryptor: %w", err)
+	}
+
+	client := resty.New()
+	client.SetRedirectPolicy(resty.RedirectPolicyFunc(
+		func(req *http.Request, _ []*http.Request) error {
+			req.Method = http.MethodPost
+			return nil
+		}))
+
+	return &httpSender{
+		scheme:    "http",
+		host:      host,
+		key:       agentConfig.Key,
+		realIP:    realIP(host),
+		encryptor: encryptor,
+		client:    client,
+		telemetry: tel,
+	}, nil
+}
+
+// newHTTPSSender создает отправителя на эндпоинт /updates по HTTPS.
+// Сертификат сервера проверяется центром tls_ca (или системными центрами, если он не задан),
+// если заданы tls_cert и tls_key - агент предъявляет серверу клиентский сертификат.
+func newHTTPSSender(agentConfig config.AgentConfig, host string, tel *telemetry) (*httpSender, error) {
+	tlsConfig, err := crypto.ClientTLSConfig(agentConfig.TLSCAPath, agentConfig.TLSCertPath, agentConfig.TLSKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot initialize TLS: %w", err)
+	}
+
+	s, err := newHTTPSender(agentConfig, host, tel)
+	if err != nil {
+		return nil, err
+	}
+	s.scheme = "https"
+	s.client.SetTLSClientConfig(tlsConfig)
+
+	return s, nil
+}
+
+func (s *httpSender) Close() error {
+	return nil
+}
+
+// Send отправляет пакет метрик на сервер.
+func (s *httpSender) Send(ctx context.Context, batch []*metrics.Metric) error {
+
+	logger.Log.Debug("prepairing to send metrics batch",
+		zap.Any("metrics", batch),
+	)
+
+	bodyBytes, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("error while marshalling metrics: %w", err)
+	}
+
+	compressed, err := compression.GzipCompress(bodyBytes)
+	if err != nil {
+		return fmt.Errorf("error while gzip compress metrics: %w", err)
+	}
+
+	body, err := s.encryptor.Encrypt(compressed.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to encrypt data: %w", err)
+	}
+
+	encryptedLen := 0
+	if s.encryptor != nil {
+		encryptedLen = len(body)
+	}
+	s.telemetry.payload(len(bodyBytes), compressed.Len(), encryptedLen)
+
+	req := s.client.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetHeader("Content-Encoding", "gzip").
+		SetBody(body)
+
+	if s.key != "" {
+		hash := validation.CalculateHMAC(body, s.key)
+		req.SetHeader("HashSHA256", hash)
+	}
+
+	if s.realIP != "" {
+		req.SetHeader(validation.RealIPHeader, s.realIP)
+	}
+
+	if identity, ok := batchIdentityFrom(ctx); ok {
+		req.SetHeader(addinfo.HeaderAgentID, identity.agentID)
+		req.SetHeader(addinfo.HeaderBatchID, identity.id)
+		req.SetHeader(addinfo.HeaderBatchSeq, strconv.FormatUint(identity.seq, 10))
+	}
+
+	baseURL := &url.URL{
+		Scheme: s.scheme,
+		Host:   s.host,
+		Path:   "updates",
+	}
+	fullURL := baseURL.String()
+
+	attempts := 0
+	resp, err := retry.DoWithResult(
+		ctx,
+		func() (*resty.Response, error) {
+			attempts++
+			return req.Post(fullURL)
+		},
+		NewAgentErrorClassifier())
+	s.telemetry.retries(attempts)
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrServerUnavailable, err)
+	}
+
+	logger.Log.Info("data sent", zap.String("url", fullURL), zap.Int("respCode", resp.StatusCode()))
+
+	if resp.StatusCode() >= http.StatusInternalServerError {
+		return fmt.Errorf("%w: unexpected status code: %d", ErrServerUnavailable, resp.StatusCode())
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode())
+	}
+
+	return nil
+}
@@ -0,0 +1,451 @@
+// Пакет spool реализует очередь пакетов метрик на диске, в которую агент
+// складывает пакеты, не доставленные на сервер, чтобы отправить их позже в том же порядке.
+//
+// Очередь хранится в каталоге в виде файлов-сегментов. Каждый сегмент содержит
+// пакеты в формате JSON, по одному на строку. Новые пакеты дописываются в последний
+// сегмент, пока он не превысит ограничение по размеру.
+//
+// При превышении общего размера очереди или возраста сегмента самые старые сегменты удаляются.
+// Метрики типа gauge из удаленных пакетов теряются, а дельты метрик типа counter
+// суммируются и дописываются в очередь отдельным пакетом, поэтому значения счетчиков
+// на сервере остаются корректными.
+package spool
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/galogen13/yandex-go-metrics/internal/logger"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+const (
+	segmentExt = ".seg"
+	tmpExt     = ".tmp"
+
+	// maxLineSize - максимальный размер одного пакета в сегменте
+	maxLineSize = 64 << 20
+)
+
+// ErrStopReplay - ошибка, обернув которую, функция отправки прекращает Replay,
+// при этом текущий пакет считается отправленным и удаляется из очереди.
+var ErrStopReplay = errors.New("spool replay stopped")
+
+// Batch - пакет метрик, сохраненный в очереди
+type Batch struct {
+	CreatedAt time.Time         `json:"created_at"`
+	Metrics   []*metrics.Metric `json:"metrics"`
+
+	// Идентификатор пакета, присвоенный агентом: при повторной отправке из очереди
+	// сервер узнает уже примененный пакет. У пакетов из объединенных счетчиков не заполнен.
+	AgentID string `json:"agent_id,omitempty"`
+	ID      string `json:"id,omitempty"`
+	Seq     uint64 `json:"seq,omitempty"`
+}
+
+// NewBatch создает пакет из метрик с текущим временем создания
+func NewBatch(batchMetrics []*metrics.Metric) Batch {
+	return Batch{CreatedAt: time.Now(), Metrics: batchMetrics}
+}
+
+// Spool - очередь пакетов метрик на диске
+type Spool struct {
+	dir            string
+	maxSegmentSize int64
+	maxSize        int64
+	maxAge         time.Duration
+
+	mux       sync.Mutex
+	muxReplay sync.Mutex
+	// active - сегмент, в который дописываются новые пакеты
+	active string
+	// replaying - сегмент, который в данный момент отправляется на сервер
+	replaying string
+	nextSeq   uint64
+}
+
+type segment struct {
+	path    string
+	seq     uint64
+	size    int64
+	modTime time.Time
+}
+
+// New открывает (или создает) очередь в каталоге dir.
+// maxSegmentSize - размер сегмента в байтах, после которого начинается новый сегмент,
+// maxSize - максимальный размер очереди в байтах, maxAge - максимальный возраст сегмента.
+// Нулевые maxSize и maxAge отключают соответствующие ограничения.
+func New(dir string, maxSegmentSize, maxSize int64, maxAge time.Duration) (*Spool, error) {
+	if dir == "" {
+		return nil, errors.New("spool directory is not filled")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("cannot create spool directory: %w", err)
+	}
+
+	spool := &Spool{
+		dir:            dir,
+		maxSegmentSize: maxSegmentSize,
+		maxSize:        maxSize,
+		maxAge:         maxAge,
+		nextSeq:        1,
+	}
+
+	segments, err := spool.segments()
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) > 0 {
+		spool.nextSeq = segments[len(segments)-1].seq + 1
+	}
+
+	return spool, nil
+}
+
+// Empty сообщает, есть ли в очереди неотправленные пакеты
+func (s *Spool) Empty() bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	segments, err := s.segments()
+	if err != nil {
+		logger.Log.Error("cannot list spool segments", zap.Error(err))
+		return true
+	}
+	return len(segments) == 0
+}
+
+// Stats возвращает количество сегментов очереди и их общий размер в байтах
+func (s *Spool) Stats() (segments int, size int64, err error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	list, err := s.segments()
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, seg := range list {
+		size += seg.size
+	}
+	return len(list), size, nil
+}
+
+// Enqueue дописывает пакет в конец очереди и применяет ограничения по размеру и возрасту
+func (s *Spool) Enqueue(batch Batch) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if err := s.append(batch); err != nil {
+		return err
+	}
+
+	return s.trim()
+}
+
+// Replay отправляет пакеты очереди по порядку функцией send.
+// Отправленные пакеты удаляются из очереди. При первой ошибке отправка прекращается,
+// неотправленные пакеты остаются в очереди, и возвращается ошибка send
+// (если ошибка оборачивает ErrStopReplay, текущий пакет из очереди удаляется).
+// Если очередь уже отправляется другой горутиной, Replay ничего не делает.
+func (s *Spool) Replay(send func(Batch) error) error {
+	if !s.muxReplay.TryLock() {
+		return nil
+	}
+	defer s.muxReplay.Unlock()
+
+	for {
+		s.mux.Lock()
+		segments, err := s.segments()
+		if err != nil {
+			s.mux.Unlock()
+			return err
+		}
+		if len(segments) == 0 {
+			s.mux.Unlock()
+			return nil
+		}
+
+		path := segments[0].path
+		if path == s.active {
+			s.active = "" // новые пакеты пойдут в новый сегмент
+		}
+		s.replaying = path
+		batches, err := readSegment(path)
+		s.mux.Unlock()
+
+		if err != nil {
+			s.finishReplay(path, nil)
+			return err
+		}
+
+		for i, batch := range batches {
+			if err := send(batch); err != nil {
+				remaining := batches[i:]
+				if errors.Is(err, ErrStopReplay) {
+					remaining = batches[i+1:]
+				}
+				if werr := s.finishReplay(path, remaining); werr != nil {
+					return errors.Join(err, werr)
+				}
+				return err
+			}
+		}
+
+		if err := s.finishReplay(path, nil); err != nil {
+			return err
+		}
+	}
+}
+
+// finishReplay удаляет отправленный сегмент или перезаписывает его неотправленными пакетами
+func (s *Spool) finishReplay(path string, remaining []Batch) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.replaying = ""
+
+	if len(remaining) > 0 {
+		return writeSegment(path, remaining)
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("cannot remove spool segment: %w", err)
+	}
+	return nil
+}
+
+func (s *Spool) append(batch Batch) error {
+	line, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("cannot marshal spool batch: %w", err)
+	}
+	line = append(line, '\n')
+
+	if s.active != "" {
+		info, err := os.Stat(s.active)
+		if err != nil || info.Size()+int64(len(line)) > s.maxSegmentSize {
+			s.active = ""
+		}
+	}
+
+	if s.active == "" {
+		s.active = filepath.Join(s.dir, fmt.Sprintf("%020d%s", s.nextSeq, segmentExt))
+		s.nextSeq++
+	}
+
+	file, err := os.OpenFile(s.active, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("cannot open spool segment: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(line); err != nil {
+		return fmt.Errorf("cannot write spool segment: %w", err)
+	}
+
+	return file.Sync()
+}
+
+// trim удаляет самые старые сегменты, превышающие ограничения очереди.
+// Дельты счетчиков из удаленных сегментов дописываются в очередь отдельным пакетом.
+func (s *Spool) trim() error {
+	if s.maxSize <= 0 && s.maxAge <= 0 {
+		return nil
+	}
+
+	segments, err := s.segments()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, seg := range segments {
+		total += seg.size
+	}
+
+	evictedCounters := map[string]*metrics.Metric{}
+	evictedBatches := 0
+	now := time.Now()
+
+	// последний сегмент не удаляется никогда - в него пишутся новые пакеты
+	for _, seg := range segments[:max(0, len(segments)-1)] {
+		if seg.path == s.replaying || seg.path == s.active {
+			continue
+		}
+
+		expired := s.maxAge > 0 && now.Sub(seg.modTime) > s.maxAge
+		oversized := s.maxSize > 0 && total > s.maxSize
+		if !expired && !oversized {
+			break
+		}
+
+		batches, err := readSegment(seg.path)
+		if err != nil {
+			return err
+		}
+		for _, batch := range batches {
+			mergeCounters(evictedCounters, batch.Metrics)
+		}
+		evictedBatches += len(batches)
+
+		if err := os.Remove(seg.path); err != nil {
+			return fmt.Errorf("cannot remove spool segment: %w", err)
+		}
+		total -= seg.size
+	}
+
+	if evictedBatches == 0 {
+		return nil
+	}
+
+	logger.Log.Warn("spool limits exceeded, oldest batches dropped, counters merged",
+		zap.Int("batches", evictedBatches),
+		zap.Int("counters", len(evictedCounters)),
+	)
+
+	if len(evictedCounters) == 0 {
+		return nil
+	}
+
+	counters := make([]*metrics.Metric, 0, len(evictedCounters))
+	for _, key := range slices.Sorted(maps.Keys(evictedCounters)) {
+		counters = append(counters, evictedCounters[key])
+	}
+
+	return s.append(NewBatch(counters))
+}
+
+func (s *Spool) segments() ([]segment, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read spool directory: %w", err)
+	}
+
+	segments := make([]segment, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("cannot stat spool segment: %w", err)
+		}
+
+		segments = append(segments, segment{
+			path:    filepath.Join(s.dir, name),
+			seq:     seq,
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+	}
+
+	sort.Slice(segments, func(i, j int) bool { return segments[i].seq < segments[j].seq })
+
+	return segments, nil
+}
+
+func readSegment(path string) ([]Batch, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open spool segment: %w", err)
+	}
+	defer file.Close()
+
+	batches := []Batch{}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		var batch Batch
+		if err := json.Unmarshal(scanner.Bytes(), &batch); err != nil {
+			// например, строка, недописанная при аварийном завершении агента
+			logger.Log.Error("corrupted spool batch skipped", zap.String("segment", path), zap.Error(err))
+			continue
+		}
+		batches = append(batches, batch)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read spool segment: %w", err)
+	}
+
+	return batches, nil
+}
+
+func writeSegment(path string, batches []Batch) error {
+	tmpPath := path + tmpExt
+
+	file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("cannot create spool segment: %w", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, batch := range batches {
+		if err := encoder.Encode(batch); err != nil {
+			file.Close()
+			return fmt.Errorf("cannot write spool segment: %w", err)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("cannot write spool segment: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("cannot sync spool segment: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("cannot close spool segment: %w", err)
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// mergeCounters суммирует дельты метрик типа counter пакета в counters по ключу метрики:
+// метрики с одним идентификатором и разными метками суммируются раздельно
+func mergeCounters(counters map[string]*metrics.Metric, batchMetrics []*metrics.Metric) {
+	for _, metric := range batchMetrics {
+		if metric.MType != metrics.Counter || metric.Delta == nil {
+			continue
+		}
+
+		key := metric.Key()
+		if merged, ok := counters[key]; ok {
+			*merged.Delta += *metric.Delta
+			continue
+		}
+
+		delta := *metric.Delta
+		merged := metrics.NewMetrics(metric.ID, metrics.Counter)
+		merged.Delta = &delta
+		merged.Labels = maps.Clone(metric.Labels)
+		counters[key] = merged
+	}
+}
@@ -0,0 +1,155 @@
+package spool
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+func testBatch(t *testing.T, gauge float64, delta int64) Batch {
+	t.Helper()
+
+	gaugeMetric := metrics.NewMetrics("Alloc", metrics.Gauge)
+	require.NoError(t, gaugeMetric.UpdateValue(gauge))
+
+	counterMetric := metrics.NewMetrics("PollCount", metrics.Counter)
+	require.NoError(t, counterMetric.UpdateValue(delta))
+
+	return NewBatch([]*metrics.Metric{gaugeMetric, counterMetric})
+}
+
+func TestSpool_ReplayInOrder(t *testing.T) {
+	spool, err := New(t.TempDir(), 200, 0, 0)
+	require.NoError(t, err)
+	assert.True(t, spool.Empty())
+
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, spool.Enqueue(testBatch(t, float64(i), 1)))
+	}
+	assert.False(t, spool.Empty())
+
+	got := []float64{}
+	failOn := 3
+	send := func(batch Batch) error {
+		value := *batch.Metrics[0].Value
+		if int(value) == failOn {
+			return errors.New("server unavailable")
+		}
+		got = append(got, value)
+		return nil
+	}
+
+	require.Error(t, spool.Replay(send))
+	assert.Equal(t, []float64{1, 2}, got)
+
+	failOn = 0
+	require.NoError(t, spool.Replay(send))
+	assert.Equal(t, []float64{1, 2, 3, 4, 5}, got)
+	assert.True(t, spool.Empty())
+}
+
+func TestSpool_ReopenKeepsBatches(t *testing.T) {
+	dir := t.TempDir()
+
+	spool, err := New(dir, 1<<20, 0, 0)
+	require.NoError(t, err)
+	require.NoError(t, spool.Enqueue(testBatch(t, 1, 1)))
+
+	reopened, err := New(dir, 1<<20, 0, 0)
+	require.NoError(t, err)
+	require.NoError(t, reopened.Enqueue(testBatch(t, 2, 1)))
+
+	got := []float64{}
+	require.NoError(t, reopened.Replay(func(batch Batch) error {
+		got = append(got, *batch.Metrics[0].Value)
+		return nil
+	}))
+	assert.Equal(t, []float64{1, 2}, got)
+}
+
+func TestSpool_TrimMergesCounters(t *testing.T) {
+	// каждый пакет попадает в отдельный сегмент, в очереди помещается не больше двух сегментов
+	spool, err := New(t.TempDir(), 1, 300, time.Hour)
+	require.NoError(t, err)
+
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, spool.Enqueue(testBatch(t, float64(i), 10)))
+	}
+
+	var (
+		gauges   []float64
+		pollSum  int64
+		batchNum int
+	)
+	require.NoError(t, spool.Replay(func(batch Batch) error {
+		batchNum++
+		for _, metric := range batch.Metrics {
+			switch metric.MType {
+			case metrics.Gauge:
+				gauges = append(gauges, *metric.Value)
+			case metrics.Counter:
+				pollSum += *metric.Delta
+			}
+		}
+		return nil
+	}))
+
+	assert.Less(t, batchNum, 5)
+	assert.Contains(t, gauges, 5.0)
+	assert.NotContains(t, gauges, 1.0)
+	assert.Equal(t, int64(50), pollSum)
+}
+
+func TestSpool_TrimKeepsCounterLabels(t *testing.T) {
+	spool, err := New(t.TempDir(), 1, 300, time.Hour)
+	require.NoError(t, err)
+
+	for i := 1; i <= 5; i++ {
+		batch := testBatch(t, float64(i), 10)
+		labeled := metrics.NewMetrics("PollCount", metrics.Counter)
+		require.NoError(t, labeled.UpdateValue(int64(1)))
+		labeled.Labels = map[string]string{"cgroup": "app"}
+		batch.Metrics = append(batch.Metrics, labeled)
+		require.NoError(t, spool.Enqueue(batch))
+	}
+
+	sums := map[string]int64{}
+	require.NoError(t, spool.Replay(func(batch Batch) error {
+		for _, metric := range batch.Metrics {
+			if metric.MType == metrics.Counter {
+				sums[metric.Key()] += *metric.Delta
+			}
+		}
+		return nil
+	}))
+
+	assert.Equal(t, map[string]int64{"PollCount": 50, `PollCount{cgroup="app"}`: 5}, sums,
+		"объединенные счетчики сохраняют метки")
+}
+
+func TestSpool_StopReplay(t *testing.T) {
+	spool, err := New(t.TempDir(), 1<<20, 0, 0)
+	require.NoError(t, err)
+
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, spool.Enqueue(testBatch(t, float64(i), 1)))
+	}
+
+	err = spool.Replay(func(batch Batch) error {
+		return fmt.Errorf("%w: partially sent", ErrStopReplay)
+	})
+	require.ErrorIs(t, err, ErrStopReplay)
+
+	got := []float64{}
+	require.NoError(t, spool.Replay(func(batch Batch) error {
+		got = append(got, *batch.Metrics[0].Value)
+		return nil
+	}))
+	assert.Equal(t, []float64{2, 3}, got)
+}
@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/galogen13/yandex-go-metrics/internal/logger"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+// statusShutdownTimeout - время на завершение запросов к серверу состояния при остановке агента
+const statusShutdownTimeout = 5 * time.Second
+
+// statusSnapshot - ответ /snapshot: метрики, которые агент отправил бы сейчас
+type statusSnapshot struct {
+	// Gauges - последние значения метрик типа gauge с метками агента
+	Gauges []*metrics.Metric `json:"gauges"`
+	// Counters - накопленные и еще не доставленные на сервер дельты метрик типа counter с метками агента
+	Counters []*metrics.Metric `json:"counters"`
+}
+
+// ready сообщает, готов ли агент: метрики собраны хотя бы раз и хотя бы один пакет доставлен на сервер.
+// Если агент не готов, возвращает причину.
+func (agent *Agent) ready() (bool, string) {
+	switch {
+	case !agent.polled.Load():
+		return false, "metrics are not collected yet"
+	case !agent.delivered.Load():
+		return false, "metrics are not delivered yet"
+	}
+	return true, ""
+}
+
+// currentSnapshot возвращает собранные метрики и накопленные дельты счетчиков без изменения состояния агента:
+// в отличие от snapshot, агрегаты gauge не сбрасываются.
+func (agent *Agent) currentSnapshot() statusSnapshot {
+	agent.muxMetrics.Lock()
+	gauges := []*metrics.Metric{}
+	for _, c := range agent.collectors {
+		gauges = append(gauges, agent.gauges[c.Name()]...)
+	}
+	agent.muxMetrics.Unlock()
+
+	return statusSnapshot{Gauges: withLabels(gauges, agent.labels), Counters: agent.counterMetrics()}
+}
+
+// statusHandler возвращает обработчик HTTP-сервера состояния агента:
+//   - /healthz - агент работает (200 OK);
+//   - /readyz - агент готов (200 OK) или еще нет (503 Service Unavailable с причиной);
+//   - /snapshot - собранные метрики и накопленные дельты счетчиков в формате JSON.
+func (agent *Agent) statusHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("ok"))
+	})
+
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		if ok, reason := agent.ready(); !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(reason))
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+
+	mux.HandleFunc("GET /snapshot", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(agent.currentSnapshot()); err != nil {
+			logger.Log.Error("cannot encode agent snapshot", zap.Error(err))
+		}
+	})
+
+	return mux
+}
+
+// startStatusServer запускает HTTP-сервер состояния агента на адресе address.
+// Сервер останавливается при отмене ctx.
+func (agent *Agent) startStatusServer(ctx context.Context, wg *sync.WaitGroup, address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("cannot listen status address: %w", err)
+	}
+
+	server := &http.Server{Handler: agent.statusHandler()}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		logger.Log.Info("running agent status server", zap.String("address", listener.Addr().String()))
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Log.Error("agent status server stopped with error", zap.Error(err))
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), statusShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Log.Error("agent status server shutdown error", zap.Error(err))
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package agent
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/galogen13/yandex-go-metrics/internal/agent/collector"
+	"github.com/galogen13/yandex-go-metrics/internal/config"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+func TestAgent_StatusHandler(t *testing.T) {
+	agentConfig := config.AgentConfig{Host: "localhost:8080", Instance: "test"}
+	registry := collector.NewRegistry()
+	registry.Register(collector.RuntimeCollectorName, collector.NewRuntimeCollector, true)
+	agent, err := NewAgent(agentConfig, registry)
+	require.NoError(t, err)
+	agent.sender = funcSender(func([]*metrics.Metric) error { return nil })
+
+	server := httptest.NewServer(agent.statusHandler())
+	defer server.Close()
+
+	get := func(path string) (int, string) {
+		resp, err := server.Client().Get(server.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	status, _ := get("/healthz")
+	assert.Equal(t, http.StatusOK, status)
+
+	status, body := get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Contains(t, body, "not collected")
+
+	alloc, err := collector.NewGaugeMetric("Alloc", 12.5)
+	require.NoError(t, err)
+	agent.storeResult(metricsResult{name: collector.RuntimeCollectorName, metrics: []*metrics.Metric{alloc}})
+	agent.addCounter("Requests", 3)
+	agent.polled.Store(true)
+
+	status, body = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Contains(t, body, "not delivered")
+
+	status, body = get("/snapshot")
+	require.Equal(t, http.StatusOK, status)
+	var snapshot statusSnapshot
+	require.NoError(t, json.Unmarshal([]byte(body), &snapshot))
+	require.Len(t, snapshot.Counters, 1)
+	assert.Equal(t, "Requests", snapshot.Counters[0].ID)
+	assert.Equal(t, int64(3), *snapshot.Counters[0].Delta)
+	assert.Equal(t, "test", snapshot.Counters[0].Labels[LabelInstance])
+	require.Len(t, snapshot.Gauges, 1)
+	assert.Equal(t, 12.5, *snapshot.Gauges[0].Value)
+	assert.Equal(t, "test", snapshot.Gauges[0].Labels[LabelInstance])
+	assert.Equal(t, int64(3), counterDeltas(agent)["Requests"], "снимок не меняет состояние агента")
+
+	agent.sendBatch(testMetricsBatch(t, 1), agent.nextBatchIdentity())
+	status, _ = get("/readyz")
+	assert.Equal(t, http.StatusOK, status)
+}
@@ -0,0 +1,112 @@
+package agent
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/galogen13/yandex-go-metrics/internal/agent/collector"
+	"github.com/galogen13/yandex-go-metrics/internal/logger"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+// Собственные метрики агента типа counter
+const (
+	telemetryBatchesSent           = "AgentBatchesSent"           // доставленные пакеты
+	telemetryBatchesFailed         = "AgentBatchesFailed"         // недоставленные пакеты
+	telemetrySendRetries           = "AgentSendRetries"           // повторные попытки отправки
+	telemetryPayloadBytes          = "AgentPayloadBytes"          // размер пакетов до сжатия
+	telemetryPayloadGzipBytes      = "AgentPayloadGzipBytes"      // размер пакетов после сжатия
+	telemetryPayloadEncryptedBytes = "AgentPayloadEncryptedBytes" // размер пакетов после шифрования
+)
+
+// Собственные метрики агента типа gauge
+const (
+	telemetrySendLatency   = "AgentLastSendLatency" // длительность последней отправки в секундах
+	telemetryPollDuration  = "AgentPollDuration"    // + имя сборщика: длительность последнего сбора в секундах
+	telemetrySpoolBytes    = "AgentSpoolBytes"      // размер очереди на диске
+	telemetrySpoolSegments = "AgentSpoolSegments"   // количество сегментов очереди на диске
+)
+
+// telemetry собирает собственные метрики агента, которые отправляются вместе с обычным пакетом.
+// Дельты счетчиков передаются функции addCounter и доставляются как остальные счетчики агента,
+// значения gauge отдаются методом Metrics. Методы безопасны для вызова у nil.
+type telemetry struct {
+	addCounter func(mID string, delta int64)
+
+	mux    sync.Mutex
+	gauges map[string]float64
+}
+
+func newTelemetry(addCounter func(mID string, delta int64)) *telemetry {
+	return &telemetry{addCounter: addCounter, gauges: map[string]float64{}}
+}
+
+func (t *telemetry) count(mID string, delta int64) {
+	if t == nil || delta == 0 {
+		return
+	}
+	t.addCounter(mID, delta)
+}
+
+func (t *telemetry) set(mID string, value float64) {
+	if t == nil {
+		return
+	}
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.gauges[mID] = value
+}
+
+// sendAttempt учитывает результат отправки пакета и ее длительность
+func (t *telemetry) sendAttempt(err error, latency time.Duration) {
+	t.set(telemetrySendLatency, latency.Seconds())
+	if err != nil {
+		t.count(telemetryBatchesFailed, 1)
+		return
+	}
+	t.count(telemetryBatchesSent, 1)
+}
+
+// retries учитывает повторные попытки: attempts - общее количество попыток отправки
+func (t *telemetry) retries(attempts int) {
+	t.count(telemetrySendRetries, int64(max(0, attempts-1)))
+}
+
+// payload учитывает размеры пакета до сжатия, после сжатия и после шифрования.
+// Нулевые размеры (этап не выполнялся) не учитываются.
+func (t *telemetry) payload(raw, compressed, encrypted int) {
+	t.count(telemetryPayloadBytes, int64(raw))
+	t.count(telemetryPayloadGzipBytes, int64(compressed))
+	t.count(telemetryPayloadEncryptedBytes, int64(encrypted))
+}
+
+// pollDuration запоминает длительность сбора метрик сборщиком
+func (t *telemetry) pollDuration(collectorName string, duration time.Duration) {
+	t.set(collector.MetricID(telemetryPollDuration, collectorName), duration.Seconds())
+}
+
+// Metrics возвращает собственные метрики агента типа gauge
+func (t *telemetry) Metrics() []*metrics.Metric {
+	if t == nil {
+		return nil
+	}
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	result := make([]*metrics.Metric, 0, len(t.gauges))
+	for _, mID := range slices.Sorted(maps.Keys(t.gauges)) {
+		metric, err := collector.NewGaugeMetric(mID, t.gauges[mID])
+		if err != nil {
+			logger.Log.Error("error creating agent telemetry metric", zap.Error(err))
+			continue
+		}
+		result = append(result, metric)
+	}
+	return result
+}
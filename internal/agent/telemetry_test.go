@@ -0,0 +1,37 @@
+package agent
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTelemetry(t *testing.T) {
+	counters := map[string]int64{}
+	tel := newTelemetry(func(mID string, delta int64) { counters[mID] += delta })
+
+	tel.sendAttempt(nil, 250*time.Millisecond)
+	tel.sendAttempt(errors.New("connection refused"), time.Second)
+	tel.retries(3)
+	tel.retries(1)
+	tel.payload(1000, 300, 0)
+	tel.pollDuration("runtime", 10*time.Millisecond)
+
+	assert.Equal(t, map[string]int64{
+		telemetryBatchesSent:      1,
+		telemetryBatchesFailed:    1,
+		telemetrySendRetries:      2,
+		telemetryPayloadBytes:     1000,
+		telemetryPayloadGzipBytes: 300,
+	}, counters)
+
+	gauges := gaugeValues(tel)
+	assert.Equal(t, 1.0, gauges[telemetrySendLatency])
+	assert.Equal(t, 0.01, gauges["AgentPollDurationRuntime"])
+
+	var disabled *telemetry
+	disabled.sendAttempt(nil, time.Second)
+	assert.Empty(t, disabled.Metrics())
+}
@@ -0,0 +1,475 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/galogen13/yandex-go-metrics/internal/agent/collector"
+	"github.com/galogen13/yandex-go-metrics/internal/config"
+	"github.com/galogen13/yandex-go-metrics/internal/logger"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+// Режимы работы с несколькими серверами
+const (
+	// UpstreamFailover - пакет отправляется первому доступному серверу в порядке приоритета
+	UpstreamFailover = "failover"
+	// UpstreamBroadcast - пакет отправляется всем серверам
+	UpstreamBroadcast = "broadcast"
+	// UpstreamShard - метрики распределяются по серверам консистентным хешированием идентификатора
+	UpstreamShard = "shard"
+)
+
+const (
+	upstreamMinBackoff = time.Second
+	upstreamMaxBackoff = time.Minute
+	// upstreamReplicas - количество виртуальных узлов сервера в кольце хешей
+	upstreamReplicas = 128
+)
+
+// upstream - сервер группы и его состояние
+type upstream struct {
+	host   string
+	id     string // часть идентификаторов метрик состояния сервера
+	sender sender
+
+	mux          sync.Mutex
+	failures     int // количество ошибок доступности подряд
+	lastErr      error
+	backoffUntil time.Time
+	// pending - дельты счетчиков, принятые другими серверами в режиме broadcast, но не доставленные
+	// этому серверу; ключ - metrics.Metric.Key(). Дописываются в следующий пакет этого сервера.
+	pending map[string]*metrics.Metric
+}
+
+// upstreamSender отправляет пакеты метрик группе серверов в одном из режимов
+// failover, broadcast или shard и отслеживает состояние каждого сервера.
+//
+// После ошибки доступности сервер считается неработающим на время, которое удваивается
+// с каждой ошибкой подряд (от upstreamMinBackoff до upstreamMaxBackoff). Такие серверы
+// не исключаются полностью, а опрашиваются последними, чтобы пакет не был потерян,
+// если недоступны все серверы.
+type upstreamSender struct {
+	mode      string
+	upstreams []*upstream
+	ring      *hashRing
+	now       func() time.Time
+}
+
+func newUpstreamSender(agentConfig config.AgentConfig, hosts []string, tel *telemetry) (*upstreamSender, error) {
+	mode := agentConfig.UpstreamMode
+	switch mode {
+	case "":
+		mode = UpstreamFailover
+	case UpstreamFailover, UpstreamBroadcast, UpstreamShard:
+	default:
+		return nil, fmt.Errorf("unknown upstream mode: %s", mode)
+	}
+
+	senders := make([]sender, 0, len(hosts))
+	for _, host := range hosts {
+		hostSender, err := newHostSender(agentConfig, host, tel)
+		if err != nil {
+			for _, s := range senders {
+				s.Close()
+			}
+			return nil, fmt.Errorf("cannot initialize sender for %s: %w", host, err)
+		}
+		senders = append(senders, hostSender)
+	}
+
+	return newUpstreamSenderWith(mode, hosts, senders), nil
+}
+
+func newUpstreamSenderWith(mode string, hosts []string, senders []sender) *upstreamSender {
+	upstreams := make([]*upstream, len(hosts))
+	for i, host := range hosts {
+		upstreams[i] = &upstream{host: host, id: collector.MetricID("", host), sender: senders[i]}
+	}
+
+	return &upstreamSender{
+		mode:      mode,
+		upstreams: upstreams,
+		ring:      newHashRing(hosts, upstreamReplicas),
+		now:       time.Now,
+	}
+}
+
+func (s *upstreamSender) Close() error {
+	errs := make([]error, 0, len(s.upstreams))
+	for _, u := range s.upstreams {
+		errs = append(errs, u.sender.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Send отправляет пакет метрик группе серверов согласно режиму.
+func (s *upstreamSender) Send(ctx context.Context, batch []*metrics.Metric) error {
+	switch s.mode {
+	case UpstreamBroadcast:
+		return s.sendBroadcast(ctx, batch)
+	case UpstreamShard:
+		return s.sendShard(ctx, batch)
+	}
+	return s.sendFailover(ctx, batch)
+}
+
+// sendFailover отправляет пакет первому серверу, принявшему его.
+// Отказ сервера принять метрики (не связанный с доступностью) возвращается сразу:
+// другие серверы отклонят такой пакет так же.
+func (s *upstreamSender) sendFailover(ctx context.Context, batch []*metrics.Metric) error {
+	errs := []error{}
+	for _, u := range s.ordered(s.upstreams) {
+		err := s.sendTo(ctx, u, batch)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, ErrServerUnavailable) {
+			return err
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// sendBroadcast отправляет пакет всем серверам параллельно.
+// Отправка считается успешной, если пакет принял хотя бы один сервер. Дельты счетчиков,
+// не доставленные недоступному серверу, остаются в его pending и отправляются ему со следующим
+// пакетом, поэтому серверы, принявшие пакет, не получают их повторно. Если пакет не принял
+// ни один сервер, возвращается ошибка, и пакет повторяет агент.
+func (s *upstreamSender) sendBroadcast(ctx context.Context, batch []*metrics.Metric) error {
+	errs := make([]error, len(s.upstreams))
+	pendings := make([]map[string]*metrics.Metric, len(s.upstreams))
+
+	var wg sync.WaitGroup
+	for i, u := range s.upstreams {
+		pendings[i] = u.takePending()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = s.sendTo(ctx, u, withPending(batch, pendings[i]))
+		}()
+	}
+	wg.Wait()
+
+	delivered := slices.Contains(errs, nil)
+	for i, u := range s.upstreams {
+		if errs[i] == nil || !errors.Is(errs[i], ErrServerUnavailable) {
+			// отклоненный сервером пакет не повторяется, как и при отправке одному серверу
+			continue
+		}
+		u.addPending(pendings[i])
+		if delivered {
+			u.addPending(countersByKey(batch))
+		}
+	}
+
+	if delivered {
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+// sendShard делит пакет по серверам-владельцам метрик и отправляет части параллельно.
+// Если часть не доставлена, возвращается *PartialSendError с недоставленными метриками.
+func (s *upstreamSender) sendShard(ctx context.Context, batch []*metrics.Metric) error {
+	shards := make([][]*metrics.Metric, len(s.upstreams))
+	for _, metric := range batch {
+		owner := s.ring.owner(metric.ID)
+		shards[owner] = append(shards[owner], metric)
+	}
+
+	errs := make([]error, len(s.upstreams))
+
+	var wg sync.WaitGroup
+	for i, u := range s.upstreams {
+		if len(shards[i]) == 0 {
+			continue
+		}
+		shardCtx := ctx
+		if identity, ok := batchIdentityFrom(ctx); ok {
+			shardCtx = withBatchIdentity(ctx, identity.shard(u.id))
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = s.sendTo(shardCtx, u, shards[i])
+		}()
+	}
+	wg.Wait()
+
+	failed := []*metrics.Metric{}
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, shards[i]...)
+		}
+	}
+
+	err := errors.Join(errs...)
+	if err == nil {
+		return nil
+	}
+	if len(failed) == len(batch) {
+		return err
+	}
+	return &PartialSendError{Failed: failed, Err: err}
+}
+
+// takePending забирает недоставленные серверу дельты счетчиков
+func (u *upstream) takePending() map[string]*metrics.Metric {
+	u.mux.Lock()
+	defer u.mux.Unlock()
+
+	pending := u.pending
+	u.pending = nil
+	return pending
+}
+
+// addPending добавляет недоставленные серверу дельты счетчиков
+func (u *upstream) addPending(counters map[string]*metrics.Metric) {
+	if len(counters) == 0 {
+		return
+	}
+
+	u.mux.Lock()
+	defer u.mux.Unlock()
+
+	if u.pending == nil {
+		u.pending = make(map[string]*metrics.Metric, len(counters))
+	}
+	for key, counter := range counters {
+		if current, ok := u.pending[key]; ok {
+			delta := *current.Delta + *counter.Delta
+			current.Delta = &delta
+			continue
+		}
+		counterCopy := *counter
+		delta := *counter.Delta
+		counterCopy.Delta = &delta
+		u.pending[key] = &counterCopy
+	}
+}
+
+// takeAllPending забирает недоставленные серверам группы дельты счетчиков, ключ - адрес сервера
+func (s *upstreamSender) takeAllPending() map[string]map[string]*metrics.Metric {
+	result := map[string]map[string]*metrics.Metric{}
+	for _, u := range s.upstreams {
+		if pending := u.takePending(); len(pending) > 0 {
+			result[u.host] = pending
+		}
+	}
+	return result
+}
+
+// adoptPending принимает недоставленные дельты счетчиков от прежнего отправителя, ключ - адрес сервера.
+// Дельты переходят к серверам с тем же адресом, если группа работает в режиме broadcast.
+// Возвращает дельты, которые передать не удалось.
+func (s *upstreamSender) adoptPending(pending map[string]map[string]*metrics.Metric) map[string]map[string]*metrics.Metric {
+	if s.mode != UpstreamBroadcast {
+		return pending
+	}
+
+	rest := maps.Clone(pending)
+	for _, u := range s.upstreams {
+		if counters, ok := rest[u.host]; ok {
+			u.addPending(counters)
+			delete(rest, u.host)
+		}
+	}
+	return rest
+}
+
+// countersByKey возвращает счетчики пакета по ключу метрики
+func countersByKey(batch []*metrics.Metric) map[string]*metrics.Metric {
+	counters := map[string]*metrics.Metric{}
+	for _, metric := range batch {
+		if metric.MType == metrics.Counter && metric.Delta != nil {
+			counters[metric.Key()] = metric
+		}
+	}
+	return counters
+}
+
+// withPending возвращает пакет с добавленными дельтами счетчиков pending.
+// Метрики пакета не изменяются: пакет отправляется нескольким серверам одновременно.
+func withPending(batch []*metrics.Metric, pending map[string]*metrics.Metric) []*metrics.Metric {
+	if len(pending) == 0 {
+		return batch
+	}
+
+	rest := maps.Clone(pending)
+	result := make([]*metrics.Metric, 0, len(batch)+len(pending))
+	for _, metric := range batch {
+		if counter, ok := rest[metric.Key()]; ok && metric.MType == metrics.Counter && metric.Delta != nil {
+			metricCopy := *metric
+			delta := *metric.Delta + *counter.Delta
+			metricCopy.Delta = &delta
+			metric = &metricCopy
+			delete(rest, metric.Key())
+		}
+		result = append(result, metric)
+	}
+	for _, key := range slices.Sorted(maps.Keys(rest)) {
+		result = append(result, rest[key])
+	}
+	return result
+}
+
+func (s *upstreamSender) sendTo(ctx context.Context, u *upstream, batch []*metrics.Metric) error {
+	err := u.sender.Send(ctx, batch)
+	if err != nil {
+		err = fmt.Errorf("%s: %w", u.host, err)
+	}
+	s.updateState(u, err)
+	return err
+}
+
+func (s *upstreamSender) updateState(u *upstream, err error) {
+	u.mux.Lock()
+	defer u.mux.Unlock()
+
+	if err == nil {
+		if u.failures > 0 {
+			logger.Log.Info("upstream server recovered", zap.String("host", u.host), zap.Int("failures", u.failures))
+		}
+		u.failures = 0
+		u.lastErr = nil
+		u.backoffUntil = time.Time{}
+		return
+	}
+
+	u.lastErr = err
+	if !errors.Is(err, ErrServerUnavailable) {
+		logger.Log.Warn("upstream server rejected metrics", zap.String("host", u.host), zap.Error(err))
+		return
+	}
+
+	u.failures++
+	backoff := min(upstreamMinBackoff<<min(u.failures-1, 16), upstreamMaxBackoff)
+	u.backoffUntil = s.now().Add(backoff)
+
+	logger.Log.Warn("upstream server unavailable",
+		zap.String("host", u.host),
+		zap.Int("failures", u.failures),
+		zap.Duration("backoff", backoff),
+		zap.Error(err),
+	)
+}
+
+// ordered возвращает серверы в порядке приоритета: сначала работающие,
+// затем неработающие в порядке окончания их паузы.
+func (s *upstreamSender) ordered(upstreams []*upstream) []*upstream {
+	now := s.now()
+
+	backoffs := make(map[*upstream]time.Time, len(upstreams))
+	for _, u := range upstreams {
+		u.mux.Lock()
+		if u.backoffUntil.After(now) {
+			backoffs[u] = u.backoffUntil
+		}
+		u.mux.Unlock()
+	}
+
+	result := slices.Clone(upstreams)
+	slices.SortStableFunc(result, func(a, b *upstream) int {
+		return backoffs[a].Compare(backoffs[b])
+	})
+	return result
+}
+
+// Metrics возвращает метрики состояния серверов группы:
+// UpstreamUp<Host> (1 - сервер работает, 0 - на паузе после ошибок),
+// UpstreamFailures<Host> (ошибок доступности подряд), UpstreamBackoff<Host> (секунд до конца паузы).
+func (s *upstreamSender) Metrics() []*metrics.Metric {
+	now := s.now()
+
+	result := make([]*metrics.Metric, 0, 3*len(s.upstreams))
+	for _, u := range s.upstreams {
+		u.mux.Lock()
+		up := 1.0
+		backoff := u.backoffUntil.Sub(now).Seconds()
+		if backoff > 0 {
+			up = 0
+		} else {
+			backoff = 0
+		}
+		failures := float64(u.failures)
+		u.mux.Unlock()
+
+		for mID, value := range map[string]float64{
+			"UpstreamUp" + u.id:       up,
+			"UpstreamFailures" + u.id: failures,
+			"UpstreamBackoff" + u.id:  backoff,
+		} {
+			metric, err := collector.NewGaugeMetric(mID, value)
+			if err != nil {
+				logger.Log.Error("error creating upstream state metric", zap.Error(err))
+				continue
+			}
+			result = append(result, metric)
+		}
+	}
+	return result
+}
+
+// hashRing - кольцо консистентного хеширования: при добавлении или удалении сервера
+// меняется владелец только у части метрик.
+type hashRing struct {
+	points []uint32
+	owners []int // индекс сервера для точки с тем же номером
+}
+
+func newHashRing(hosts []string, replicas int) *hashRing {
+	type point struct {
+		hash  uint32
+		owner int
+	}
+
+	points := make([]point, 0, len(hosts)*replicas)
+	for i, host := range hosts {
+		for r := range replicas {
+			points = append(points, point{hash: hashKey(host + "#" + strconv.Itoa(r)), owner: i})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].hash < points[j].hash })
+
+	ring := &hashRing{
+		points: make([]uint32, len(points)),
+		owners: make([]int, len(points)),
+	}
+	for i, p := range points {
+		ring.points[i] = p.hash
+		ring.owners[i] = p.owner
+	}
+	return ring
+}
+
+// owner возвращает индекс сервера, которому принадлежит ключ
+func (r *hashRing) owner(key string) int {
+	if len(r.points) == 0 {
+		return 0
+	}
+
+	h := hashKey(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[i]
+}
+
+func hashKey(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32()
+}
@@ -0,0 +1,217 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+type fakeSender struct {
+	mux      sync.Mutex
+	err      error
+	received [][]*metrics.Metric
+}
+
+func (s *fakeSender) Send(_ context.Context, batch []*metrics.Metric) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.received = append(s.received, batch)
+	return s.err
+}
+
+func (s *fakeSender) Close() error {
+	return nil
+}
+
+func (s *fakeSender) calls() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return len(s.received)
+}
+
+func newTestUpstreams(t *testing.T, mode string, n int) (*upstreamSender, []*fakeSender) {
+	t.Helper()
+
+	hosts := make([]string, n)
+	fakes := make([]*fakeSender, n)
+	senders := make([]sender, n)
+	for i := range n {
+		hosts[i] = fmt.Sprintf("server%d:8080", i+1)
+		fakes[i] = &fakeSender{}
+		senders[i] = fakes[i]
+	}
+
+	upstreams := newUpstreamSenderWith(mode, hosts, senders)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	upstreams.now = func() time.Time { return now }
+
+	return upstreams, fakes
+}
+
+func testMetricsBatch(t *testing.T, n int) []*metrics.Metric {
+	t.Helper()
+
+	batch := make([]*metrics.Metric, 0, n)
+	for i := range n {
+		metric := metrics.NewMetrics(fmt.Sprintf("Counter%d", i), metrics.Counter)
+		require.NoError(t, metric.UpdateValue(int64(1)))
+		batch = append(batch, metric)
+	}
+	return batch
+}
+
+func gaugeValues(reporter metricsReporter) map[string]float64 {
+	values := map[string]float64{}
+	for _, metric := range reporter.Metrics() {
+		values[metric.ID] = *metric.Value
+	}
+	return values
+}
+
+func TestUpstreamSender_Failover(t *testing.T) {
+	upstreams, fakes := newTestUpstreams(t, UpstreamFailover, 2)
+	ctx := context.Background()
+	batch := testMetricsBatch(t, 3)
+
+	fakes[0].err = fmt.Errorf("%w: connection refused", ErrServerUnavailable)
+
+	require.NoError(t, upstreams.Send(ctx, batch))
+	assert.Equal(t, 1, fakes[0].calls())
+	assert.Equal(t, 1, fakes[1].calls())
+
+	state := gaugeValues(upstreams)
+	assert.Equal(t, 0.0, state["UpstreamUpServer18080"])
+	assert.Equal(t, 1.0, state["UpstreamFailuresServer18080"])
+	assert.Equal(t, 1.0, state["UpstreamBackoffServer18080"])
+	assert.Equal(t, 1.0, state["UpstreamUpServer28080"])
+
+	// сервер на паузе опрашивается после работающего
+	require.NoError(t, upstreams.Send(ctx, batch))
+	assert.Equal(t, 1, fakes[0].calls())
+	assert.Equal(t, 2, fakes[1].calls())
+
+	// недоступны все серверы - опрашиваются все
+	fakes[1].err = fmt.Errorf("%w: timeout", ErrServerUnavailable)
+	err := upstreams.Send(ctx, batch)
+	assert.ErrorIs(t, err, ErrServerUnavailable)
+	assert.Equal(t, 2, fakes[0].calls())
+	assert.Equal(t, 3, fakes[1].calls())
+	assert.Equal(t, 2.0, gaugeValues(upstreams)["UpstreamBackoffServer18080"])
+
+	// отказ принять метрики не приводит к переключению на другой сервер
+	fakes[0].err = nil
+	fakes[1].err = errors.New("unexpected status code: 400")
+	err = upstreams.Send(ctx, batch)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrServerUnavailable)
+	assert.Equal(t, 2, fakes[0].calls())
+}
+
+func TestUpstreamSender_Broadcast(t *testing.T) {
+	upstreams, fakes := newTestUpstreams(t, UpstreamBroadcast, 3)
+	ctx := context.Background()
+	batch := testMetricsBatch(t, 3)
+
+	fakes[1].err = fmt.Errorf("%w: connection refused", ErrServerUnavailable)
+	require.NoError(t, upstreams.Send(ctx, batch))
+	for _, fake := range fakes {
+		assert.Equal(t, 1, fake.calls())
+	}
+
+	for _, fake := range fakes {
+		fake.err = fmt.Errorf("%w: connection refused", ErrServerUnavailable)
+	}
+	assert.ErrorIs(t, upstreams.Send(ctx, batch), ErrServerUnavailable)
+}
+
+func TestUpstreamSender_BroadcastPendingCounters(t *testing.T) {
+	upstreams, fakes := newTestUpstreams(t, UpstreamBroadcast, 2)
+	ctx := context.Background()
+
+	deltas := func(batch []*metrics.Metric) map[string]int64 {
+		result := map[string]int64{}
+		for _, metric := range batch {
+			result[metric.ID] += *metric.Delta
+		}
+		return result
+	}
+
+	fakes[1].err = fmt.Errorf("%w: connection refused", ErrServerUnavailable)
+	require.NoError(t, upstreams.Send(ctx, testMetricsBatch(t, 2)), "пакет принят первым сервером")
+
+	// все серверы недоступны: пакет повторяет агент, поэтому в pending он не попадает
+	fakes[0].err = fmt.Errorf("%w: connection refused", ErrServerUnavailable)
+	assert.ErrorIs(t, upstreams.Send(ctx, testMetricsBatch(t, 1)), ErrServerUnavailable)
+
+	fakes[0].err = nil
+	fakes[1].err = nil
+	require.NoError(t, upstreams.Send(ctx, testMetricsBatch(t, 1)))
+
+	assert.Equal(t, map[string]int64{"Counter0": 1}, deltas(fakes[0].received[2]),
+		"сервер, принявший пакет, не получает его повторно")
+	assert.Equal(t, map[string]int64{"Counter0": 2, "Counter1": 1}, deltas(fakes[1].received[2]),
+		"недоставленные дельты дописываются в следующий пакет сервера")
+
+	require.NoError(t, upstreams.Send(ctx, testMetricsBatch(t, 1)))
+	assert.Equal(t, map[string]int64{"Counter0": 1}, deltas(fakes[1].received[3]))
+}
+
+func TestUpstreamSender_Shard(t *testing.T) {
+	upstreams, fakes := newTestUpstreams(t, UpstreamShard, 3)
+	ctx := context.Background()
+	batch := testMetricsBatch(t, 300)
+
+	require.NoError(t, upstreams.Send(ctx, batch))
+
+	owners := map[string]int{}
+	total := 0
+	for i, fake := range fakes {
+		require.Equal(t, 1, fake.calls(), "каждый сервер получает свою часть")
+		for _, metric := range fake.received[0] {
+			owners[metric.ID] = i
+		}
+		total += len(fake.received[0])
+	}
+	assert.Equal(t, len(batch), total)
+
+	// повторная отправка распределяется так же
+	require.NoError(t, upstreams.Send(ctx, batch))
+	for i, fake := range fakes {
+		for _, metric := range fake.received[1] {
+			assert.Equal(t, i, owners[metric.ID])
+		}
+	}
+
+	fakes[2].err = fmt.Errorf("%w: connection refused", ErrServerUnavailable)
+	err := upstreams.Send(ctx, batch)
+
+	var partialErr *PartialSendError
+	require.ErrorAs(t, err, &partialErr)
+	assert.ErrorIs(t, err, ErrServerUnavailable)
+	assert.ElementsMatch(t, fakes[2].received[2], partialErr.Failed)
+}
+
+func TestHashRing_Stability(t *testing.T) {
+	ring := newHashRing([]string{"a:1", "b:1", "c:1"}, upstreamReplicas)
+	extended := newHashRing([]string{"a:1", "b:1", "c:1", "d:1"}, upstreamReplicas)
+
+	moved := 0
+	const keys = 1000
+	for i := range keys {
+		key := fmt.Sprintf("Metric%d", i)
+		if ring.owner(key) != extended.owner(key) {
+			moved++
+			assert.Equal(t, 3, extended.owner(key), "метрика может перейти только к новому серверу")
+		}
+	}
+	assert.Less(t, moved, keys/2)
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -12,42 +13,207 @@ import (
 )
 
 type AgentConfig struct {
-	Host           string `json:"address" mapstructure:"address"`                 // адрес сервера, на который будут отправляться метрики
-	ReportInterval int    `json:"report_interval" mapstructure:"report_interval"` // количество секунд между отправками метрик на сервер
-	PollInterval   int    `json:"poll_interval" mapstructure:"poll_interval"`     // количество секунд между сборами значений метрик
-	Key            string `json:"key" mapstructure:"key"`                         // ключ
-	RateLimit      int    `json:"rate_limit" mapstructure:"rate_limit"`           // максимальное количество горутин, одновременно отправляющих данные на сервер
-	CryptoKeyPath  string `json:"crypto_key" mapstructure:"crypto_key"`           // путь к публичному ключу
+	Host           string   `json:"address" mapstructure:"address"`                 // адрес сервера, на который будут отправляться метрики
+	Hosts          []string `json:"addresses" mapstructure:"addresses"`             // адреса нескольких серверов в порядке приоритета; если не заданы - используется Host
+	UpstreamMode   string   `json:"upstream_mode" mapstructure:"upstream_mode"`     // режим работы с несколькими серверами: failover, broadcast или shard
+	Transport      string   `json:"transport" mapstructure:"transport"`             // протокол отправки метрик: http, https или grpc
+	ReportInterval int      `json:"report_interval" mapstructure:"report_interval"` // количество секунд между отправками метрик на сервер
+	PollInterval   int      `json:"poll_interval" mapstructure:"poll_interval"`     // количество секунд между сборами значений метрик
+	Key            string   `json:"key" mapstructure:"key"`                         // ключ
+	RateLimit      int      `json:"rate_limit" mapstructure:"rate_limit"`           // максимальное количество горутин, одновременно отправляющих данные на сервер
+	CryptoKeyPath  string   `json:"crypto_key" mapstructure:"crypto_key"`           // путь к публичному ключу
+	Instance       string   `json:"instance" mapstructure:"instance"`               // значение метки instance; если не задано - используется имя хоста
+	ConfigPath     string   `json:"-" mapstructure:"config"`                        // путь к файлу конфигурации
+	ConfigWatch    bool     `json:"config_watch" mapstructure:"config_watch"`       // перечитывать конфигурацию при изменении файла
+	StatusAddress  string   `json:"status_address" mapstructure:"status_address"`   // адрес HTTP-сервера состояния агента (/healthz, /readyz, /snapshot); если не задан - не запускается
+	// Labels - дополнительные метки всех метрик агента
+	Labels map[string]string `json:"labels" mapstructure:"labels"`
+	// ограничения части пакета метрик: пакет, превышающий их, делится на части, которые отправляются параллельно
+	BatchMaxMetrics int `json:"batch_max_metrics" mapstructure:"batch_max_metrics"` // максимальное количество метрик в части; 0 - без ограничения
+	BatchMaxSize    int `json:"batch_max_size" mapstructure:"batch_max_size"`       // максимальный размер части в байтах до сжатия; 0 - без ограничения
+	// отправка только изменившихся метрик
+	SendChangedOnly bool `json:"send_changed_only" mapstructure:"send_changed_only"` // не отправлять неизменившиеся gauge и нулевые дельты counter
+	HeartbeatEvery  int  `json:"heartbeat_every" mapstructure:"heartbeat_every"`     // каждый N-й пакет отправлять полностью; 0 - только первый
+	// параметры TLS для транспорта https и grpc
+	TLSCAPath   string `json:"tls_ca" mapstructure:"tls_ca"`     // сертификат центра, которым проверяется сервер; если не задан - системные центры
+	TLSCertPath string `json:"tls_cert" mapstructure:"tls_cert"` // клиентский сертификат агента для mTLS
+	TLSKeyPath  string `json:"tls_key" mapstructure:"tls_key"`   // ключ клиентского сертификата агента
+	// параметры очереди на диске для пакетов, не доставленных на сервер
+	SpoolDir         string `json:"spool_dir" mapstructure:"spool_dir"`                   // каталог очереди; если не задан - очередь отключена
+	SpoolSegmentSize int64  `json:"spool_segment_size" mapstructure:"spool_segment_size"` // размер сегмента очереди в байтах
+	SpoolMaxSize     int64  `json:"spool_max_size" mapstructure:"spool_max_size"`         // максимальный размер очереди в байтах
+	SpoolMaxAge      int    `json:"spool_max_age" mapstructure:"spool_max_age"`           // максимальный возраст сегмента очереди в секундах
+	// Collectors - параметры сборщиков метрик, ключ - имя сборщика
+	Collectors map[string]CollectorConfig `json:"collectors" mapstructure:"collectors"`
+	// GaugeAggregation - способы агрегации значений метрик типа gauge за интервал отправки
+	// (last, min, max, mean, sum, p95), ключ - идентификатор метрики или шаблон filepath.Match
+	GaugeAggregation map[string][]string `json:"gauge_aggregation" mapstructure:"gauge_aggregation"`
+}
+
+// CollectorConfig - параметры отдельного сборщика метрик
+type CollectorConfig struct {
+	Enabled  *bool          `json:"enabled" mapstructure:"enabled"`   // включен ли сборщик; если не задано - используется значение по умолчанию
+	Interval time.Duration  `json:"interval" mapstructure:"interval"` // время между сборами; если 0 - используется poll_interval
+	Options  map[string]any `json:"options" mapstructure:"options"`   // произвольные параметры, которые интерпретирует сам сборщик
+	Timeout  time.Duration  `json:"timeout" mapstructure:"timeout"`   // максимальное время сбора; если 0 - равно интервалу
+	Align    bool           `json:"align" mapstructure:"align"`       // выравнивать сборы по границам интервала по часам
+	Jitter   time.Duration  `json:"jitter" mapstructure:"jitter"`     // максимальная случайная задержка сбора
 }
 
 type FileAgentConfig struct {
-	Host           string `json:"address"`         // адрес сервера, на который будут отправляться метрики
-	ReportInterval string `json:"report_interval"` // время между отправками метрик на сервер
-	PollInterval   string `json:"poll_interval"`   // время между сборами значений метрик
-	Key            string `json:"key"`             // ключ
-	RateLimit      int    `json:"rate_limit"`      // максимальное количество горутин, одновременно отправляющих данные на сервер
-	CryptoKeyPath  string `json:"crypto_key"`      // путь к публичному ключу
+	Host           string   `json:"address"`         // адрес сервера, на который будут отправляться метрики
+	Hosts          []string `json:"addresses"`       // адреса нескольких серверов в порядке приоритета
+	UpstreamMode   string   `json:"upstream_mode"`   // режим работы с несколькими серверами
+	Transport      string   `json:"transport"`       // протокол отправки метрик: http, https или grpc
+	ReportInterval string   `json:"report_interval"` // время между отправками метрик на сервер
+	PollInterval   string   `json:"poll_interval"`   // время между сборами значений метрик
+	Key            string   `json:"key"`             // ключ
+	RateLimit      int      `json:"rate_limit"`      // максимальное количество горутин, одновременно отправляющих данные на сервер
+	CryptoKeyPath  string   `json:"crypto_key"`      // путь к публичному ключу
+	Instance       string   `json:"instance"`        // значение метки instance
+	ConfigWatch    bool     `json:"config_watch"`    // перечитывать конфигурацию при изменении файла
+	StatusAddress  string   `json:"status_address"`  // адрес HTTP-сервера состояния агента
+	// Labels - дополнительные метки всех метрик агента
+	Labels map[string]string `json:"labels"`
+	// ограничения части пакета метрик
+	BatchMaxMetrics int `json:"batch_max_metrics"` // максимальное количество метрик в части
+	BatchMaxSize    int `json:"batch_max_size"`    // максимальный размер части в байтах до сжатия
+	// отправка только изменившихся метрик
+	SendChangedOnly bool `json:"send_changed_only"` // не отправлять неизменившиеся gauge и нулевые дельты counter
+	HeartbeatEvery  *int `json:"heartbeat_every"`   // каждый N-й пакет отправлять полностью; указатель, т.к. 0 - допустимое значение
+	// параметры TLS для транспорта https и grpc
+	TLSCAPath   string `json:"tls_ca"`   // сертификат центра, которым проверяется сервер
+	TLSCertPath string `json:"tls_cert"` // клиентский сертификат агента
+	TLSKeyPath  string `json:"tls_key"`  // ключ клиентского сертификата агента
+	// параметры очереди на диске для пакетов, не доставленных на сервер
+	SpoolDir         string `json:"spool_dir"`          // каталог очереди
+	SpoolSegmentSize int64  `json:"spool_segment_size"` // размер сегмента очереди в байтах
+	SpoolMaxSize     int64  `json:"spool_max_size"`     // максимальный размер очереди в байтах
+	SpoolMaxAge      string `json:"spool_max_age"`      // максимальный возраст сегмента очереди
+	// Collectors - параметры сборщиков метрик, ключ - имя сборщика
+	Collectors map[string]FileCollectorConfig `json:"collectors"`
+	// GaugeAggregation - способы агрегации значений метрик типа gauge за интервал отправки
+	GaugeAggregation map[string][]string `json:"gauge_aggregation"`
 }
 
-func GetAgentConfig() (AgentConfig, error) {
+// FileCollectorConfig - параметры сборщика метрик в файле конфигурации
+type FileCollectorConfig struct {
+	Enabled  *bool          `json:"enabled"`  // включен ли сборщик
+	Interval string         `json:"interval"` // время между сборами
+	Options  map[string]any `json:"options"`  // произвольные параметры сборщика
+	Timeout  string         `json:"timeout"`  // максимальное время сбора
+	Align    bool           `json:"align"`    // выравнивать сборы по границам интервала по часам
+	Jitter   string         `json:"jitter"`   // максимальная случайная задержка сбора
+}
 
+// setAgentDefaults задает значения параметров агента по умолчанию
+func setAgentDefaults() {
 	viper.SetDefault("address", "localhost:8080")
+	viper.SetDefault("addresses", []string{})
+	viper.SetDefault("upstream_mode", "failover")
+	viper.SetDefault("transport", "http")
 	viper.SetDefault("report_interval", 10)
 	viper.SetDefault("poll_interval", 2)
 	viper.SetDefault("key", "")
 	viper.SetDefault("rate_limit", 1)
+	viper.SetDefault("batch_max_metrics", 1000)
+	viper.SetDefault("batch_max_size", 1<<20)
+	viper.SetDefault("send_changed_only", false)
+	viper.SetDefault("heartbeat_every", 10)
+	viper.SetDefault("tls_ca", "")
+	viper.SetDefault("tls_cert", "")
+	viper.SetDefault("tls_key", "")
 	viper.SetDefault("crypto_key", "")
+	viper.SetDefault("instance", "")
+	viper.SetDefault("spool_dir", "")
+	viper.SetDefault("spool_segment_size", 1<<20)
+	viper.SetDefault("spool_max_size", 64<<20)
+	viper.SetDefault("spool_max_age", 24*60*60)
 	viper.SetDefault("config", "")
+	viper.SetDefault("config_watch", false)
+	viper.SetDefault("status_address", "")
+}
+
+// GetAgentConfig разбирает флаги командной строки и возвращает конфигурацию агента.
+// Приоритет источников: файл конфигурации, переменные окружения, флаги, значения по умолчанию.
+func GetAgentConfig() (AgentConfig, error) {
+
+	setAgentDefaults()
 
 	pflag.StringP("address", "a", viper.GetString("address"), "server address")
+	pflag.StringSlice("addresses", viper.GetStringSlice("addresses"), "comma-separated server addresses in priority order")
+	pflag.String("upstream-mode", viper.GetString("upstream_mode"), "multiple servers mode: failover, broadcast or shard")
+	pflag.String("transport", viper.GetString("transport"), "transport: http, https or grpc")
 	pflag.IntP("report-interval", "r", viper.GetInt("report_interval"), "report interval")
 	pflag.IntP("poll-interval", "p", viper.GetInt("poll_interval"), "poll interval")
 	pflag.IntP("rate-limit", "l", viper.GetInt("rate_limit"), "rate limit")
+	pflag.Int("batch-max-metrics", viper.GetInt("batch_max_metrics"), "max metrics in one request, 0 - unlimited")
+	pflag.Int("batch-max-size", viper.GetInt("batch_max_size"), "max uncompressed request body size in bytes, 0 - unlimited")
+	pflag.Bool("send-changed-only", viper.GetBool("send_changed_only"), "skip unchanged gauges and zero counter deltas")
+	pflag.Int("heartbeat-every", viper.GetInt("heartbeat_every"), "send all metrics every N reports in send-changed-only mode, 0 - only the first report")
+	pflag.String("tls-ca", viper.GetString("tls_ca"), "CA certificate path to verify the server")
+	pflag.String("tls-cert", viper.GetString("tls_cert"), "client certificate path for mTLS")
+	pflag.String("tls-key", viper.GetString("tls_key"), "client key path for mTLS")
 	pflag.StringP("key", "k", viper.GetString("key"), "secret key")
 	pflag.String("crypto-key", viper.GetString("crypto_key"), "path to crypto key")
+	pflag.String("instance", viper.GetString("instance"), "value of the instance label (host name by default)")
+	pflag.String("spool-dir", viper.GetString("spool_dir"), "directory of the outbound queue for unsent metrics")
+	pflag.Int64("spool-segment-size", viper.GetInt64("spool_segment_size"), "outbound queue segment size in bytes")
+	pflag.Int64("spool-max-size", viper.GetInt64("spool_max_size"), "outbound queue max size in bytes")
+	pflag.Int("spool-max-age", viper.GetInt("spool_max_age"), "outbound queue segment max age in seconds")
 	pflag.StringP("config", "c", viper.GetString("config"), "path to configuration file")
+	pflag.Bool("config-watch", viper.GetBool("config_watch"), "reload configuration when the configuration file changes")
+	pflag.String("status-address", viper.GetString("status_address"), "agent status HTTP server address (/healthz, /readyz, /snapshot)")
 	pflag.Parse()
 
+	return loadAgentConfig()
+}
+
+// AgentPushConfig - параметры однократной отправки метрик командой agent push.
+// Метрика задается либо типом, идентификатором и значением, либо файлом с пакетом метрик.
+type AgentPushConfig struct {
+	Type   string            // тип метрики: gauge или counter
+	ID     string            // идентификатор метрики
+	Value  string            // значение gauge или дельта counter
+	Labels map[string]string // метки метрики
+	File   string            // путь к файлу с пакетом метрик в формате JSON; "-" - стандартный ввод
+}
+
+// GetAgentPushConfig разбирает флаги команды agent push вместе с флагами агента
+// и возвращает конфигурацию агента и параметры отправки.
+func GetAgentPushConfig() (AgentConfig, AgentPushConfig, error) {
+	var push AgentPushConfig
+
+	pflag.StringVar(&push.Type, "type", "", "pushed metric type: gauge or counter")
+	pflag.StringVar(&push.ID, "id", "", "pushed metric id")
+	pflag.StringVar(&push.Value, "value", "", "pushed metric value (delta for counter)")
+	pflag.StringToStringVar(&push.Labels, "label", nil, "pushed metric labels, e.g. --label env=prod")
+	pflag.StringVarP(&push.File, "file", "f", "", "path to JSON file with metrics batch to push (- for stdin)")
+
+	agentConfig, err := GetAgentConfig()
+	if err != nil {
+		return AgentConfig{}, AgentPushConfig{}, err
+	}
+
+	if push.File != "" && (push.ID != "" || push.Type != "" || push.Value != "") {
+		return AgentConfig{}, AgentPushConfig{}, errors.New("either --file or --type, --id and --value must be set")
+	}
+	if push.File == "" && (push.ID == "" || push.Type == "" || push.Value == "") {
+		return AgentConfig{}, AgentPushConfig{}, errors.New("--type, --id and --value must be set")
+	}
+
+	return agentConfig, push, nil
+}
+
+// ReloadAgentConfig повторно читает файл конфигурации и переменные окружения.
+// Флаги командной строки, разобранные GetAgentConfig, сохраняют свое действие.
+func ReloadAgentConfig() (AgentConfig, error) {
+	viper.Reset()
+	setAgentDefaults()
+	return loadAgentConfig()
+}
+
+func loadAgentConfig() (AgentConfig, error) {
 	configPath := os.Getenv("CONFIG")
 	if configPath == "" {
 		if f := pflag.Lookup("config"); f != nil {
@@ -64,12 +230,29 @@ func GetAgentConfig() (AgentConfig, error) {
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
 	viper.AutomaticEnv()
 	viper.BindEnv("address", "ADDRESS")
+	viper.BindEnv("addresses", "ADDRESSES")
+	viper.BindEnv("upstream_mode", "UPSTREAM_MODE")
+	viper.BindEnv("transport", "TRANSPORT")
 	viper.BindEnv("report_interval", "REPORT_INTERVAL")
 	viper.BindEnv("poll_interval", "POLL_INTERVAL")
 	viper.BindEnv("key", "KEY")
 	viper.BindEnv("rate_limit", "RATE_LIMIT")
+	viper.BindEnv("batch_max_metrics", "BATCH_MAX_METRICS")
+	viper.BindEnv("batch_max_size", "BATCH_MAX_SIZE")
+	viper.BindEnv("send_changed_only", "SEND_CHANGED_ONLY")
+	viper.BindEnv("heartbeat_every", "HEARTBEAT_EVERY")
+	viper.BindEnv("tls_ca", "TLS_CA")
+	viper.BindEnv("tls_cert", "TLS_CERT")
+	viper.BindEnv("tls_key", "TLS_KEY")
 	viper.BindEnv("crypto_key", "CRYPTO_KEY")
+	viper.BindEnv("instance", "INSTANCE")
+	viper.BindEnv("spool_dir", "SPOOL_DIR")
+	viper.BindEnv("spool_segment_size", "SPOOL_SEGMENT_SIZE")
+	viper.BindEnv("spool_max_size", "SPOOL_MAX_SIZE")
+	viper.BindEnv("spool_max_age", "SPOOL_MAX_AGE")
 	viper.BindEnv("config", "CONFIG")
+	viper.BindEnv("config_watch", "CONFIG_WATCH")
+	viper.BindEnv("status_address", "STATUS_ADDRESS")
 
 	var cfg AgentConfig
 
@@ -77,9 +260,29 @@ func GetAgentConfig() (AgentConfig, error) {
 		return cfg, err
 	}
 
+	if err := validateCollectorsConfig(cfg.Collectors); err != nil {
+		return cfg, err
+	}
+
 	return cfg, nil
 }
 
+// validateCollectorsConfig проверяет параметры сборщиков метрик
+func validateCollectorsConfig(collectors map[string]CollectorConfig) error {
+	for name, collector := range collectors {
+		if collector.Interval < 0 {
+			return fmt.Errorf("negative interval of collector %s: %s", name, collector.Interval)
+		}
+		if collector.Timeout < 0 {
+			return fmt.Errorf("negative timeout of collector %s: %s", name, collector.Timeout)
+		}
+		if collector.Jitter < 0 {
+			return fmt.Errorf("negative jitter of collector %s: %s", name, collector.Jitter)
+		}
+	}
+	return nil
+}
+
 func parseAgentConfigFile(configPath string) error {
 	if configPath == "" {
 		return nil
@@ -101,6 +304,15 @@ func parseAgentConfigFile(configPath string) error {
 	if fileConfig.Host != "" {
 		viper.Set("address", fileConfig.Host)
 	}
+	if len(fileConfig.Hosts) > 0 {
+		viper.Set("addresses", fileConfig.Hosts)
+	}
+	if fileConfig.UpstreamMode != "" {
+		viper.Set("upstream_mode", fileConfig.UpstreamMode)
+	}
+	if fileConfig.Transport != "" {
+		viper.Set("transport", fileConfig.Transport)
+	}
 	if fileConfig.ReportInterval != "" {
 		reportIntervalDuration, err := time.ParseDuration(fileConfig.ReportInterval)
 		if err != nil {
@@ -121,13 +333,101 @@ func parseAgentConfigFile(configPath string) error {
 	if fileConfig.CryptoKeyPath != "" {
 		viper.Set("crypto_key", fileConfig.CryptoKeyPath)
 	}
+	if fileConfig.Instance != "" {
+		viper.Set("instance", fileConfig.Instance)
+	}
+	if fileConfig.ConfigWatch {
+		viper.Set("config_watch", fileConfig.ConfigWatch)
+	}
+	if fileConfig.StatusAddress != "" {
+		viper.Set("status_address", fileConfig.StatusAddress)
+	}
+	if len(fileConfig.Labels) > 0 {
+		viper.Set("labels", fileConfig.Labels)
+	}
 	if fileConfig.RateLimit != 0 {
 		viper.Set("rate_limit", fileConfig.RateLimit)
 	}
+	if fileConfig.BatchMaxMetrics != 0 {
+		viper.Set("batch_max_metrics", fileConfig.BatchMaxMetrics)
+	}
+	if fileConfig.BatchMaxSize != 0 {
+		viper.Set("batch_max_size", fileConfig.BatchMaxSize)
+	}
+	if fileConfig.SendChangedOnly {
+		viper.Set("send_changed_only", fileConfig.SendChangedOnly)
+	}
+	if fileConfig.HeartbeatEvery != nil {
+		viper.Set("heartbeat_every", *fileConfig.HeartbeatEvery)
+	}
+	if fileConfig.TLSCAPath != "" {
+		viper.Set("tls_ca", fileConfig.TLSCAPath)
+	}
+	if fileConfig.TLSCertPath != "" {
+		viper.Set("tls_cert", fileConfig.TLSCertPath)
+	}
+	if fileConfig.TLSKeyPath != "" {
+		viper.Set("tls_key", fileConfig.TLSKeyPath)
+	}
+	if fileConfig.SpoolDir != "" {
+		viper.Set("spool_dir", fileConfig.SpoolDir)
+	}
+	if fileConfig.SpoolSegmentSize != 0 {
+		viper.Set("spool_segment_size", fileConfig.SpoolSegmentSize)
+	}
+	if fileConfig.SpoolMaxSize != 0 {
+		viper.Set("spool_max_size", fileConfig.SpoolMaxSize)
+	}
+	if fileConfig.SpoolMaxAge != "" {
+		spoolMaxAgeDuration, err := time.ParseDuration(fileConfig.SpoolMaxAge)
+		if err != nil {
+			return fmt.Errorf("failed to parse SpoolMaxAge duration: %w", err)
+		}
+		viper.Set("spool_max_age", int(spoolMaxAgeDuration.Seconds()))
+	}
+	if len(fileConfig.Collectors) > 0 {
+		collectors, err := parseFileCollectorsConfig(fileConfig.Collectors)
+		if err != nil {
+			return err
+		}
+		viper.Set("collectors", collectors)
+	}
+	if len(fileConfig.GaugeAggregation) > 0 {
+		viper.Set("gauge_aggregation", fileConfig.GaugeAggregation)
+	}
 
 	return nil
 }
 
+func parseFileCollectorsConfig(fileCollectors map[string]FileCollectorConfig) (map[string]any, error) {
+	collectors := make(map[string]any, len(fileCollectors))
+
+	for name, fileCollector := range fileCollectors {
+		collector := map[string]any{
+			"options": fileCollector.Options,
+		}
+		if fileCollector.Enabled != nil {
+			collector["enabled"] = *fileCollector.Enabled
+		}
+		// interval, timeout и jitter приводятся к time.Duration при разборе конфигурации, как параметры сборщиков
+		if fileCollector.Interval != "" {
+			collector["interval"] = fileCollector.Interval
+		}
+		if fileCollector.Timeout != "" {
+			collector["timeout"] = fileCollector.Timeout
+		}
+		if fileCollector.Align {
+			collector["align"] = fileCollector.Align
+		}
+		if fileCollector.Jitter != "" {
+			collector["jitter"] = fileCollector.Jitter
+		}
+		collectors[name] = collector
+	}
+
+	return collectors, nil
+}
+
 func bindPFlags() {
 	pflag.VisitAll(func(f *pflag.Flag) {
 		if f.Changed {
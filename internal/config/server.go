@@ -22,10 +22,23 @@ type ServerConfig struct {
 	Key                  string `json:"key" mapstructure:"key"`
 	AuditFile            string `json:"audit_file" mapstructure:"audit_file"`
 	AuditURL             string `json:"audit_url" mapstructure:"audit_url"`
-	CryptoKeyPath        string `json:"crypto_key" mapstructure:"crypto_key"` // путь к приватному ключу
+	CryptoKeyPath        string `json:"crypto_key" mapstructure:"crypto_key"`     // путь к приватному ключу
+	GRPCAddress          string `json:"grpc_address" mapstructure:"grpc_address"` // адрес gRPC-сервера; если не задан - gRPC-сервер не запускается
 	UseDatabaseAsStorage bool
 	StoreOnUpdate        bool
 	StorePeriodically    bool
+
+	// BatchDedupTTL - сколько секунд сервер помнит примененные пакеты метрик, чтобы не применять повторно отправленные
+	BatchDedupTTL int `json:"batch_dedup_ttl" mapstructure:"batch_dedup_ttl"`
+
+	// TLS: если заданы сертификат и ключ, HTTP- и gRPC-серверы принимают только TLS-соединения;
+	// если задан сертификат центра клиентов, агенты должны предъявить подписанный им сертификат (mTLS)
+	TLSCertPath     string `json:"tls_cert" mapstructure:"tls_cert"`
+	TLSKeyPath      string `json:"tls_key" mapstructure:"tls_key"`
+	TLSClientCAPath string `json:"tls_client_ca" mapstructure:"tls_client_ca"`
+
+	// TrustedSubnet - подсети (CIDR), из которых разрешено обновлять метрики; адрес агента берется из заголовка X-Real-IP
+	TrustedSubnet []string `json:"trusted_subnet" mapstructure:"trusted_subnet"`
 }
 
 type FileServerConfig struct {
@@ -39,6 +52,13 @@ type FileServerConfig struct {
 	AuditFile       string `json:"audit_file"`
 	AuditURL        string `json:"audit_url"`
 	CryptoKeyPath   string `json:"crypto_key"` // путь к приватному ключу
+	GRPCAddress     string `json:"grpc_address"`
+	BatchDedupTTL   string `json:"batch_dedup_ttl"` // время, в течение которого сервер помнит примененные пакеты метрик
+	TLSCertPath     string `json:"tls_cert"`        // путь к сертификату сервера
+	TLSKeyPath      string `json:"tls_key"`         // путь к ключу сертификата сервера
+	TLSClientCAPath string `json:"tls_client_ca"`   // путь к сертификату центра, выпустившего сертификаты агентов
+
+	TrustedSubnet []string `json:"trusted_subnet"` // подсети (CIDR), из которых разрешено обновлять метрики
 }
 
 func GetServerConfig() (*ServerConfig, error) {
@@ -53,6 +73,12 @@ func GetServerConfig() (*ServerConfig, error) {
 	viper.SetDefault("audit_file", "")
 	viper.SetDefault("audit_url", "")
 	viper.SetDefault("crypto_key", "")
+	viper.SetDefault("grpc_address", "")
+	viper.SetDefault("batch_dedup_ttl", 24*60*60)
+	viper.SetDefault("tls_cert", "")
+	viper.SetDefault("tls_key", "")
+	viper.SetDefault("tls_client_ca", "")
+	viper.SetDefault("trusted_subnet", []string{})
 	viper.SetDefault("config", "")
 
 	pflag.StringP("address", "a", viper.GetString("address"), "server address")
@@ -65,6 +91,12 @@ func GetServerConfig() (*ServerConfig, error) {
 	pflag.String("audit-file", viper.GetString("audit_file"), "audit file")
 	pflag.String("audit-url", viper.GetString("audit_url"), "audit URL")
 	pflag.String("crypto-key", viper.GetString("crypto_key"), "crypto key path")
+	pflag.String("grpc-address", viper.GetString("grpc_address"), "gRPC server address")
+	pflag.Int("batch-dedup-ttl", viper.GetInt("batch_dedup_ttl"), "seconds to remember applied metrics batches")
+	pflag.String("tls-cert", viper.GetString("tls_cert"), "TLS certificate path")
+	pflag.String("tls-key", viper.GetString("tls_key"), "TLS key path")
+	pflag.String("tls-client-ca", viper.GetString("tls_client_ca"), "CA certificate path to verify agent certificates (mTLS)")
+	pflag.StringSliceP("trusted-subnet", "t", viper.GetStringSlice("trusted_subnet"), "comma-separated CIDR subnets allowed to update metrics")
 	pflag.StringP("config", "c", viper.GetString("config"), "path to configuration file")
 	pflag.Parse()
 
@@ -93,6 +125,12 @@ func GetServerConfig() (*ServerConfig, error) {
 	viper.BindEnv("audit_file", "AUDIT_FILE")
 	viper.BindEnv("audit_url", "AUDIT_URL")
 	viper.BindEnv("crypto_key", "CRYPTO_KEY")
+	viper.BindEnv("grpc_address", "GRPC_ADDRESS")
+	viper.BindEnv("batch_dedup_ttl", "BATCH_DEDUP_TTL")
+	viper.BindEnv("tls_cert", "TLS_CERT")
+	viper.BindEnv("tls_key", "TLS_KEY")
+	viper.BindEnv("tls_client_ca", "TLS_CLIENT_CA")
+	viper.BindEnv("trusted_subnet", "TRUSTED_SUBNET")
 	viper.BindEnv("config", "CONFIG")
 
 	var cfg = &ServerConfig{}
@@ -173,5 +211,33 @@ func parseServerConfigFile(configPath string) error {
 		viper.Set("crypto_key", fileConfig.CryptoKeyPath)
 	}
 
+	if fileConfig.GRPCAddress != "" {
+		viper.Set("grpc_address", fileConfig.GRPCAddress)
+	}
+
+	if fileConfig.BatchDedupTTL != "" {
+		batchDedupTTLDuration, err := time.ParseDuration(fileConfig.BatchDedupTTL)
+		if err != nil {
+			return fmt.Errorf("failed to parse batchDedupTTL duration: %w", err)
+		}
+		viper.Set("batch_dedup_ttl", int(batchDedupTTLDuration.Seconds()))
+	}
+
+	if fileConfig.TLSCertPath != "" {
+		viper.Set("tls_cert", fileConfig.TLSCertPath)
+	}
+
+	if fileConfig.TLSKeyPath != "" {
+		viper.Set("tls_key", fileConfig.TLSKeyPath)
+	}
+
+	if fileConfig.TLSClientCAPath != "" {
+		viper.Set("tls_client_ca", fileConfig.TLSClientCAPath)
+	}
+
+	if len(fileConfig.TrustedSubnet) > 0 {
+		viper.Set("trusted_subnet", fileConfig.TrustedSubnet)
+	}
+
 	return nil
 }
@@ -0,0 +1,219 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"time"
+)
+
+// CertUsage - назначение выпускаемого сертификата
+type CertUsage int
+
+const (
+	// ServerCert - сертификат сервера
+	ServerCert CertUsage = iota
+	// ClientCert - сертификат клиента (агента) для взаимной аутентификации
+	ClientCert
+)
+
+// GenerateCA генерирует самоподписанный сертификат и ключ локального удостоверяющего центра в формате PEM.
+// Центр используется для выпуска сертификатов сервера и агентов в сети без доступа к публичным центрам.
+func GenerateCA(commonName string, validFor time.Duration) (certPEM, keyPEM string, err error) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate CA key: %w", err)
+	}
+
+	template, err := certTemplate(commonName, validFor)
+	if err != nil {
+		return "", "", err
+	}
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create CA certificate: %w", err)
+	}
+
+	return encodeCertAndKey(der, key)
+}
+
+// GenerateCertificate выпускает сертификат сервера или клиента, подписанный удостоверяющим центром.
+// Для сертификата сервера hosts - DNS-имена и IP-адреса, по которым к нему обращаются агенты.
+func GenerateCertificate(caCertPEM, caKeyPEM, commonName string, hosts []string, usage CertUsage, validFor time.Duration) (certPEM, keyPEM string, err error) {
+
+	caCert, caKey, err := parseCA(caCertPEM, caKeyPEM)
+	if err != nil {
+		return "", "", err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate certificate key: %w", err)
+	}
+
+	template, err := certTemplate(commonName, validFor)
+	if err != nil {
+		return "", "", err
+	}
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	switch usage {
+	case ServerCert:
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	case ClientCert:
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	default:
+		return "", "", fmt.Errorf("unknown certificate usage: %d", usage)
+	}
+
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	return encodeCertAndKey(der, key)
+}
+
+// ServerTLSConfig создает конфигурацию TLS сервера из сертификата и ключа.
+// Если задан clientCAPath, сервер требует от агентов сертификат, подписанный этим центром (mTLS).
+// Если сертификат не задан, возвращает nil - сервер работает без TLS.
+func ServerTLSConfig(certPath, keyPath, clientCAPath string) (*tls.Config, error) {
+	if certPath == "" && keyPath == "" {
+		if clientCAPath != "" {
+			return nil, errors.New("client CA requires server certificate and key")
+		}
+		return nil, nil // TLS отключен
+	}
+
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if clientCAPath != "" {
+		pool, err := loadCertPool(clientCAPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client CA: %w", err)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+// ClientTLSConfig создает конфигурацию TLS агента.
+// Если задан caPath, сертификат сервера проверяется только этим центром (а не системными),
+// если заданы certPath и keyPath - агент предъявляет серверу клиентский сертификат.
+func ClientTLSConfig(caPath, certPath, keyPath string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caPath != "" {
+		pool, err := loadCertPool(caPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load CA: %w", err)
+		}
+		config.RootCAs = pool
+	}
+
+	if certPath != "" || keyPath != "" {
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// loadCertPool загружает сертификаты удостоверяющих центров из PEM файла
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pemData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemData) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+func certTemplate(commonName string, validFor time.Duration) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(validFor),
+	}, nil
+}
+
+func parseCA(caCertPEM, caKeyPEM string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certBlock, _ := pem.Decode([]byte(caCertPEM))
+	if certBlock == nil {
+		return nil, nil, errors.New("failed to decode CA certificate PEM")
+	}
+	caCert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+	if !caCert.IsCA {
+		return nil, nil, errors.New("certificate is not a CA")
+	}
+
+	keyBlock, _ := pem.Decode([]byte(caKeyPEM))
+	if keyBlock == nil {
+		return nil, nil, errors.New("failed to decode CA key PEM")
+	}
+	caKey, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse CA key: %w", err)
+	}
+
+	return caCert, caKey, nil
+}
+
+func encodeCertAndKey(der []byte, key *ecdsa.PrivateKey) (certPEM, keyPEM string, err error) {
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to marshal key: %w", err)
+	}
+
+	certPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return certPEM, keyPEM, nil
+}
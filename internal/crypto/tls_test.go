@@ -0,0 +1,112 @@
+package crypto_test
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/galogen13/yandex-go-metrics/internal/crypto"
+)
+
+// writeTestPKI выпускает центр, сертификат сервера для 127.0.0.1 и клиентский сертификат
+// и возвращает каталог с файлами ca.pem, server.pem, server-key.pem, client.pem, client-key.pem
+func writeTestPKI(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	write := func(name, data string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0600))
+	}
+
+	caCert, caKey, err := crypto.GenerateCA("test CA", time.Hour)
+	require.NoError(t, err)
+	write("ca.pem", caCert)
+
+	serverCert, serverKey, err := crypto.GenerateCertificate(caCert, caKey, "server", []string{"127.0.0.1"}, crypto.ServerCert, time.Hour)
+	require.NoError(t, err)
+	write("server.pem", serverCert)
+	write("server-key.pem", serverKey)
+
+	clientCert, clientKey, err := crypto.GenerateCertificate(caCert, caKey, "agent", nil, crypto.ClientCert, time.Hour)
+	require.NoError(t, err)
+	write("client.pem", clientCert)
+	write("client-key.pem", clientKey)
+
+	return dir
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := writeTestPKI(t)
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	serverConfig, err := crypto.ServerTLSConfig(path("server.pem"), path("server-key.pem"), path("ca.pem"))
+	require.NoError(t, err)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = serverConfig
+	server.StartTLS()
+	defer server.Close()
+
+	get := func(clientConfig *tls.Config) (*http.Response, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}
+		return client.Get(server.URL)
+	}
+
+	t.Run("Клиентский сертификат центра", func(t *testing.T) {
+		clientConfig, err := crypto.ClientTLSConfig(path("ca.pem"), path("client.pem"), path("client-key.pem"))
+		require.NoError(t, err)
+
+		resp, err := get(clientConfig)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Без клиентского сертификата", func(t *testing.T) {
+		clientConfig, err := crypto.ClientTLSConfig(path("ca.pem"), "", "")
+		require.NoError(t, err)
+
+		_, err = get(clientConfig)
+		assert.Error(t, err)
+	})
+
+	t.Run("Сервер не подписан закрепленным центром", func(t *testing.T) {
+		other := writeTestPKI(t)
+		clientConfig, err := crypto.ClientTLSConfig(filepath.Join(other, "ca.pem"), path("client.pem"), path("client-key.pem"))
+		require.NoError(t, err)
+
+		_, err = get(clientConfig)
+		assert.Error(t, err)
+	})
+}
+
+func TestServerTLSConfig(t *testing.T) {
+	config, err := crypto.ServerTLSConfig("", "", "")
+	assert.NoError(t, err)
+	assert.Nil(t, config, "TLS отключен")
+
+	_, err = crypto.ServerTLSConfig("", "", "ca.pem")
+	assert.Error(t, err, "центр клиентов без сертификата сервера")
+
+	_, err = crypto.ServerTLSConfig("missing.pem", "missing-key.pem", "")
+	assert.Error(t, err)
+}
+
+func TestGenerateCertificate_NotCA(t *testing.T) {
+	caCert, caKey, err := crypto.GenerateCA("test CA", time.Hour)
+	require.NoError(t, err)
+	cert, key, err := crypto.GenerateCertificate(caCert, caKey, "server", nil, crypto.ServerCert, time.Hour)
+	require.NoError(t, err)
+
+	_, _, err = crypto.GenerateCertificate(cert, key, "client", nil, crypto.ClientCert, time.Hour)
+	assert.Error(t, err, "сертификат сервера не может выпускать сертификаты")
+}
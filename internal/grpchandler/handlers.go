@@ -0,0 +1,117 @@
+// Пакет grpchandler предоставляет gRPC-обработчики для сервера сбора метрик.
+// Обработчики используют тот же интерфейс handler.Server, что и HTTP-обработчики,
+// поэтому семантика обновления метрик совпадает с эндпоинтом /updates.
+package grpchandler
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/galogen13/yandex-go-metrics/internal/handler"
+	"github.com/galogen13/yandex-go-metrics/internal/logger"
+	"github.com/galogen13/yandex-go-metrics/internal/proto/metricspb"
+	addinfo "github.com/galogen13/yandex-go-metrics/internal/service/additional-info"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+// MetricsServer реализует gRPC-сервис metricspb.MetricsServer
+type MetricsServer struct {
+	metricspb.UnimplementedMetricsServer
+	serverService handler.Server
+}
+
+// NewMetricsServer создает gRPC-сервис поверх сервиса метрик
+func NewMetricsServer(serverService handler.Server) *MetricsServer {
+	return &MetricsServer{serverService: serverService}
+}
+
+// UpdateMetrics обновляет пакет метрик.
+//
+// В случае ошибки возвращает:
+//   - InvalidArgument - некорректные метрики
+//   - Aborted - пакет с тем же идентификатором еще применяется, отправку нужно повторить
+//   - Internal - внутренняя ошибка сервера
+func (s *MetricsServer) UpdateMetrics(ctx context.Context, req *metricspb.UpdateMetricsRequest) (*metricspb.UpdateMetricsResponse, error) {
+
+	incomingMetrics := metricspb.ToMetrics(req.GetMetrics())
+
+	if err := s.serverService.UpdateMetrics(ctx, incomingMetrics, batchAddInfo(ctx, req)); err != nil {
+		logger.Log.Error("Error updating metrics", zap.Error(err))
+		return nil, status.Error(resolveCode(err), err.Error())
+	}
+
+	return &metricspb.UpdateMetricsResponse{Updated: int32(len(incomingMetrics))}, nil
+}
+
+// UpdateMetricsStream принимает пакет метрик частями и применяет его одним обновлением
+// после получения всех частей. Если поток прерван, ни одна метрика не применяется.
+func (s *MetricsServer) UpdateMetricsStream(stream metricspb.Metrics_UpdateMetricsStreamServer) error {
+
+	ctx := stream.Context()
+
+	incomingMetrics := []*metrics.Metric{}
+	var first *metricspb.UpdateMetricsRequest
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			logger.Log.Error("Error receiving metrics", zap.Error(err))
+			return err
+		}
+		if first == nil {
+			first = req
+		}
+		incomingMetrics = append(incomingMetrics, metricspb.ToMetrics(req.GetMetrics())...)
+	}
+
+	if err := s.serverService.UpdateMetrics(ctx, incomingMetrics, batchAddInfo(ctx, first)); err != nil {
+		logger.Log.Error("Error updating metrics", zap.Error(err))
+		return status.Error(resolveCode(err), err.Error())
+	}
+
+	return stream.SendAndClose(&metricspb.UpdateMetricsResponse{Updated: int32(len(incomingMetrics))})
+}
+
+func addInfo(ctx context.Context) addinfo.AddInfo {
+	info := addinfo.AddInfo{}
+	if remoteAddr, ok := addinfo.RemoteAddrFrom(ctx); ok {
+		// адрес, проверенный по доверенной подсети
+		info.RemoteAddr = remoteAddr
+	} else if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		info.RemoteAddr = p.Addr.String()
+	}
+	return info
+}
+
+// batchAddInfo дополняет addInfo идентификатором пакета из запроса
+func batchAddInfo(ctx context.Context, req *metricspb.UpdateMetricsRequest) addinfo.AddInfo {
+	info := addInfo(ctx)
+	info.AgentID = req.GetAgentId()
+	info.BatchID = req.GetBatchId()
+	info.BatchSeq = req.GetBatchSeq()
+	return info
+}
+
+func resolveCode(err error) codes.Code {
+	if errors.Is(err, metrics.ErrMetricValidation) {
+		return codes.InvalidArgument
+	}
+
+	if errors.Is(err, metrics.ErrMetricNotFound) {
+		return codes.NotFound
+	}
+
+	if errors.Is(err, metrics.ErrBatchInProgress) {
+		return codes.Aborted
+	}
+
+	return codes.Internal
+}
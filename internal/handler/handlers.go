@@ -16,6 +16,7 @@ import (
 	"github.com/galogen13/yandex-go-metrics/internal/logger"
 	addinfo "github.com/galogen13/yandex-go-metrics/internal/service/additional-info"
 	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+	"github.com/galogen13/yandex-go-metrics/internal/validation"
 	"github.com/galogen13/yandex-go-metrics/internal/web"
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
@@ -59,6 +60,9 @@ type Server interface {
 
 	// Decryptor возвращает декриптор для расщифровки сообщений
 	Decryptor() *crypto.Decryptor
+
+	// TrustedSubnet возвращает подсети, из которых разрешено обновлять метрики; nil - без ограничений
+	TrustedSubnet() *validation.TrustedSubnet
 }
 
 // PingStorageHandler возвращает HTTP-обработчик для проверки доступности хранилища.
@@ -137,7 +141,8 @@ func GetListHandler(serverService Server) http.HandlerFunc {
 //
 //	{
 //	    "id": "Alloc",
-//	    "type": "gauge"
+//	    "type": "gauge",
+//	    "labels": {"host": "web1"}
 //	}
 //
 // Пример успешного ответа:
@@ -248,6 +253,11 @@ func UpdateHandler(serverService Server) http.HandlerFunc {
 // UpdatesHandler возвращает HTTP-обработчик для массового обновления метрик в формате JSON.
 // Обработчик принимает массив метрик и сохраняет их все за один запрос.
 //
+// Если задан заголовок X-Batch-ID, пакет с уже примененным идентификатором
+// не применяется повторно, а запрос завершается успешно. Если пакет с тем же
+// идентификатором еще применяется, запрос завершается ответом 503, чтобы агент
+// повторил отправку. Заголовки X-Agent-ID и X-Batch-Seq дополняют идентификатор пакета.
+//
 // Пример запроса:
 //
 //	POST /updates HTTP/1.1
@@ -273,6 +283,7 @@ func UpdateHandler(serverService Server) http.HandlerFunc {
 // В случае ошибки возвращает:
 //   - 400 Bad Request - некорректный запрос или валидация
 //   - 500 Internal Server Error - внутренняя ошибка сервера
+//   - 503 Service Unavailable - пакет с тем же X-Batch-ID еще применяется
 func UpdatesHandler(serverService Server) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
@@ -287,7 +298,7 @@ func UpdatesHandler(serverService Server) http.HandlerFunc {
 			return
 		}
 
-		err := serverService.UpdateMetrics(ctx, metrics, addinfo.AddInfo{RemoteAddr: r.RemoteAddr})
+		err := serverService.UpdateMetrics(ctx, metrics, batchAddInfo(r))
 		if err != nil {
 			logger.Log.Error("Error updating metrics", zap.Error(err))
 			w.WriteHeader(resolveHTTPStatus(err))
@@ -305,10 +316,11 @@ func UpdatesHandler(serverService Server) http.HandlerFunc {
 
 // GetValueURLHandler возвращает HTTP-обработчик для получения значения метрики через URL.
 // Обработчик извлекает параметры из URL и возвращает значение метрики в текстовом формате.
+// Метки метрики передаются параметрами запроса.
 //
 // Пример запроса:
 //
-//	GET /value/gauge/Alloc HTTP/1.1
+//	GET /value/gauge/Alloc?host=web1 HTTP/1.1
 //
 // Пример успешного ответа:
 //
@@ -333,6 +345,7 @@ func GetValueURLHandler(serverService Server) http.HandlerFunc {
 		mType := metrics.MetricType(mTypeParam)
 
 		metric := metrics.NewMetrics(mID, mType)
+		metric.Labels = labelsFromQuery(r)
 
 		metric, err := serverService.GetMetric(ctx, metric)
 		if err != nil {
@@ -352,11 +365,11 @@ func GetValueURLHandler(serverService Server) http.HandlerFunc {
 
 // UpdateURLHandler возвращает HTTP-обработчик для обновления метрики через URL.
 // Обработчик извлекает параметры из URL, преобразует значения и сохраняет метрику.
-// Поддерживает URL формата: /update/{type}/{name}/{value}
+// Поддерживает URL формата: /update/{type}/{name}/{value}, метки метрики передаются параметрами запроса.
 //
 // Пример запроса:
 //
-//	POST /update/gauge/Alloc/123.45 HTTP/1.1
+//	POST /update/gauge/Alloc/123.45?host=web1&instance=agent1 HTTP/1.1
 //
 // Пример успешного ответа:
 //
@@ -405,6 +418,7 @@ func UpdateURLHandler(serverService Server) http.HandlerFunc {
 		}
 
 		metric := metrics.NewMetrics(metricID, metricType)
+		metric.Labels = labelsFromQuery(r)
 		if err = metric.UpdateValue(valueConverted); err != nil {
 			logger.Log.Error("Incorrect metric value",
 				zap.Any("type", metric.MType),
@@ -422,6 +436,33 @@ func UpdateURLHandler(serverService Server) http.HandlerFunc {
 	}
 }
 
+// labelsFromQuery возвращает метки метрики из параметров запроса.
+// Если параметр повторяется, используется первое значение.
+func labelsFromQuery(r *http.Request) map[string]string {
+	query := r.URL.Query()
+	if len(query) == 0 {
+		return nil
+	}
+
+	labels := make(map[string]string, len(query))
+	for name, values := range query {
+		labels[name] = values[0]
+	}
+	return labels
+}
+
+// batchAddInfo возвращает дополнительные поля запроса /updates с идентификатором пакета из заголовков.
+// Порядковый номер пакета носит справочный характер, поэтому некорректное значение не считается ошибкой.
+func batchAddInfo(r *http.Request) addinfo.AddInfo {
+	batchSeq, _ := strconv.ParseUint(r.Header.Get(addinfo.HeaderBatchSeq), 10, 64)
+	return addinfo.AddInfo{
+		RemoteAddr: r.RemoteAddr,
+		AgentID:    r.Header.Get(addinfo.HeaderAgentID),
+		BatchID:    r.Header.Get(addinfo.HeaderBatchID),
+		BatchSeq:   batchSeq,
+	}
+}
+
 func convertGaugeValue(valueStr string) (float64, error) {
 	value, err := strconv.ParseFloat(valueStr, 64)
 	if err != nil {
@@ -447,5 +488,9 @@ func resolveHTTPStatus(err error) int {
 		return http.StatusNotFound
 	}
 
+	if errors.Is(err, metrics.ErrBatchInProgress) {
+		return http.StatusServiceUnavailable
+	}
+
 	return http.StatusInternalServerError
 }
@@ -0,0 +1,40 @@
+package logger
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryRequestLogger логирует унарные gRPC-запросы аналогично RequestLogger
+func UnaryRequestLogger(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+
+	resp, err := handler(ctx, req)
+
+	Log.Info("incoming grpc request",
+		zap.String("method", info.FullMethod),
+		zap.String("duration", time.Since(start).String()),
+		zap.String("code", status.Code(err).String()),
+	)
+
+	return resp, err
+}
+
+// StreamRequestLogger логирует потоковые gRPC-запросы аналогично RequestLogger
+func StreamRequestLogger(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+
+	err := handler(srv, ss)
+
+	Log.Info("incoming grpc stream",
+		zap.String("method", info.FullMethod),
+		zap.String("duration", time.Since(start).String()),
+		zap.String("code", status.Code(err).String()),
+	)
+
+	return err
+}
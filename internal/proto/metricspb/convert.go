@@ -0,0 +1,61 @@
+package metricspb
+
+import "github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+
+// FromMetric преобразует метрику сервиса в сообщение gRPC
+func FromMetric(metric *metrics.Metric) *Metric {
+	pbMetric := &Metric{Id: metric.ID, Labels: metric.Labels}
+
+	switch metric.MType {
+	case metrics.Gauge:
+		pbMetric.Type = Metric_GAUGE
+		if metric.Value != nil {
+			pbMetric.Value = *metric.Value
+		}
+	case metrics.Counter:
+		pbMetric.Type = Metric_COUNTER
+		if metric.Delta != nil {
+			pbMetric.Delta = *metric.Delta
+		}
+	}
+
+	return pbMetric
+}
+
+// FromMetrics преобразует слайс метрик сервиса в слайс сообщений gRPC
+func FromMetrics(metricsList []*metrics.Metric) []*Metric {
+	result := make([]*Metric, 0, len(metricsList))
+	for _, metric := range metricsList {
+		result = append(result, FromMetric(metric))
+	}
+	return result
+}
+
+// ToMetric преобразует сообщение gRPC в метрику сервиса.
+// Метрика неизвестного типа возвращается без типа и значения и не пройдет проверку metrics.Metric.Check.
+func (m *Metric) ToMetric() *metrics.Metric {
+	var metric *metrics.Metric
+	switch m.GetType() {
+	case Metric_GAUGE:
+		metric = metrics.NewMetrics(m.GetId(), metrics.Gauge)
+		metric.UpdateValue(m.GetValue())
+	case Metric_COUNTER:
+		metric = metrics.NewMetrics(m.GetId(), metrics.Counter)
+		metric.UpdateValue(m.GetDelta())
+	default:
+		metric = metrics.NewMetrics(m.GetId(), metrics.NoType)
+	}
+	if len(m.GetLabels()) > 0 {
+		metric.Labels = m.GetLabels()
+	}
+	return metric
+}
+
+// ToMetrics преобразует слайс сообщений gRPC в слайс метрик сервиса
+func ToMetrics(pbMetrics []*Metric) []*metrics.Metric {
+	result := make([]*metrics.Metric, 0, len(pbMetrics))
+	for _, pbMetric := range pbMetrics {
+		result = append(result, pbMetric.ToMetric())
+	}
+	return result
+}
@@ -0,0 +1,89 @@
+package memstorage
+
+import (
+	"context"
+	"time"
+
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+// appliedBatch - запись о примененном пакете метрик
+type appliedBatch struct {
+	seq       uint64
+	appliedAt time.Time
+}
+
+// ApplyBatch отмечает пакет как примененный и записывает его метрики под одной блокировкой.
+// Если merge завершилась ошибкой, пакет не отмечается. Возвращает false, если пакет уже был применен.
+func (storage *MemStorage) ApplyBatch(ctx context.Context, agentID, batchID string, seq uint64, keys []string, merge func(map[string]*metrics.Metric) ([]*metrics.Metric, []*metrics.Metric, error)) (bool, error) {
+
+	storage.batchesMu.Lock()
+	defer storage.batchesMu.Unlock()
+
+	if _, ok := storage.batches[agentID][batchID]; ok {
+		return false, nil
+	}
+
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	// merge изменяет найденные метрики, поэтому ей передаются копии: при ошибке хранилище не меняется
+	found := make(map[string]*metrics.Metric, len(keys))
+	for _, key := range keys {
+		if metric, ok := storage.Metrics[key]; ok {
+			found[key] = copyMetric(metric)
+		}
+	}
+
+	metricsInsert, metricsUpdate, err := merge(found)
+	if err != nil {
+		return false, err
+	}
+
+	for _, metric := range append(metricsInsert, metricsUpdate...) {
+		storage.Metrics[metric.Key()] = metric
+	}
+
+	agentBatches, ok := storage.batches[agentID]
+	if !ok {
+		agentBatches = map[string]appliedBatch{}
+		storage.batches[agentID] = agentBatches
+	}
+	agentBatches[batchID] = appliedBatch{seq: seq, appliedAt: time.Now()}
+
+	return true, nil
+}
+
+// PruneBatches удаляет записи о пакетах, примененных раньше before
+func (storage *MemStorage) PruneBatches(ctx context.Context, before time.Time) error {
+
+	storage.batchesMu.Lock()
+	defer storage.batchesMu.Unlock()
+
+	for agentID, agentBatches := range storage.batches {
+		for batchID, batch := range agentBatches {
+			if batch.appliedAt.Before(before) {
+				delete(agentBatches, batchID)
+			}
+		}
+		if len(agentBatches) == 0 {
+			delete(storage.batches, agentID)
+		}
+	}
+
+	return nil
+}
+
+// copyMetric возвращает копию метрики, не разделяющую с ней значение
+func copyMetric(metric *metrics.Metric) *metrics.Metric {
+	metricCopy := *metric
+	if metric.Value != nil {
+		value := *metric.Value
+		metricCopy.Value = &value
+	}
+	if metric.Delta != nil {
+		delta := *metric.Delta
+		metricCopy.Delta = &delta
+	}
+	return &metricCopy
+}
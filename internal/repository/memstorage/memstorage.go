@@ -7,13 +7,18 @@ import (
 	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
 )
 
+// MemStorage хранит метрики в памяти. Ключ - metrics.Metric.Key().
 type MemStorage struct {
 	mu      sync.RWMutex
 	Metrics map[string]*metrics.Metric
+
+	// batches - журнал примененных пакетов метрик: агент -> идентификатор пакета -> запись
+	batchesMu sync.Mutex
+	batches   map[string]map[string]appliedBatch
 }
 
 func NewMemStorage() *MemStorage {
-	newStorage := MemStorage{Metrics: map[string]*metrics.Metric{}}
+	newStorage := MemStorage{Metrics: map[string]*metrics.Metric{}, batches: map[string]map[string]appliedBatch{}}
 	return &newStorage
 }
 
@@ -34,7 +39,7 @@ func (storage *MemStorage) Update(ctx context.Context, metrics []*metrics.Metric
 	storage.mu.Lock()
 	defer storage.mu.Unlock()
 	for _, metric := range metrics {
-		storage.Metrics[metric.ID] = metric
+		storage.Metrics[metric.Key()] = metric
 	}
 
 	return nil
@@ -43,24 +48,24 @@ func (storage *MemStorage) Update(ctx context.Context, metrics []*metrics.Metric
 func (storage *MemStorage) Get(ctx context.Context, incomingMetric *metrics.Metric) (*metrics.Metric, error) {
 	storage.mu.RLock()
 	defer storage.mu.RUnlock()
-	metric, ok := storage.Metrics[incomingMetric.ID]
+	metric, ok := storage.Metrics[incomingMetric.Key()]
 	if !ok || metric.MType != incomingMetric.MType {
 		return nil, nil
 	}
 	return metric, nil
 }
 
-func (storage *MemStorage) GetByIDs(ctx context.Context, IDs []string) (map[string]*metrics.Metric, error) {
+func (storage *MemStorage) GetByKeys(ctx context.Context, keys []string) (map[string]*metrics.Metric, error) {
 
 	storage.mu.RLock()
 	defer storage.mu.RUnlock()
 
-	result := make(map[string]*metrics.Metric, len(IDs))
+	result := make(map[string]*metrics.Metric, len(keys))
 
-	for _, ID := range IDs {
-		metric, ok := storage.Metrics[ID]
+	for _, key := range keys {
+		metric, ok := storage.Metrics[key]
 		if ok {
-			result[metric.ID] = metric
+			result[key] = metric
 		}
 	}
 
@@ -0,0 +1,85 @@
+package pgstorage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/galogen13/yandex-go-metrics/internal/retry"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+// ApplyBatch в одной транзакции отмечает пакет как примененный и записывает его метрики.
+// Метрики с ключами keys блокируются до конца транзакции. Возвращает false, если пакет уже был применен.
+func (storage *PGStorage) ApplyBatch(ctx context.Context, agentID, batchID string, seq uint64, keys []string, merge func(map[string]*metrics.Metric) ([]*metrics.Metric, []*metrics.Metric, error)) (bool, error) {
+
+	return retry.DoWithResult(
+		ctx,
+		func() (bool, error) {
+			return storage.applyBatchNoRetry(ctx, agentID, batchID, seq, keys, merge)
+		},
+		NewPostgresErrorClassifier())
+
+}
+
+func (storage *PGStorage) applyBatchNoRetry(ctx context.Context, agentID, batchID string, seq uint64, keys []string, merge func(map[string]*metrics.Metric) ([]*metrics.Metric, []*metrics.Metric, error)) (bool, error) {
+
+	tx, err := storage.pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction apply batch: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx,
+		`INSERT INTO applied_batches(agent_id, batch_id, seq) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING;`,
+		agentID, batchID, int64(seq))
+	if err != nil {
+		return false, fmt.Errorf("failed to claim batch: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	rows, err := tx.Query(ctx, "SELECT metric_key, id, labels, mtype, value, delta, value_str FROM metrics WHERE metric_key = ANY($1) FOR UPDATE;", keys)
+	if err != nil {
+		return false, fmt.Errorf("failed to do query apply batch: %w", err)
+	}
+	found, err := scanMetricsByKey(rows, len(keys))
+	if err != nil {
+		return false, err
+	}
+
+	metricsInsert, metricsUpdate, err := merge(found)
+	if err != nil {
+		return false, err
+	}
+
+	batch := &pgx.Batch{}
+	queueInserts(batch, metricsInsert)
+	queueUpdates(batch, metricsUpdate)
+
+	if err := execBatch(ctx, tx, batch); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+
+}
+
+// PruneBatches удаляет записи о пакетах, примененных раньше before
+func (storage *PGStorage) PruneBatches(ctx context.Context, before time.Time) error {
+
+	_, err := storage.pool.Exec(ctx, `DELETE FROM applied_batches WHERE applied_at < $1;`, before)
+	if err != nil {
+		return fmt.Errorf("failed to prune batches: %w", err)
+	}
+	return nil
+
+}
@@ -84,18 +84,47 @@ func (storage *PGStorage) insertNoRetry(ctx context.Context, metricsInsert []*me
 	defer tx.Rollback(ctx)
 
 	batch := &pgx.Batch{}
+	queueInserts(batch, metricsInsert)
 
+	if err := execBatch(ctx, tx, batch); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+
+}
+
+// queueInserts добавляет в batch вставку метрик
+func queueInserts(batch *pgx.Batch, metricsInsert []*metrics.Metric) {
 	for _, metric := range metricsInsert {
 		batch.Queue(
-			`INSERT INTO metrics(id, mtype, value, delta, value_str) VALUES ($1, $2, $3, $4, $5)`,
-			metric.ID, metric.MType, metric.Value, metric.Delta, metric.ValueStr,
+			`INSERT INTO metrics(metric_key, id, labels, mtype, value, delta, value_str) VALUES ($1, $2, COALESCE($3, '{}'::jsonb), $4, $5, $6, $7)`,
+			metric.Key(), metric.ID, metric.Labels, metric.MType, metric.Value, metric.Delta, metric.ValueStr,
+		)
+	}
+}
+
+// queueUpdates добавляет в batch обновление значений метрик
+func queueUpdates(batch *pgx.Batch, metricsUpdate []*metrics.Metric) {
+	for _, metric := range metricsUpdate {
+		batch.Queue(
+			`UPDATE metrics SET value=$1, delta=$2, value_str=$3 WHERE metric_key=$4 AND mtype=$5;`,
+			metric.Value, metric.Delta, metric.ValueStr, metric.Key(), metric.MType,
 		)
 	}
+}
+
+// execBatch выполняет запросы batch в транзакции tx
+func execBatch(ctx context.Context, tx pgx.Tx, batch *pgx.Batch) error {
 
 	results := tx.SendBatch(ctx, batch)
 	defer results.Close()
 
-	for range metricsInsert {
+	for range batch.Len() {
 		_, err := results.Exec()
 		if err != nil {
 			return fmt.Errorf("failed to execute batch item: %w", err)
@@ -106,10 +135,6 @@ func (storage *PGStorage) insertNoRetry(ctx context.Context, metricsInsert []*me
 		return fmt.Errorf("failed to close batch results: %w", err)
 	}
 
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
 	return nil
 
 }
@@ -134,26 +159,10 @@ func (storage *PGStorage) updateNoRetry(ctx context.Context, metricsUpdate []*me
 	defer tx.Rollback(ctx)
 
 	batch := &pgx.Batch{}
+	queueUpdates(batch, metricsUpdate)
 
-	for _, metric := range metricsUpdate {
-		batch.Queue(
-			`UPDATE metrics SET value=$1, delta=$2, value_str=$3 WHERE id=$4 AND mtype=$5;`,
-			metric.Value, metric.Delta, metric.ValueStr, metric.ID, metric.MType,
-		)
-	}
-
-	results := tx.SendBatch(ctx, batch)
-	defer results.Close()
-
-	for range metricsUpdate {
-		_, err := results.Exec()
-		if err != nil {
-			return fmt.Errorf("failed to execute batch item: %w", err)
-		}
-	}
-
-	if err := results.Close(); err != nil {
-		return fmt.Errorf("failed to close batch results: %w", err)
+	if err := execBatch(ctx, tx, batch); err != nil {
+		return err
 	}
 
 	if err := tx.Commit(ctx); err != nil {
@@ -183,9 +192,9 @@ func (storage *PGStorage) getNoRetry(ctx context.Context, metric *metrics.Metric
 		valueStr sql.NullString
 	)
 
-	row := storage.pool.QueryRow(ctx, "SELECT id, mtype, value, delta, value_str FROM metrics WHERE id = $1 AND mtype = $2;", metric.ID, metric.MType)
+	row := storage.pool.QueryRow(ctx, "SELECT id, labels, mtype, value, delta, value_str FROM metrics WHERE metric_key = $1 AND mtype = $2;", metric.Key(), metric.MType)
 	qMetric := metrics.Metric{}
-	err := row.Scan(&qMetric.ID, &qMetric.MType, &value, &delta, &valueStr)
+	err := row.Scan(&qMetric.ID, &qMetric.Labels, &qMetric.MType, &value, &delta, &valueStr)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -207,39 +216,46 @@ func (storage *PGStorage) getNoRetry(ctx context.Context, metric *metrics.Metric
 	return &qMetric, nil
 }
 
-func (storage *PGStorage) GetByIDs(ctx context.Context, ids []string) (map[string]*metrics.Metric, error) {
+func (storage *PGStorage) GetByKeys(ctx context.Context, keys []string) (map[string]*metrics.Metric, error) {
 
 	return retry.DoWithResult(
 		ctx,
 		func() (map[string]*metrics.Metric, error) {
-			return storage.getByIDsNoRetry(ctx, ids)
+			return storage.getByKeysNoRetry(ctx, keys)
 		},
 		NewPostgresErrorClassifier())
 
 }
 
-func (storage *PGStorage) getByIDsNoRetry(ctx context.Context, ids []string) (map[string]*metrics.Metric, error) {
+func (storage *PGStorage) getByKeysNoRetry(ctx context.Context, keys []string) (map[string]*metrics.Metric, error) {
 
-	rows, err := storage.pool.Query(ctx, "SELECT id, mtype, value, delta, value_str FROM metrics WHERE id = ANY($1);", ids)
+	rows, err := storage.pool.Query(ctx, "SELECT metric_key, id, labels, mtype, value, delta, value_str FROM metrics WHERE metric_key = ANY($1);", keys)
 	if err != nil {
-		return nil, fmt.Errorf("failed to do query GetByIDs: %w", err)
+		return nil, fmt.Errorf("failed to do query GetByKeys: %w", err)
 	}
 
+	return scanMetricsByKey(rows, len(keys))
+}
+
+// scanMetricsByKey читает строки metric_key, id, labels, mtype, value, delta, value_str в карту по ключу метрики
+func scanMetricsByKey(rows pgx.Rows, size int) (map[string]*metrics.Metric, error) {
+
 	defer rows.Close()
 
-	result := make(map[string]*metrics.Metric, len(ids))
+	result := make(map[string]*metrics.Metric, size)
 
 	for rows.Next() {
 		var (
+			key      string
 			value    sql.NullFloat64
 			delta    sql.NullInt64
 			valueStr sql.NullString
 			qMetric  metrics.Metric
 		)
 
-		err = rows.Scan(&qMetric.ID, &qMetric.MType, &value, &delta, &valueStr)
+		err := rows.Scan(&key, &qMetric.ID, &qMetric.Labels, &qMetric.MType, &value, &delta, &valueStr)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan query result GetByIDs: %w", err)
+			return nil, fmt.Errorf("failed to scan query result GetByKeys: %w", err)
 		}
 
 		if value.Valid {
@@ -254,11 +270,10 @@ func (storage *PGStorage) getByIDsNoRetry(ctx context.Context, ids []string) (ma
 			qMetric.ValueStr = valueStr.String
 		}
 
-		result[qMetric.ID] = &qMetric
+		result[key] = &qMetric
 	}
 
-	err = rows.Err()
-	if err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 	return result, nil
@@ -278,7 +293,7 @@ func (storage *PGStorage) GetAll(ctx context.Context) ([]*metrics.Metric, error)
 func (storage *PGStorage) getAllNoRetry(ctx context.Context) ([]*metrics.Metric, error) {
 
 	result := []*metrics.Metric{}
-	rows, err := storage.pool.Query(ctx, "SELECT id, mtype, value, delta, value_str FROM metrics;")
+	rows, err := storage.pool.Query(ctx, "SELECT id, labels, mtype, value, delta, value_str FROM metrics;")
 	if err != nil {
 		return nil, fmt.Errorf("failed to do query GetAll: %w", err)
 	}
@@ -293,7 +308,7 @@ func (storage *PGStorage) getAllNoRetry(ctx context.Context) ([]*metrics.Metric,
 			qMetric  metrics.Metric
 		)
 
-		err = rows.Scan(&qMetric.ID, &qMetric.MType, &value, &delta, &valueStr)
+		err = rows.Scan(&qMetric.ID, &qMetric.Labels, &qMetric.MType, &value, &delta, &valueStr)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan query result GetAll: %w", err)
 		}
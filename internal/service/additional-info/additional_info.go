@@ -2,7 +2,34 @@
 // поля дополнительной информации, необходимые для работы сервера
 package addinfo
 
+import "context"
+
+// Заголовки запроса /updates, идентифицирующие пакет метрик
+const (
+	HeaderAgentID  = "X-Agent-ID"  // идентификатор экземпляра агента
+	HeaderBatchID  = "X-Batch-ID"  // идентификатор пакета, одинаковый при повторных отправках
+	HeaderBatchSeq = "X-Batch-Seq" // порядковый номер пакета у агента
+)
+
 // AddInfo - структура дополнительных полей
 type AddInfo struct {
-	RemoteAddr string // ip-адрес агента
+	RemoteAddr string // ip-адрес агента; если задана доверенная подсеть - проверенный адрес из X-Real-IP
+	// Идентификация пакета метрик: пакет с уже примененным идентификатором не применяется повторно.
+	// Если BatchID не заполнен, пакет применяется всегда.
+	AgentID  string // идентификатор экземпляра агента
+	BatchID  string // идентификатор пакета
+	BatchSeq uint64 // порядковый номер пакета у агента
+}
+
+type remoteAddrKey struct{}
+
+// WithRemoteAddr возвращает контекст запроса с проверенным ip-адресом агента
+func WithRemoteAddr(ctx context.Context, remoteAddr string) context.Context {
+	return context.WithValue(ctx, remoteAddrKey{}, remoteAddr)
+}
+
+// RemoteAddrFrom возвращает проверенный ip-адрес агента из контекста запроса
+func RemoteAddrFrom(ctx context.Context) (string, bool) {
+	remoteAddr, ok := ctx.Value(remoteAddrKey{}).(string)
+	return remoteAddr, ok
 }
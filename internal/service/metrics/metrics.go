@@ -7,8 +7,11 @@ package metrics
 import (
 	"errors"
 	"fmt"
+	"maps"
 	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 )
 
 type MetricType string
@@ -22,6 +25,8 @@ const (
 var (
 	ErrMetricValidation = errors.New("metric validation error")
 	ErrMetricNotFound   = errors.New("metric not found")
+	// ErrBatchInProgress - пакет с тем же идентификатором еще применяется; отправку нужно повторить позже
+	ErrBatchInProgress = errors.New("metrics batch is being applied")
 )
 
 // Metric описывает метрику, где:
@@ -30,14 +35,16 @@ var (
 // - Delta - значение, на которое изменяется метрика типа counter, если метрика типа gauge - не заполнено.
 // - Value - значение метрики типа gauge, если метрика типа counter - не заполнено.
 // - ValueStr - строковое представление значения метрики.
+// - Labels - метки (измерения) метрики. Метрики с одним идентификатором и разными метками хранятся раздельно.
 //
 // generate:reset
 type Metric struct {
-	ID       string     `json:"id"`
-	MType    MetricType `json:"type"`
-	Delta    *int64     `json:"delta,omitempty"`
-	Value    *float64   `json:"value,omitempty"`
-	ValueStr string     `json:"-"`
+	ID       string            `json:"id"`
+	MType    MetricType        `json:"type"`
+	Delta    *int64            `json:"delta,omitempty"`
+	Value    *float64          `json:"value,omitempty"`
+	ValueStr string            `json:"-"`
+	Labels   map[string]string `json:"labels,omitempty"`
 }
 
 // NewMetrics создает новый экземпляр метрики по идентификатору и типу метрики
@@ -80,6 +87,28 @@ func (metric *Metric) UpdateValue(value any) error {
 	return nil
 }
 
+// Key возвращает ключ хранения метрики: идентификатор, а для метрики с метками -
+// идентификатор и метки, упорядоченные по имени, например Alloc{host="a",instance="b"}.
+func (metric Metric) Key() string {
+	if len(metric.Labels) == 0 {
+		return metric.ID
+	}
+
+	var b strings.Builder
+	b.WriteString(metric.ID)
+	b.WriteByte('{')
+	for i, name := range slices.Sorted(maps.Keys(metric.Labels)) {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(metric.Labels[name]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
 // GetValue возвращает значение метрики. Тип возвращаемого значения зависит от типа метрики
 func (metric Metric) GetValue() any {
 	switch metric.MType {
@@ -104,6 +133,7 @@ func (metric Metric) getValueString() string {
 // Check проверяет корректность заполненности метрики:
 // - корректный идентификатор (начинается с буквы, не содержит служебных символов)
 // - корректный тип (gauge или counter)
+// - корректные имена меток (начинаются с буквы или подчеркивания, содержат только буквы, цифры и подчеркивания)
 // - заполнено корректное поле значения в зависимости от типа
 func (metric Metric) Check(checkValue bool) error {
 	metricIDIsCorrect := metric.checkID()
@@ -111,6 +141,12 @@ func (metric Metric) Check(checkValue bool) error {
 		return fmt.Errorf("%w: metric ID is incorrect: %s", ErrMetricValidation, metric.ID)
 	}
 
+	for name := range metric.Labels {
+		if !IsValidLabelName(name) {
+			return fmt.Errorf("%w: metric label name is incorrect: %s", ErrMetricValidation, name)
+		}
+	}
+
 	if !metric.checkType() {
 		return fmt.Errorf("%w: metric type is incorrect: %s", ErrMetricValidation, metric.MType)
 	}
@@ -156,9 +192,19 @@ func (metric Metric) checkType() bool {
 var metricIDRegex = regexp.MustCompile("^[a-zA-Z][a-zA-Z0-9]*$")
 
 func (metric Metric) checkID() bool {
-	match := metricIDRegex.MatchString(metric.ID)
-	return match
+	return IsValidID(metric.ID)
+}
+
+// IsValidID сообщает, является ли строка допустимым идентификатором метрики
+func IsValidID(mID string) bool {
+	return metricIDRegex.MatchString(mID)
+}
+
+var labelNameRegex = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*$")
 
+// IsValidLabelName сообщает, является ли строка допустимым именем метки
+func IsValidLabelName(name string) bool {
+	return labelNameRegex.MatchString(name)
 }
 
 func (metric Metric) checkValue() bool {
@@ -103,6 +103,12 @@ func Test_Check(t *testing.T) {
 		{name: "Некорректное поле значения для Counter",
 			metric:  Metric{ID: "Counter", MType: Counter, Value: &value},
 			wantErr: true},
+		{name: "Корректные метки",
+			metric:  Metric{ID: "Alloc", MType: Gauge, Value: &value, Labels: map[string]string{"host": "web-1", "_instance": "a b"}},
+			wantErr: false},
+		{name: "Некорректное имя метки",
+			metric:  Metric{ID: "Alloc", MType: Gauge, Value: &value, Labels: map[string]string{"host-name": "web1"}},
+			wantErr: true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -116,3 +122,10 @@ func Test_Check(t *testing.T) {
 		})
 	}
 }
+
+func TestMetric_Key(t *testing.T) {
+	assert.Equal(t, "Alloc", Metric{ID: "Alloc"}.Key())
+	assert.Equal(t, "Alloc", Metric{ID: "Alloc", Labels: map[string]string{}}.Key())
+	assert.Equal(t, `Alloc{host="web1",instance="a\"b"}`,
+		Metric{ID: "Alloc", Labels: map[string]string{"instance": `a"b`, "host": "web1"}}.Key())
+}
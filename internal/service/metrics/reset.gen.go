@@ -18,4 +18,6 @@ func (v *Metric) Reset() {
 
 	v.ValueStr = ""
 
+	clear(v.Labels)
+
 }
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/galogen13/yandex-go-metrics/internal/logger"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+// batchPruneInterval - период удаления устаревших записей о примененных пакетах
+const batchPruneInterval = time.Minute
+
+// BatchLog - журнал примененных пакетов метрик. Агент присваивает каждому пакету идентификатор,
+// который не меняется при повторных отправках, поэтому пакет, доставленный повторно после
+// таймаута или из очереди на диске, не применяется второй раз и счетчики не удваиваются.
+// Хранилище, реализующее BatchLog, включает дедупликацию пакетов.
+type BatchLog interface {
+	// ApplyBatch атомарно отмечает пакет как примененный и записывает его метрики: метрики с ключами keys
+	// читаются из хранилища и передаются merge (ключ карты - metrics.Metric.Key()), которая возвращает
+	// метрики для вставки и обновления.
+	// Если merge или запись завершились ошибкой, пакет не отмечается. Возвращает false, если пакет уже был применен.
+	ApplyBatch(ctx context.Context, agentID, batchID string, seq uint64, keys []string, merge func(found map[string]*metrics.Metric) (insert, update []*metrics.Metric, err error)) (bool, error)
+	// PruneBatches удаляет записи о пакетах, примененных раньше before.
+	PruneBatches(ctx context.Context, before time.Time) error
+}
+
+// batchesInFlight - пакеты, которые применяются в данный момент. Повторная отправка пакета,
+// пришедшая до завершения первой, отклоняется с ошибкой metrics.ErrBatchInProgress: если первая
+// отправка завершится ошибкой, агент повторит пакет, а не будет считать его доставленным.
+type batchesInFlight struct {
+	mu      sync.Mutex
+	batches map[[2]string]struct{}
+}
+
+func newBatchesInFlight() *batchesInFlight {
+	return &batchesInFlight{batches: map[[2]string]struct{}{}}
+}
+
+// start отмечает пакет как применяемый. Возвращает false, если пакет уже применяется.
+func (b *batchesInFlight) start(agentID, batchID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := [2]string{agentID, batchID}
+	if _, ok := b.batches[key]; ok {
+		return false
+	}
+	b.batches[key] = struct{}{}
+	return true
+}
+
+// finish снимает отметку с пакета
+func (b *batchesInFlight) finish(agentID, batchID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.batches, [2]string{agentID, batchID})
+}
+
+// startBatchPrune периодически удаляет записи о пакетах старше batch_dedup_ttl
+func (serverService *ServerService) startBatchPrune(ctx context.Context) {
+
+	ttl := time.Duration(serverService.Config.BatchDedupTTL) * time.Second
+
+	ticker := time.NewTicker(batchPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := serverService.batches.PruneBatches(ctx, time.Now().Add(-ttl)); err != nil {
+				logger.Log.Info("cant prune applied batches", zap.Error(err))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
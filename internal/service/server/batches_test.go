@@ -0,0 +1,128 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/galogen13/yandex-go-metrics/internal/audit"
+	"github.com/galogen13/yandex-go-metrics/internal/config"
+	storage "github.com/galogen13/yandex-go-metrics/internal/repository/memstorage"
+	addinfo "github.com/galogen13/yandex-go-metrics/internal/service/additional-info"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+)
+
+func TestRouter_UpdatesBatchDedup(t *testing.T) {
+
+	stor := storage.NewMemStorage()
+	config := config.ServerConfig{Host: "localhost:8080", BatchDedupTTL: 60}
+
+	serverService, err := NewServerService(&config, stor, audit.NewAuditService())
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(metricsRouter(serverService))
+	defer ts.Close()
+
+	send := func(batchID string) {
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/updates", strings.NewReader(`[{"id":"Requests","type":"counter","delta":5}]`))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		if batchID != "" {
+			req.Header.Set(addinfo.HeaderAgentID, "agent-1")
+			req.Header.Set(addinfo.HeaderBatchID, batchID)
+			req.Header.Set(addinfo.HeaderBatchSeq, "1")
+		}
+		resp, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	requests := func() int64 {
+		metric, err := serverService.GetMetric(t.Context(), metrics.NewMetrics("Requests", metrics.Counter))
+		require.NoError(t, err)
+		return *metric.Delta
+	}
+
+	send("batch-1")
+	assert.Equal(t, int64(5), requests())
+
+	send("batch-1")
+	assert.Equal(t, int64(5), requests(), "повторно отправленный пакет не применяется")
+
+	send("batch-2")
+	assert.Equal(t, int64(10), requests())
+
+	send("")
+	send("")
+	assert.Equal(t, int64(20), requests(), "пакеты без идентификатора применяются всегда")
+
+	require.NoError(t, stor.PruneBatches(t.Context(), time.Now().Add(time.Second)))
+	send("batch-1")
+	assert.Equal(t, int64(25), requests(), "идентификатор забывается по истечении batch_dedup_ttl")
+}
+
+func TestServerService_UpdateMetricsReleasesFailedBatch(t *testing.T) {
+
+	stor := storage.NewMemStorage()
+	config := config.ServerConfig{BatchDedupTTL: 60}
+
+	serverService, err := NewServerService(&config, stor, audit.NewAuditService())
+	require.NoError(t, err)
+
+	info := addinfo.AddInfo{AgentID: "agent-1", BatchID: "batch-1"}
+
+	gauge := metrics.NewMetrics("Requests", metrics.Gauge)
+	require.NoError(t, gauge.UpdateValue(1.5))
+	require.NoError(t, serverService.UpdateMetrics(t.Context(), []*metrics.Metric{gauge}, addinfo.AddInfo{}))
+
+	// метрика с тем же идентификатором и другим типом не применяется, и пакет не применяется целиком
+	counter := metrics.NewMetrics("Requests", metrics.Counter)
+	require.NoError(t, counter.UpdateValue(int64(1)))
+	other := metrics.NewMetrics("Other", metrics.Gauge)
+	require.NoError(t, other.UpdateValue(2.5))
+	require.Error(t, serverService.UpdateMetrics(t.Context(), []*metrics.Metric{other, counter}, info))
+
+	found, err := stor.GetByKeys(t.Context(), []string{"Other"})
+	require.NoError(t, err)
+	assert.Empty(t, found, "метрики непримененного пакета не записаны")
+
+	retry := metrics.NewMetrics("Requests", metrics.Gauge)
+	require.NoError(t, retry.UpdateValue(3.5))
+	require.NoError(t, serverService.UpdateMetrics(t.Context(), []*metrics.Metric{retry}, info))
+
+	stored, err := stor.Get(t.Context(), retry)
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, 3.5, *stored.Value, "повторная отправка непримененного пакета применяется")
+}
+
+func TestServerService_UpdateMetricsBatchInProgress(t *testing.T) {
+
+	stor := storage.NewMemStorage()
+	config := config.ServerConfig{BatchDedupTTL: 60}
+
+	serverService, err := NewServerService(&config, stor, audit.NewAuditService())
+	require.NoError(t, err)
+
+	info := addinfo.AddInfo{AgentID: "agent-1", BatchID: "batch-1"}
+	gauge := metrics.NewMetrics("Requests", metrics.Gauge)
+	require.NoError(t, gauge.UpdateValue(1.5))
+
+	// первая отправка пакета еще применяется
+	require.True(t, serverService.inFlight.start(info.AgentID, info.BatchID))
+	err = serverService.UpdateMetrics(t.Context(), []*metrics.Metric{gauge}, info)
+	assert.ErrorIs(t, err, metrics.ErrBatchInProgress, "повторная отправка не подтверждается, пока пакет применяется")
+
+	serverService.inFlight.finish(info.AgentID, info.BatchID)
+	require.NoError(t, serverService.UpdateMetrics(t.Context(), []*metrics.Metric{gauge}, info))
+
+	stored, err := stor.Get(t.Context(), gauge)
+	require.NoError(t, err)
+	assert.NotNil(t, stored)
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/galogen13/yandex-go-metrics/internal/crypto"
 	addinfo "github.com/galogen13/yandex-go-metrics/internal/service/additional-info"
 	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+	"github.com/galogen13/yandex-go-metrics/internal/validation"
 )
 
 // mockServer реализует интерфейс handler.Server для тестирования.
@@ -54,6 +55,10 @@ func (m *mockServer) Decryptor() *crypto.Decryptor {
 	return nil
 }
 
+func (m *mockServer) TrustedSubnet() *validation.TrustedSubnet {
+	return nil
+}
+
 func (m *mockServer) ShutdownTrackingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 	})
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"google.golang.org/grpc"
+
+	"github.com/galogen13/yandex-go-metrics/internal/grpchandler"
+	"github.com/galogen13/yandex-go-metrics/internal/handler"
+	"github.com/galogen13/yandex-go-metrics/internal/logger"
+	"github.com/galogen13/yandex-go-metrics/internal/proto/metricspb"
+	"github.com/galogen13/yandex-go-metrics/internal/validation"
+)
+
+// metricsGRPCServer создает gRPC-сервер сервиса метрик; opts - дополнительные параметры сервера (например, TLS)
+func metricsGRPCServer(server handler.Server, opts ...grpc.ServerOption) *grpc.Server {
+	s := grpc.NewServer(append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			logger.UnaryRequestLogger,
+			validation.TrustedSubnetUnaryInterceptor(server.TrustedSubnet()),
+			validation.HashUnaryInterceptor(server.Key()),
+		),
+		grpc.ChainStreamInterceptor(
+			logger.StreamRequestLogger,
+			validation.TrustedSubnetStreamInterceptor(server.TrustedSubnet()),
+			validation.HashStreamInterceptor(server.Key()),
+		),
+	}, opts...)...)
+
+	metricspb.RegisterMetricsServer(s, grpchandler.NewMetricsServer(server))
+
+	return s
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/galogen13/yandex-go-metrics/internal/audit"
+	"github.com/galogen13/yandex-go-metrics/internal/config"
+	"github.com/galogen13/yandex-go-metrics/internal/proto/metricspb"
+	storage "github.com/galogen13/yandex-go-metrics/internal/repository/memstorage"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+	"github.com/galogen13/yandex-go-metrics/internal/validation"
+)
+
+func newTestGRPCClient(t *testing.T, serverService *ServerService) metricspb.MetricsClient {
+	t.Helper()
+
+	listener := bufconn.Listen(1024 * 1024)
+	grpcServer := metricsGRPCServer(serverService)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return metricspb.NewMetricsClient(conn)
+}
+
+func TestGRPC_UpdateMetrics(t *testing.T) {
+
+	stor := storage.NewMemStorage()
+	config := config.ServerConfig{Key: "secret"}
+
+	serverService, err := NewServerService(&config, stor, audit.NewAuditService())
+	require.NoError(t, err)
+
+	client := newTestGRPCClient(t, serverService)
+	ctx := context.Background()
+
+	t.Run("Унарное обновление с подписью", func(t *testing.T) {
+		req := &metricspb.UpdateMetricsRequest{Metrics: []*metricspb.Metric{
+			{Id: "Alloc", Type: metricspb.Metric_GAUGE, Value: 12.5},
+			{Id: "PollCount", Type: metricspb.Metric_COUNTER, Delta: 3},
+		}}
+		hash, err := validation.CalculateMessagesHMAC("secret", req)
+		require.NoError(t, err)
+
+		resp, err := client.UpdateMetrics(metadata.AppendToOutgoingContext(ctx, validation.HashMetadataKey, hash), req)
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), resp.GetUpdated())
+
+		metric, err := serverService.GetMetric(ctx, metrics.NewMetrics("PollCount", metrics.Counter))
+		require.NoError(t, err)
+		assert.Equal(t, "3", metric.ValueStr)
+	})
+
+	t.Run("Неверная подпись", func(t *testing.T) {
+		req := &metricspb.UpdateMetricsRequest{Metrics: []*metricspb.Metric{
+			{Id: "PollCount", Type: metricspb.Metric_COUNTER, Delta: 100},
+		}}
+
+		_, err := client.UpdateMetrics(metadata.AppendToOutgoingContext(ctx, validation.HashMetadataKey, "bad"), req)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		metric, err := serverService.GetMetric(ctx, metrics.NewMetrics("PollCount", metrics.Counter))
+		require.NoError(t, err)
+		assert.Equal(t, "3", metric.ValueStr)
+	})
+
+	t.Run("Некорректная метрика", func(t *testing.T) {
+		req := &metricspb.UpdateMetricsRequest{Metrics: []*metricspb.Metric{
+			{Id: "Alloc"},
+		}}
+
+		_, err := client.UpdateMetrics(ctx, req)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("Потоковое обновление с подписью", func(t *testing.T) {
+		requests := []*metricspb.UpdateMetricsRequest{
+			{Metrics: []*metricspb.Metric{{Id: "PollCount", Type: metricspb.Metric_COUNTER, Delta: 1}}},
+			{Metrics: []*metricspb.Metric{{Id: "PollCount", Type: metricspb.Metric_COUNTER, Delta: 2}}},
+		}
+		hash, err := validation.CalculateMessagesHMAC("secret", requests[0], requests[1])
+		require.NoError(t, err)
+
+		stream, err := client.UpdateMetricsStream(metadata.AppendToOutgoingContext(ctx, validation.HashMetadataKey, hash))
+		require.NoError(t, err)
+		for _, req := range requests {
+			require.NoError(t, stream.Send(req))
+		}
+		resp, err := stream.CloseAndRecv()
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), resp.GetUpdated())
+
+		metric, err := serverService.GetMetric(ctx, metrics.NewMetrics("PollCount", metrics.Counter))
+		require.NoError(t, err)
+		assert.Equal(t, "6", metric.ValueStr)
+	})
+}
@@ -85,19 +85,19 @@ func (mr *MockStorageMockRecorder) GetAll(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockStorage)(nil).GetAll), ctx)
 }
 
-// GetByIDs mocks base method.
-func (m *MockStorage) GetByIDs(ctx context.Context, IDs []string) (map[string]*metrics.Metric, error) {
+// GetByKeys mocks base method.
+func (m *MockStorage) GetByKeys(ctx context.Context, keys []string) (map[string]*metrics.Metric, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetByIDs", ctx, IDs)
+	ret := m.ctrl.Call(m, "GetByKeys", ctx, keys)
 	ret0, _ := ret[0].(map[string]*metrics.Metric)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetByIDs indicates an expected call of GetByIDs.
-func (mr *MockStorageMockRecorder) GetByIDs(ctx, IDs any) *gomock.Call {
+// GetByKeys indicates an expected call of GetByKeys.
+func (mr *MockStorageMockRecorder) GetByKeys(ctx, keys any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByIDs", reflect.TypeOf((*MockStorage)(nil).GetByIDs), ctx, IDs)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByKeys", reflect.TypeOf((*MockStorage)(nil).GetByKeys), ctx, keys)
 }
 
 // Insert mocks base method.
@@ -39,11 +39,13 @@ func metricsRouter(server handler.Server) *chi.Mux {
 		if server.Decryptor() != nil {
 			updateHandler = crypto.DecryptMiddleware(server.Decryptor(), updateHandler)
 		}
+		updateHandler = validation.TrustedSubnetMiddleware(server.TrustedSubnet(), updateHandler)
 
 		r.Post("/", logger.RequestLogger(updateHandler))
 
 		r.Post("/{mType}/{metrics}/{value}", logger.RequestLogger(
-			handler.UpdateURLHandler(server)))
+			validation.TrustedSubnetMiddleware(server.TrustedSubnet(),
+				handler.UpdateURLHandler(server))))
 	})
 
 	r.Route("/updates", func(r chi.Router) {
@@ -54,6 +56,7 @@ func metricsRouter(server handler.Server) *chi.Mux {
 		if server.Decryptor() != nil {
 			updatesHandler = crypto.DecryptMiddleware(server.Decryptor(), updatesHandler)
 		}
+		updatesHandler = validation.TrustedSubnetMiddleware(server.TrustedSubnet(), updatesHandler)
 
 		r.Post("/", logger.RequestLogger(updatesHandler))
 	})
@@ -490,6 +490,36 @@ func TestRouter_GetURL(t *testing.T) {
 			url:         "/value/counter/Counter",
 			contentType: reqContentTypeTextPlain,
 			want:        wantStruct{status: http.StatusOK, response: "30", contentType: respContentTypeTextPlain}},
+		{name: "Добавление counter с метками хранится отдельно от метрики без меток",
+			storage:     stor,
+			method:      http.MethodPost,
+			url:         "/update/counter/Counter/5?host=web1",
+			contentType: reqContentTypeTextPlain,
+			want:        wantStruct{status: http.StatusOK, response: "", contentType: respContentTypeTextPlain}},
+		{name: "Успешное получение значения counter с метками",
+			storage:     stor,
+			method:      http.MethodGet,
+			url:         "/value/counter/Counter?host=web1",
+			contentType: reqContentTypeTextPlain,
+			want:        wantStruct{status: http.StatusOK, response: "5", contentType: respContentTypeTextPlain}},
+		{name: "Значение counter без меток не изменилось",
+			storage:     stor,
+			method:      http.MethodGet,
+			url:         "/value/counter/Counter",
+			contentType: reqContentTypeTextPlain,
+			want:        wantStruct{status: http.StatusOK, response: "30", contentType: respContentTypeTextPlain}},
+		{name: "Несуществующий набор меток",
+			storage:     stor,
+			method:      http.MethodGet,
+			url:         "/value/counter/Counter?host=web2",
+			contentType: reqContentTypeTextPlain,
+			want:        wantStruct{status: http.StatusNotFound, response: "", contentType: respContentTypeTextPlain}},
+		{name: "Некорректное имя метки",
+			storage:     stor,
+			method:      http.MethodPost,
+			url:         "/update/counter/Counter/5?1host=web1",
+			contentType: reqContentTypeTextPlain,
+			want:        wantStruct{status: http.StatusBadRequest, response: "", contentType: respContentTypeTextPlain}},
 	}
 	for _, test := range tests {
 		// Тесты выполняются последовательно, не в отдельных горутинах, т.к. результат прошлых кейсов влияет на будущие
@@ -2,8 +2,10 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -16,7 +18,10 @@ import (
 	"github.com/galogen13/yandex-go-metrics/internal/logger"
 	addinfo "github.com/galogen13/yandex-go-metrics/internal/service/additional-info"
 	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+	"github.com/galogen13/yandex-go-metrics/internal/validation"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 //go:generate mockgen -destination=mocks/storage_mock.go . Storage
@@ -24,7 +29,8 @@ type Storage interface {
 	Update(ctx context.Context, metrics []*metrics.Metric) error
 	Insert(ctx context.Context, metrics []*metrics.Metric) error
 	Get(ctx context.Context, metric *metrics.Metric) (*metrics.Metric, error)
-	GetByIDs(ctx context.Context, IDs []string) (map[string]*metrics.Metric, error)
+	// GetByKeys возвращает метрики по ключам metrics.Metric.Key(), ключ результата - ключ метрики
+	GetByKeys(ctx context.Context, keys []string) (map[string]*metrics.Metric, error)
 	GetAll(ctx context.Context) ([]*metrics.Metric, error)
 	Ping(ctx context.Context) error
 	Close() error
@@ -35,6 +41,14 @@ type ServerService struct {
 	Config       *config.ServerConfig
 	AuditService *audit.AuditService
 	decryptor    *crypto.Decryptor
+	// tlsConfig - конфигурация TLS HTTP- и gRPC-серверов; nil, если TLS отключен
+	tlsConfig *tls.Config
+	// trustedSubnet - подсети, из которых разрешено обновлять метрики; nil, если не заданы
+	trustedSubnet *validation.TrustedSubnet
+	// batches - журнал примененных пакетов; nil, если хранилище его не поддерживает
+	batches BatchLog
+	// inFlight - пакеты, которые применяются в данный момент
+	inFlight *batchesInFlight
 }
 
 func NewServerService(config *config.ServerConfig, storage Storage, auditService *audit.AuditService) (*ServerService, error) {
@@ -43,12 +57,30 @@ func NewServerService(config *config.ServerConfig, storage Storage, auditService
 		return nil, fmt.Errorf("failed to create decryptor: %w", err)
 	}
 
-	return &ServerService{
-			Config:       config,
-			Storage:      storage,
-			AuditService: auditService,
-			decryptor:    decryptor},
-		nil
+	tlsConfig, err := crypto.ServerTLSConfig(config.TLSCertPath, config.TLSKeyPath, config.TLSClientCAPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create TLS config: %w", err)
+	}
+
+	trustedSubnet, err := validation.NewTrustedSubnet(config.TrustedSubnet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse trusted subnet: %w", err)
+	}
+
+	serverService := &ServerService{
+		Config:        config,
+		Storage:       storage,
+		AuditService:  auditService,
+		decryptor:     decryptor,
+		tlsConfig:     tlsConfig,
+		trustedSubnet: trustedSubnet,
+		inFlight:      newBatchesInFlight()}
+
+	if batches, ok := storage.(BatchLog); ok && config.BatchDedupTTL > 0 {
+		serverService.batches = batches
+	}
+
+	return serverService, nil
 }
 
 func (serverService *ServerService) Start() error {
@@ -59,8 +91,9 @@ func (serverService *ServerService) Start() error {
 	r := metricsRouter(serverService)
 
 	httpServer := &http.Server{
-		Addr:    serverService.Config.Host,
-		Handler: r,
+		Addr:      serverService.Config.Host,
+		Handler:   r,
+		TLSConfig: serverService.tlsConfig,
 	}
 
 	httpServerErrChan := make(chan error)
@@ -77,12 +110,45 @@ func (serverService *ServerService) Start() error {
 			zap.Bool("use database as storage", serverService.Config.UseDatabaseAsStorage),
 			zap.Bool("store on update", serverService.Config.StoreOnUpdate),
 			zap.Bool("store periodically", serverService.Config.StorePeriodically),
+			zap.Bool("tls", serverService.tlsConfig != nil),
+			zap.Bool("mTLS", serverService.Config.TLSClientCAPath != ""),
+			zap.Strings("trusted subnet", serverService.Config.TrustedSubnet),
 		)
+		if serverService.tlsConfig != nil {
+			// сертификат и ключ уже загружены в TLSConfig
+			if err := httpServer.ListenAndServeTLS("", ""); err != nil {
+				httpServerErrChan <- err
+			}
+			return
+		}
 		if err := httpServer.ListenAndServe(); err != nil {
 			httpServerErrChan <- err
 		}
 	}()
 
+	grpcServerOptions := []grpc.ServerOption{}
+	if serverService.tlsConfig != nil {
+		grpcServerOptions = append(grpcServerOptions, grpc.Creds(credentials.NewTLS(serverService.tlsConfig)))
+	}
+	grpcServer := metricsGRPCServer(serverService, grpcServerOptions...)
+	grpcServerErrChan := make(chan error)
+
+	if serverService.Config.GRPCAddress != "" {
+		listener, err := net.Listen("tcp", serverService.Config.GRPCAddress)
+		if err != nil {
+			return fmt.Errorf("cannot listen gRPC address: %w", err)
+		}
+
+		go func() {
+			defer close(grpcServerErrChan)
+
+			logger.Log.Info("Running gRPC server", zap.String("address", serverService.Config.GRPCAddress))
+			if err := grpcServer.Serve(listener); err != nil {
+				grpcServerErrChan <- err
+			}
+		}()
+	}
+
 	if *serverService.Config.RestoreStorage {
 		serverService.restoreFromFile(ctx)
 	}
@@ -91,12 +157,22 @@ func (serverService *ServerService) Start() error {
 		go serverService.startPeriodicSave(ctx)
 	}
 
+	if serverService.batches != nil {
+		go serverService.startBatchPrune(ctx)
+	}
+
 	select {
 	case err := <-httpServerErrChan:
+		grpcServer.Stop()
 		return err
+	case err := <-grpcServerErrChan:
+		httpServer.Close()
+		return fmt.Errorf("gRPC server error: %w", err)
 	case <-ctx.Done():
 		logger.Log.Info("shutdown signal received, stopping server gracefully...")
 
+		grpcServer.GracefulStop()
+
 		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer shutdownCancel()
 
@@ -119,29 +195,79 @@ func (serverService *ServerService) UpdateMetric(ctx context.Context, incomingMe
 
 func (serverService *ServerService) UpdateMetrics(ctx context.Context, incomingMetrics []*metrics.Metric, addInfo addinfo.AddInfo) error {
 
-	IDs := make([]string, 0, len(incomingMetrics))
+	keys := make([]string, 0, len(incomingMetrics))
 	for _, incomingMetric := range incomingMetrics {
 		if err := incomingMetric.Check(true); err != nil {
 			return errUpdatingMetrics(err)
 		}
-		IDs = append(IDs, incomingMetric.ID)
+		keys = append(keys, incomingMetric.Key())
+	}
+
+	if serverService.batches != nil && addInfo.BatchID != "" {
+		applied, err := serverService.applyBatch(ctx, incomingMetrics, keys, addInfo)
+		if err != nil {
+			return err
+		}
+		if !applied {
+			// пакет уже применен: повторная отправка подтверждается без изменения метрик
+			logger.Log.Info("duplicate metrics batch skipped",
+				zap.String("agentID", addInfo.AgentID),
+				zap.String("batchID", addInfo.BatchID),
+				zap.Uint64("batchSeq", addInfo.BatchSeq))
+			return nil
+		}
+	} else if err := serverService.applyMetrics(ctx, incomingMetrics, keys); err != nil {
+		return err
+	}
+
+	if serverService.Config.StoreOnUpdate {
+		err := serverService.saveStorageToFile(ctx, serverService.Config.FileStoragePath)
+		if err != nil {
+			logger.Log.Info("cant save metrics to file on update", zap.Error(err))
+		}
+	}
+
+	auditLog := audit.NewAuditLog(metrics.GetMetricIDs(incomingMetrics), addInfo.RemoteAddr)
+	serverService.AuditService.Notify(auditLog)
+
+	return nil
+
+}
+
+// applyBatch записывает метрики пакета с идентификатором из addInfo, если пакет еще не применен.
+// Отметка о пакете и метрики записываются атомарно. Возвращает false, если пакет уже был применен.
+func (serverService *ServerService) applyBatch(ctx context.Context, incomingMetrics []*metrics.Metric, keys []string, addInfo addinfo.AddInfo) (bool, error) {
+
+	if !serverService.inFlight.start(addInfo.AgentID, addInfo.BatchID) {
+		return false, errUpdatingMetrics(metrics.ErrBatchInProgress)
 	}
+	defer serverService.inFlight.finish(addInfo.AgentID, addInfo.BatchID)
 
-	metricsFound, err := serverService.Storage.GetByIDs(ctx, IDs)
+	applied, err := serverService.batches.ApplyBatch(ctx, addInfo.AgentID, addInfo.BatchID, addInfo.BatchSeq, keys,
+		func(found map[string]*metrics.Metric) ([]*metrics.Metric, []*metrics.Metric, error) {
+			return mergeMetrics(incomingMetrics, found)
+		})
 	if err != nil {
-		return errUpdatingMetrics(err)
+		return false, errUpdatingMetrics(err)
 	}
+	return applied, nil
 
-	metricsUpdate := make([]*metrics.Metric, 0, len(incomingMetrics)/2+1)
-	metricsInsert := make([]*metrics.Metric, 0, len(incomingMetrics)/2+1)
+}
+
+// mergeMetrics объединяет входящие метрики с найденными в хранилище: значения найденных метрик
+// обновляются, остальные метрики вставляются
+func mergeMetrics(incomingMetrics []*metrics.Metric, metricsFound map[string]*metrics.Metric) (metricsInsert, metricsUpdate []*metrics.Metric, err error) {
+
+	metricsUpdate = make([]*metrics.Metric, 0, len(incomingMetrics)/2+1)
+	metricsInsert = make([]*metrics.Metric, 0, len(incomingMetrics)/2+1)
 
 	for _, incomingMetric := range incomingMetrics {
 
-		metric, ok := metricsFound[incomingMetric.ID]
+		metric, ok := metricsFound[incomingMetric.Key()]
 		if ok {
 			err := metric.CompareTypes(incomingMetric.MType)
 			if err != nil {
-				return errUpdatingMetrics(err)
+				return nil, nil, err
 			}
 			metric.UpdateValue(incomingMetric.GetValue())
 			metricsUpdate = append(metricsUpdate, metric)
@@ -152,6 +278,23 @@ func (serverService *ServerService) UpdateMetrics(ctx context.Context, incomingM
 
 	}
 
+	return metricsInsert, metricsUpdate, nil
+
+}
+
+// applyMetrics записывает значения проверенных метрик в хранилище
+func (serverService *ServerService) applyMetrics(ctx context.Context, incomingMetrics []*metrics.Metric, keys []string) error {
+
+	metricsFound, err := serverService.Storage.GetByKeys(ctx, keys)
+	if err != nil {
+		return errUpdatingMetrics(err)
+	}
+
+	metricsInsert, metricsUpdate, err := mergeMetrics(incomingMetrics, metricsFound)
+	if err != nil {
+		return errUpdatingMetrics(err)
+	}
+
 	if len(metricsInsert) > 0 {
 		if err := serverService.Storage.Insert(ctx, metricsInsert); err != nil {
 			return errUpdatingMetrics(err)
@@ -164,16 +307,6 @@ func (serverService *ServerService) UpdateMetrics(ctx context.Context, incomingM
 		}
 	}
 
-	if serverService.Config.StoreOnUpdate {
-		err := serverService.saveStorageToFile(ctx, serverService.Config.FileStoragePath)
-		if err != nil {
-			logger.Log.Info("cant save metrics to file on update", zap.Error(err))
-		}
-	}
-
-	auditLog := audit.NewAuditLog(metrics.GetMetricIDs(incomingMetrics), addInfo.RemoteAddr)
-	serverService.AuditService.Notify(auditLog)
-
 	return nil
 
 }
@@ -241,6 +374,10 @@ func (serverService *ServerService) Decryptor() *crypto.Decryptor {
 	return serverService.decryptor
 }
 
+func (serverService *ServerService) TrustedSubnet() *validation.TrustedSubnet {
+	return serverService.trustedSubnet
+}
+
 func (serverService *ServerService) restoreStorageFromFile(ctx context.Context, fileStoragePath string) error {
 
 	if fileStoragePath == "" {
@@ -67,7 +67,7 @@ func BenchmarkUpdateMetrics(b *testing.B) {
 
 	}
 
-	mockStorage.EXPECT().GetByIDs(gomock.Any(), metricIDs).Return(existedMetrics, nil).AnyTimes()
+	mockStorage.EXPECT().GetByKeys(gomock.Any(), metricIDs).Return(existedMetrics, nil).AnyTimes()
 	mockStorage.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 	mockStorage.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/galogen13/yandex-go-metrics/internal/audit"
+	"github.com/galogen13/yandex-go-metrics/internal/config"
+	storage "github.com/galogen13/yandex-go-metrics/internal/repository/memstorage"
+	"github.com/galogen13/yandex-go-metrics/internal/validation"
+)
+
+type chanAuditor chan audit.AuditLog
+
+func (a chanAuditor) Notify(auditLog audit.AuditLog) {
+	a <- auditLog
+}
+
+func TestRouter_TrustedSubnet(t *testing.T) {
+
+	config := config.ServerConfig{Host: "localhost:8080", TrustedSubnet: []string{"192.168.1.0/24", "10.0.0.0/8"}}
+	auditService := audit.NewAuditService()
+	auditor := make(chanAuditor, 1)
+	auditService.Register(auditor)
+
+	serverService, err := NewServerService(&config, storage.NewMemStorage(), auditService)
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(metricsRouter(serverService))
+	defer ts.Close()
+
+	tests := []struct {
+		name   string
+		url    string
+		body   string
+		realIP string
+		status int
+	}{
+		{
+			name:   "Пакет из доверенной подсети",
+			url:    "/updates",
+			body:   `[{"id":"Requests","type":"counter","delta":5}]`,
+			realIP: "192.168.1.15",
+			status: http.StatusOK,
+		},
+		{
+			name:   "Метрика из второй доверенной подсети",
+			url:    "/update",
+			body:   `{"id":"Alloc","type":"gauge","value":1.5}`,
+			realIP: "10.1.2.3",
+			status: http.StatusOK,
+		},
+		{
+			name:   "Метрика в URL из доверенной подсети",
+			url:    "/update/gauge/Alloc/2.5",
+			realIP: "192.168.1.20",
+			status: http.StatusOK,
+		},
+		{
+			name:   "Пакет из недоверенной подсети",
+			url:    "/updates",
+			body:   `[{"id":"Requests","type":"counter","delta":5}]`,
+			realIP: "192.168.2.15",
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "Метрика в URL из недоверенной подсети",
+			url:    "/update/gauge/Alloc/2.5",
+			realIP: "172.16.0.1",
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "Без заголовка X-Real-IP",
+			url:    "/update",
+			body:   `{"id":"Alloc","type":"gauge","value":1.5}`,
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "Некорректный X-Real-IP",
+			url:    "/update",
+			body:   `{"id":"Alloc","type":"gauge","value":1.5}`,
+			realIP: "not-an-ip",
+			status: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, ts.URL+tt.url, strings.NewReader(tt.body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			if tt.realIP != "" {
+				req.Header.Set(validation.RealIPHeader, tt.realIP)
+			}
+
+			resp, err := ts.Client().Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, tt.status, resp.StatusCode)
+
+			if tt.status != http.StatusOK {
+				return
+			}
+			select {
+			case auditLog := <-auditor:
+				assert.Equal(t, tt.realIP, auditLog.IPAddress, "в аудит попадает проверенный адрес агента")
+			case <-time.After(time.Second):
+				t.Fatal("audit log not received")
+			}
+		})
+	}
+}
+
+func TestNewServerService_InvalidTrustedSubnet(t *testing.T) {
+
+	config := config.ServerConfig{TrustedSubnet: []string{"192.168.1.0/33"}}
+
+	_, err := NewServerService(&config, storage.NewMemStorage(), audit.NewAuditService())
+	assert.Error(t, err)
+}
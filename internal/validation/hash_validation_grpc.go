@@ -0,0 +1,154 @@
+package validation
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/galogen13/yandex-go-metrics/internal/logger"
+)
+
+const (
+	// HashMetadataKey - ключ метаданных gRPC с подписью сообщений
+	HashMetadataKey = "hashsha256"
+)
+
+var marshalOptions = proto.MarshalOptions{Deterministic: true}
+
+// CalculateMessagesHMAC вычисляет HMAC-SHA256 от детерминированно сериализованных сообщений, идущих подряд.
+// Если ключ не задан, возвращает пустую строку.
+func CalculateMessagesHMAC(key string, messages ...proto.Message) (string, error) {
+	if key == "" {
+		return "", nil
+	}
+
+	h := hmac.New(sha256.New, []byte(key))
+	for _, message := range messages {
+		if err := writeMessage(h, message); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func writeMessage(h hash.Hash, message any) error {
+	protoMessage, ok := message.(proto.Message)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", message)
+	}
+
+	data, err := marshalOptions.Marshal(protoMessage)
+	if err != nil {
+		return fmt.Errorf("cannot marshal message: %w", err)
+	}
+	h.Write(data)
+	return nil
+}
+
+// HashUnaryInterceptor проверяет подпись унарного запроса и подписывает ответ.
+// Как и HashValidation, пропускает запрос без проверки, если ключ или подпись не заданы.
+func HashUnaryInterceptor(key string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		receivedHash := receivedMessageHash(ctx)
+		if key == "" || receivedHash == "" {
+			return handler(ctx, req)
+		}
+
+		h := hmac.New(sha256.New, []byte(key))
+		if err := writeMessage(h, req); err != nil {
+			logger.Log.Error("unexpected error marshalling request", zap.Error(err))
+			return nil, status.Error(codes.Internal, "cannot calculate request hash")
+		}
+
+		if !hashEquals(receivedHash, h) {
+			return nil, status.Error(codes.InvalidArgument, "hash mismatch")
+		}
+
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return resp, err
+		}
+
+		if respMessage, ok := resp.(proto.Message); ok {
+			respHash, err := CalculateMessagesHMAC(key, respMessage)
+			if err == nil {
+				grpc.SetHeader(ctx, metadata.Pairs(HashMetadataKey, respHash))
+			}
+		}
+		return resp, nil
+	}
+}
+
+// HashStreamInterceptor проверяет подпись всех сообщений клиентского потока.
+// Подпись сверяется после получения последнего сообщения: при несовпадении
+// обработчик вместо io.EOF получает ошибку и не должен применять принятые сообщения.
+func HashStreamInterceptor(key string) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		receivedHash := receivedMessageHash(ss.Context())
+		if key == "" || receivedHash == "" {
+			return handler(srv, ss)
+		}
+
+		return handler(srv, &hashServerStream{
+			ServerStream: ss,
+			receivedHash: receivedHash,
+			h:            hmac.New(sha256.New, []byte(key)),
+		})
+	}
+}
+
+type hashServerStream struct {
+	grpc.ServerStream
+	receivedHash string
+	h            hash.Hash
+}
+
+func (s *hashServerStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if errors.Is(err, io.EOF) {
+		if !hashEquals(s.receivedHash, s.h) {
+			return status.Error(codes.InvalidArgument, "hash mismatch")
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := writeMessage(s.h, m); err != nil {
+		logger.Log.Error("unexpected error marshalling request", zap.Error(err))
+		return status.Error(codes.Internal, "cannot calculate request hash")
+	}
+	return nil
+}
+
+func receivedMessageHash(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := md.Get(HashMetadataKey)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func hashEquals(receivedHash string, h hash.Hash) bool {
+	expectedHash := hex.EncodeToString(h.Sum(nil))
+	equals := hmac.Equal([]byte(receivedHash), []byte(expectedHash))
+	logger.Log.Info("hash check result", zap.Bool("equals", equals))
+	return equals
+}
@@ -0,0 +1,167 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/galogen13/yandex-go-metrics/internal/logger"
+	addinfo "github.com/galogen13/yandex-go-metrics/internal/service/additional-info"
+)
+
+const (
+	// RealIPHeader - заголовок с IP-адресом агента
+	RealIPHeader = "X-Real-IP"
+	// RealIPMetadataKey - ключ метаданных gRPC с IP-адресом агента
+	RealIPMetadataKey = "x-real-ip"
+)
+
+// TrustedSubnet - подсети, из которых разрешено обновлять метрики
+type TrustedSubnet struct {
+	prefixes []netip.Prefix
+}
+
+// NewTrustedSubnet создает список доверенных подсетей из CIDR.
+// Если cidrs пуст, возвращает nil - проверка отключена.
+func NewTrustedSubnet(cidrs []string) (*TrustedSubnet, error) {
+	if len(cidrs) == 0 {
+		return nil, nil // проверка отключена
+	}
+
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted subnet %q: %w", cidr, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return &TrustedSubnet{prefixes: prefixes}, nil
+}
+
+// Check проверяет, что IP-адрес realIP принадлежит одной из доверенных подсетей,
+// и возвращает проверенный адрес.
+func (s *TrustedSubnet) Check(realIP string) (netip.Addr, error) {
+	if realIP == "" {
+		return netip.Addr{}, fmt.Errorf("%s is not set", RealIPHeader)
+	}
+	addr, err := netip.ParseAddr(realIP)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("invalid %s: %w", RealIPHeader, err)
+	}
+	addr = addr.Unmap()
+
+	for _, prefix := range s.prefixes {
+		if prefix.Contains(addr) {
+			return addr, nil
+		}
+	}
+	return netip.Addr{}, fmt.Errorf("address %s is not in trusted subnet", addr)
+}
+
+// TrustedSubnetMiddleware отклоняет запросы, IP-адрес агента в заголовке X-Real-IP которых
+// не принадлежит доверенным подсетям (403 Forbidden). Проверенный адрес записывается
+// в r.RemoteAddr и попадает в аудит. Если подсети не заданы, запрос пропускается без проверки.
+func TrustedSubnetMiddleware(subnet *TrustedSubnet, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if subnet == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		addr, err := subnet.Check(r.Header.Get(RealIPHeader))
+		if err != nil {
+			logger.Log.Info("request from untrusted address rejected", zap.String("remoteAddr", r.RemoteAddr), zap.Error(err))
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		r.RemoteAddr = addr.String()
+		next.ServeHTTP(w, r)
+	}
+}
+
+// TrustedSubnetUnaryInterceptor - аналог TrustedSubnetMiddleware для унарных запросов gRPC:
+// IP-адрес агента берется из метаданных x-real-ip, в случае ошибки возвращается PermissionDenied.
+func TrustedSubnetUnaryInterceptor(subnet *TrustedSubnet) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if subnet == nil {
+			return handler(ctx, req)
+		}
+
+		ctx, err := checkRealIPMetadata(ctx, subnet)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// TrustedSubnetStreamInterceptor - аналог TrustedSubnetMiddleware для потоковых запросов gRPC
+func TrustedSubnetStreamInterceptor(subnet *TrustedSubnet) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if subnet == nil {
+			return handler(srv, ss)
+		}
+
+		ctx, err := checkRealIPMetadata(ss.Context(), subnet)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &realIPServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type realIPServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *realIPServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// checkRealIPMetadata проверяет IP-адрес агента из метаданных и записывает проверенный адрес в контекст
+func checkRealIPMetadata(ctx context.Context, subnet *TrustedSubnet) (context.Context, error) {
+	realIP := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RealIPMetadataKey); len(values) > 0 {
+			realIP = values[0]
+		}
+	}
+
+	addr, err := subnet.Check(realIP)
+	if err != nil {
+		logger.Log.Info("request from untrusted address rejected", zap.Error(err))
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	return addinfo.WithRemoteAddr(ctx, addr.String()), nil
+}
+
+// OutboundIP возвращает IP-адрес интерфейса, через который уходят пакеты на адрес host (host:port).
+// Пакеты при этом не отправляются: для UDP-сокета только выбирается маршрут.
+func OutboundIP(host string) (string, error) {
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "80")
+	}
+
+	conn, err := net.Dial("udp", host)
+	if err != nil {
+		return "", fmt.Errorf("cannot determine outbound address: %w", err)
+	}
+	defer conn.Close()
+
+	addr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return "", fmt.Errorf("unexpected local address %s", conn.LocalAddr())
+	}
+	return addr.IP.String(), nil
+}
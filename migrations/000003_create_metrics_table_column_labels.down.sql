@@ -0,0 +1,11 @@
+BEGIN;
+
+DELETE FROM metrics WHERE labels <> '{}'::jsonb;
+
+ALTER TABLE metrics DROP CONSTRAINT metrics_pkey;
+ALTER TABLE metrics ADD PRIMARY KEY (id);
+ALTER TABLE metrics
+    DROP COLUMN IF EXISTS metric_key,
+    DROP COLUMN IF EXISTS labels;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,13 @@
+BEGIN;
+
+ALTER TABLE metrics
+    ADD COLUMN labels jsonb NOT NULL DEFAULT '{}'::jsonb,
+    ADD COLUMN metric_key text;
+
+UPDATE metrics SET metric_key = id;
+
+ALTER TABLE metrics ALTER COLUMN metric_key SET NOT NULL;
+ALTER TABLE metrics DROP CONSTRAINT metrics_pkey;
+ALTER TABLE metrics ADD PRIMARY KEY (metric_key);
+
+COMMIT;
\ No newline at end of file
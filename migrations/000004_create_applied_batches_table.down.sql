@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_applied_batches_applied_at;
+DROP TABLE IF EXISTS applied_batches;
+
+COMMIT;
\ No newline at end of file
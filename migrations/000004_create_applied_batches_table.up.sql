@@ -0,0 +1,14 @@
+BEGIN;
+
+CREATE TABLE applied_batches
+(
+    agent_id text NOT NULL,
+    batch_id text NOT NULL,
+    seq bigint NOT NULL,
+    applied_at timestamp with time zone NOT NULL DEFAULT now(),
+    PRIMARY KEY (agent_id, batch_id)
+);
+
+CREATE INDEX idx_applied_batches_applied_at ON applied_batches(applied_at);
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,488 @@
+// Пакет metricsclient - клиент сервера метрик для Go-сервисов.
+//
+// Сервис создает клиент, получает у него типизированные метрики (Gauge, Counter) и меняет их значения.
+// Значения накапливаются в памяти: для gauge хранится последнее значение, для counter - сумма приращений.
+// Клиент периодически отправляет изменившиеся метрики одним пакетом на эндпоинт /updates так же,
+// как агент: тело сжимается gzip, шифруется публичным ключом сервера (если задан CryptoKeyPath)
+// и подписывается HMAC в заголовке HashSHA256 (если задан Key).
+//
+//	client, err := metricsclient.New(metricsclient.Config{Address: "localhost:8080", Key: "secret"})
+//	if err != nil {
+//		return err
+//	}
+//	defer client.Close(context.Background())
+//
+//	requests, err := client.Counter("Requests")
+//	if err != nil {
+//		return err
+//	}
+//	requests.Inc()
+//
+// Пакет, который не удалось доставить из-за недоступности сервера, отправляется повторно при следующей
+// отправке с тем же идентификатором (заголовки X-Agent-ID, X-Batch-ID), поэтому сервер, уже применивший
+// пакет, не применит его второй раз.
+//
+// Get синхронно запрашивает текущее значение метрики на эндпоинте /value.
+package metricsclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/galogen13/yandex-go-metrics/internal/compression"
+	"github.com/galogen13/yandex-go-metrics/internal/crypto"
+	addinfo "github.com/galogen13/yandex-go-metrics/internal/service/additional-info"
+	"github.com/galogen13/yandex-go-metrics/internal/service/metrics"
+	"github.com/galogen13/yandex-go-metrics/internal/validation"
+)
+
+const (
+	// DefaultFlushInterval - период отправки метрик по умолчанию
+	DefaultFlushInterval = 10 * time.Second
+	// DefaultTimeout - время ожидания ответа сервера по умолчанию
+	DefaultTimeout = 5 * time.Second
+)
+
+const hashHeader = "HashSHA256"
+
+// ErrNotFound возвращается Get, если метрики нет на сервере
+var ErrNotFound = errors.New("metric not found")
+
+// Config - параметры клиента
+type Config struct {
+	// Address - адрес сервера: host:port (по HTTP) или URL со схемой, например https://metrics.local:8443
+	Address string
+	// Key - ключ подписи запросов HMAC-SHA256; если не задан, запросы не подписываются
+	Key string
+	// CryptoKeyPath - путь к публичному ключу сервера; если не задан, запросы не шифруются
+	CryptoKeyPath string
+	// FlushInterval - период отправки метрик; 0 - DefaultFlushInterval, отрицательное значение -
+	// метрики отправляются только вызовами Flush и Close
+	FlushInterval time.Duration
+	// Labels - метки, которые добавляются ко всем метрикам клиента
+	Labels map[string]string
+	// HTTPClient - HTTP-клиент для запросов к серверу (например, с настройками TLS);
+	// по умолчанию - клиент с таймаутом DefaultTimeout
+	HTTPClient *http.Client
+	// OnError вызывается при ошибке периодической отправки; по умолчанию ошибки игнорируются
+	OnError func(error)
+}
+
+// Client накапливает значения метрик и отправляет их на сервер. Методы клиента и метрик
+// можно вызывать из нескольких горутин.
+type Client struct {
+	baseURL    *url.URL
+	key        string
+	encryptor  *crypto.Encryptor
+	httpClient *http.Client
+	labels     map[string]string
+	onError    func(error)
+	// realIP - адрес интерфейса, через который идут запросы на сервер (заголовок X-Real-IP)
+	realIP string
+	// clientID - идентификатор экземпляра клиента, по которому сервер различает пакеты
+	clientID string
+	batchSeq atomic.Uint64
+
+	mu       sync.Mutex
+	gauges   map[string]*Gauge
+	counters map[string]*Counter
+
+	// flushMu удерживается, пока отправляется пакет
+	flushMu sync.Mutex
+	// pending - пакет, который не удалось доставить из-за недоступности сервера
+	pending *batch
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// batch - пакет метрик с идентификатором, который не меняется при повторных отправках
+type batch struct {
+	id      string
+	seq     uint64
+	metrics []Metric
+}
+
+// New создает клиент и запускает периодическую отправку метрик.
+// Клиент нужно закрыть методом Close, чтобы отправить накопленные значения.
+func New(cfg Config) (*Client, error) {
+	baseURL, err := parseAddress(cfg.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	for name := range cfg.Labels {
+		if !metrics.IsValidLabelName(name) {
+			return nil, fmt.Errorf("invalid label name: %q", name)
+		}
+	}
+
+	encryptor, err := crypto.NewEncryptor(cfg.CryptoKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot initialize encryptor: %w", err)
+	}
+
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: DefaultTimeout}
+	}
+
+	// адрес не определяется, если до сервера нет маршрута; сервер без trusted_subnet его не проверяет
+	realIP, _ := validation.OutboundIP(baseURL.Host)
+
+	c := &Client{
+		baseURL:    baseURL,
+		key:        cfg.Key,
+		encryptor:  encryptor,
+		httpClient: httpClient,
+		labels:     maps.Clone(cfg.Labels),
+		onError:    cfg.OnError,
+		realIP:     realIP,
+		clientID:   rand.Text(),
+		gauges:     map[string]*Gauge{},
+		counters:   map[string]*Counter{},
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	flushInterval := cfg.FlushInterval
+	if flushInterval == 0 {
+		flushInterval = DefaultFlushInterval
+	}
+	if flushInterval > 0 {
+		go c.flushLoop(flushInterval)
+	} else {
+		close(c.done)
+	}
+
+	return c, nil
+}
+
+// parseAddress возвращает базовый URL сервера по адресу host:port или URL
+func parseAddress(address string) (*url.URL, error) {
+	if address == "" {
+		return nil, errors.New("server address is not set")
+	}
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+
+	baseURL, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server address: %w", err)
+	}
+	if baseURL.Scheme != "http" && baseURL.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported server address scheme: %s", baseURL.Scheme)
+	}
+	if baseURL.Host == "" {
+		return nil, fmt.Errorf("invalid server address: %s", address)
+	}
+	return baseURL, nil
+}
+
+// flushLoop отправляет метрики каждые interval до вызова Close
+func (c *Client) flushLoop(interval time.Duration) {
+	defer close(c.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			if err := c.Flush(ctx); err != nil && c.onError != nil {
+				c.onError(err)
+			}
+			cancel()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// Close останавливает периодическую отправку и отправляет накопленные значения.
+// Повторный вызов только повторяет отправку.
+func (c *Client) Close(ctx context.Context) error {
+	c.closeOnce.Do(func() {
+		close(c.stop)
+	})
+
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return c.Flush(ctx)
+}
+
+// Gauge возвращает метрику типа gauge с идентификатором name.
+// Повторный вызов с тем же идентификатором возвращает ту же метрику.
+func (c *Client) Gauge(name string) (*Gauge, error) {
+	if !metrics.IsValidID(name) {
+		return nil, fmt.Errorf("invalid metric ID: %q", name)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.counters[name]; ok {
+		return nil, fmt.Errorf("metric %s is already registered as counter", name)
+	}
+	gauge, ok := c.gauges[name]
+	if !ok {
+		gauge = &Gauge{name: name}
+		c.gauges[name] = gauge
+	}
+	return gauge, nil
+}
+
+// Counter возвращает метрику типа counter с идентификатором name.
+// Повторный вызов с тем же идентификатором возвращает ту же метрику.
+func (c *Client) Counter(name string) (*Counter, error) {
+	if !metrics.IsValidID(name) {
+		return nil, fmt.Errorf("invalid metric ID: %q", name)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.gauges[name]; ok {
+		return nil, fmt.Errorf("metric %s is already registered as gauge", name)
+	}
+	counter, ok := c.counters[name]
+	if !ok {
+		counter = &Counter{name: name}
+		c.counters[name] = counter
+	}
+	return counter, nil
+}
+
+// Flush отправляет на сервер метрики, изменившиеся после прошлой отправки.
+// Если прошлый пакет не был доставлен из-за недоступности сервера, сначала повторяется он.
+func (c *Client) Flush(ctx context.Context) error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	if c.pending != nil {
+		err := c.sendBatch(ctx, c.pending)
+		if err != nil && isRetryable(err) {
+			return err
+		}
+		c.pending = nil
+		if err != nil {
+			return fmt.Errorf("metrics batch rejected, dropped: %w", err)
+		}
+	}
+
+	b := c.takeBatch()
+	if len(b.metrics) == 0 {
+		return nil
+	}
+
+	if err := c.sendBatch(ctx, b); err != nil {
+		if isRetryable(err) {
+			c.pending = b
+		}
+		return err
+	}
+	return nil
+}
+
+// takeBatch забирает из метрик накопленные значения и формирует из них пакет
+func (c *Client) takeBatch() *batch {
+	c.mu.Lock()
+	gauges := make([]*Gauge, 0, len(c.gauges))
+	for _, gauge := range c.gauges {
+		gauges = append(gauges, gauge)
+	}
+	counters := make([]*Counter, 0, len(c.counters))
+	for _, counter := range c.counters {
+		counters = append(counters, counter)
+	}
+	c.mu.Unlock()
+
+	b := &batch{id: rand.Text(), seq: c.batchSeq.Add(1)}
+	for _, gauge := range gauges {
+		if value, ok := gauge.take(); ok {
+			b.metrics = append(b.metrics, Metric{ID: gauge.name, Type: TypeGauge, Value: &value, Labels: c.labels})
+		}
+	}
+	for _, counter := range counters {
+		if delta := counter.take(); delta != 0 {
+			b.metrics = append(b.metrics, Metric{ID: counter.name, Type: TypeCounter, Delta: &delta, Labels: c.labels})
+		}
+	}
+	return b
+}
+
+// sendBatch отправляет пакет на эндпоинт /updates
+func (c *Client) sendBatch(ctx context.Context, b *batch) error {
+	body, err := json.Marshal(b.metrics)
+	if err != nil {
+		return fmt.Errorf("error while marshalling metrics: %w", err)
+	}
+
+	req, err := c.newRequest(ctx, "updates", body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(addinfo.HeaderAgentID, c.clientID)
+	req.Header.Set(addinfo.HeaderBatchID, b.id)
+	req.Header.Set(addinfo.HeaderBatchSeq, strconv.FormatUint(b.seq, 10))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return &unavailableError{err: err}
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	return statusError(resp)
+}
+
+// Get запрашивает у сервера текущее значение метрики типа mType с идентификатором id
+// и метками клиента. Если метрики нет на сервере, возвращает ErrNotFound.
+func (c *Client) Get(ctx context.Context, mType MetricType, id string) (Metric, error) {
+	body, err := json.Marshal(Metric{ID: id, Type: mType, Labels: c.labels})
+	if err != nil {
+		return Metric{}, fmt.Errorf("error while marshalling metric: %w", err)
+	}
+
+	req, err := c.newRequest(ctx, "value", body)
+	if err != nil {
+		return Metric{}, err
+	}
+	// ответ распаковывается вручную: подпись ответа считается по сжатому телу
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return Metric{}, &unavailableError{err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return Metric{}, fmt.Errorf("%w: %s %s", ErrNotFound, mType, id)
+	}
+	if err := statusError(resp); err != nil {
+		return Metric{}, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Metric{}, fmt.Errorf("cannot read response: %w", err)
+	}
+
+	if hash := resp.Header.Get(hashHeader); hash != "" && c.key != "" {
+		if hash != validation.CalculateHMAC(respBody, c.key) {
+			return Metric{}, errors.New("response signature mismatch")
+		}
+	}
+
+	if strings.Contains(resp.Header.Get("Content-Encoding"), "gzip") {
+		respBody, err = gunzip(respBody)
+		if err != nil {
+			return Metric{}, fmt.Errorf("cannot decompress response: %w", err)
+		}
+	}
+
+	var metric Metric
+	if err := json.Unmarshal(respBody, &metric); err != nil {
+		return Metric{}, fmt.Errorf("cannot decode response: %w", err)
+	}
+	return metric, nil
+}
+
+// newRequest создает POST-запрос на эндпоинт endpoint: тело сжимается gzip,
+// подписывается HMAC (если задан ключ) и шифруется публичным ключом (если задан)
+func (c *Client) newRequest(ctx context.Context, endpoint string, body []byte) (*http.Request, error) {
+	compressed, err := compression.GzipCompress(body)
+	if err != nil {
+		return nil, fmt.Errorf("error while gzip compress metrics: %w", err)
+	}
+
+	encrypted, err := c.encryptor.Encrypt(compressed.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt data: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL.JoinPath(endpoint).String(), bytes.NewReader(encrypted))
+	if err != nil {
+		return nil, fmt.Errorf("cannot create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	// сервер проверяет подпись после расшифровки, поэтому подписывается сжатое тело до шифрования
+	if hash := validation.CalculateHMAC(compressed.Bytes(), c.key); hash != "" {
+		req.Header.Set(hashHeader, hash)
+	}
+	if c.realIP != "" {
+		req.Header.Set(validation.RealIPHeader, c.realIP)
+	}
+	return req, nil
+}
+
+// gunzip распаковывает тело ответа, сжатое gzip
+func gunzip(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// unavailableError - ошибка соединения с сервером
+type unavailableError struct {
+	err error
+}
+
+func (e *unavailableError) Error() string {
+	return fmt.Sprintf("server unavailable: %v", e.err)
+}
+
+func (e *unavailableError) Unwrap() error {
+	return e.err
+}
+
+// StatusError - ответ сервера с кодом, отличным от 200 OK
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
+func statusError(resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	return &StatusError{StatusCode: resp.StatusCode}
+}
+
+// isRetryable сообщает, имеет ли смысл повторить отправку: сервер недоступен или вернул 5xx
+func isRetryable(err error) bool {
+	var unavailable *unavailableError
+	if errors.As(err, &unavailable) {
+		return true
+	}
+	var status *StatusError
+	return errors.As(err, &status) && status.StatusCode >= http.StatusInternalServerError
+}
@@ -0,0 +1,193 @@
+package metricsclient
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/galogen13/yandex-go-metrics/internal/compression"
+	"github.com/galogen13/yandex-go-metrics/internal/crypto"
+	addinfo "github.com/galogen13/yandex-go-metrics/internal/service/additional-info"
+	"github.com/galogen13/yandex-go-metrics/internal/validation"
+)
+
+// testServer - сервер метрик с теми же обработчиками тела запроса, что и у настоящего
+type testServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	batches  [][]Metric
+	batchIDs []string
+	stored   map[string]Metric
+	// failures - сколько запросов на /updates завершить ошибкой 503
+	failures int
+}
+
+func newTestServer(t *testing.T, key string, decryptor *crypto.Decryptor) *testServer {
+	t.Helper()
+
+	s := &testServer{stored: map[string]Metric{}}
+	wrap := func(h http.HandlerFunc) http.HandlerFunc {
+		return crypto.DecryptMiddleware(decryptor, validation.HashValidation(key, compression.GzipMiddleware(h)))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /updates", wrap(func(w http.ResponseWriter, r *http.Request) {
+		var batch []Metric
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.batchIDs = append(s.batchIDs, r.Header.Get(addinfo.HeaderBatchID))
+		if s.failures > 0 {
+			s.failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		s.batches = append(s.batches, batch)
+		for _, metric := range batch {
+			s.stored[string(metric.Type)+metric.ID] = metric
+		}
+	}))
+	mux.HandleFunc("POST /value", wrap(func(w http.ResponseWriter, r *http.Request) {
+		var query Metric
+		if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		metric, ok := s.stored[string(query.Type)+query.ID]
+		s.mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(metric)
+	}))
+
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testServer) received() ([][]Metric, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.batches, s.batchIDs
+}
+
+func TestClient_FlushAndGet(t *testing.T) {
+	privateKey, publicKey, err := crypto.GenerateKeys()
+	require.NoError(t, err)
+	dir := t.TempDir()
+	privateKeyPath := filepath.Join(dir, "private.pem")
+	publicKeyPath := filepath.Join(dir, "public.pem")
+	require.NoError(t, os.WriteFile(privateKeyPath, []byte(privateKey), 0o600))
+	require.NoError(t, os.WriteFile(publicKeyPath, []byte(publicKey), 0o600))
+
+	decryptor, err := crypto.NewDecryptor(privateKeyPath)
+	require.NoError(t, err)
+	server := newTestServer(t, "secret", decryptor)
+
+	client, err := New(Config{
+		Address:       server.URL,
+		Key:           "secret",
+		CryptoKeyPath: publicKeyPath,
+		FlushInterval: -1,
+		Labels:        map[string]string{"service": "billing"},
+	})
+	require.NoError(t, err)
+
+	temperature, err := client.Gauge("Temperature")
+	require.NoError(t, err)
+	requests, err := client.Counter("Requests")
+	require.NoError(t, err)
+	same, err := client.Counter("Requests")
+	require.NoError(t, err)
+	assert.Same(t, requests, same)
+	_, err = client.Gauge("Requests")
+	assert.Error(t, err, "идентификатор уже занят метрикой другого типа")
+
+	temperature.Set(20.5)
+	temperature.Set(21.5)
+	requests.Inc()
+	same.Add(4)
+
+	require.NoError(t, client.Flush(t.Context()))
+	require.NoError(t, client.Flush(t.Context()), "без изменений пакет не отправляется")
+
+	batches, _ := server.received()
+	require.Len(t, batches, 1)
+	got := map[string]Metric{}
+	for _, metric := range batches[0] {
+		got[metric.ID] = metric
+		assert.Equal(t, map[string]string{"service": "billing"}, metric.Labels)
+	}
+	require.Len(t, got, 2)
+	assert.Equal(t, 21.5, *got["Temperature"].Value, "отправляется последнее значение gauge")
+	assert.Equal(t, int64(5), *got["Requests"].Delta, "отправляется сумма приращений counter")
+
+	metric, err := client.Get(t.Context(), TypeGauge, "Temperature")
+	require.NoError(t, err)
+	assert.Equal(t, 21.5, *metric.Value)
+
+	_, err = client.Get(t.Context(), TypeCounter, "Unknown")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, client.Close(t.Context()))
+}
+
+func TestClient_RetryPendingBatch(t *testing.T) {
+	server := newTestServer(t, "", nil)
+	server.failures = 1
+
+	client, err := New(Config{Address: server.URL, FlushInterval: -1})
+	require.NoError(t, err)
+
+	requests, err := client.Counter("Requests")
+	require.NoError(t, err)
+
+	requests.Add(3)
+	assert.Error(t, client.Flush(t.Context()))
+
+	requests.Add(2)
+	require.NoError(t, client.Close(t.Context()), "Close отправляет накопленные значения")
+
+	batches, batchIDs := server.received()
+	require.Len(t, batches, 2)
+	require.Len(t, batchIDs, 3)
+	assert.Equal(t, batchIDs[0], batchIDs[1], "недоставленный пакет повторяется с тем же идентификатором")
+	assert.NotEqual(t, batchIDs[1], batchIDs[2])
+	assert.Equal(t, int64(3), *batches[0][0].Delta)
+	assert.Equal(t, int64(2), *batches[1][0].Delta)
+}
+
+func TestNew_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{name: "Без адреса", cfg: Config{}},
+		{name: "Неподдерживаемая схема", cfg: Config{Address: "ftp://localhost:8080"}},
+		{name: "Неверное имя метки", cfg: Config{Address: "localhost:8080", Labels: map[string]string{"1bad": "x"}}},
+		{name: "Нет файла ключа", cfg: Config{Address: "localhost:8080", CryptoKeyPath: "/no/such/key.pem"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.cfg)
+			assert.Error(t, err)
+		})
+	}
+}
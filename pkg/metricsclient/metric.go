@@ -0,0 +1,75 @@
+package metricsclient
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// MetricType - тип метрики
+type MetricType string
+
+const (
+	// TypeGauge - метрика, хранящая последнее значение
+	TypeGauge MetricType = "gauge"
+	// TypeCounter - метрика, накапливающая сумму приращений
+	TypeCounter MetricType = "counter"
+)
+
+// Metric - метрика в формате JSON-API сервера
+type Metric struct {
+	ID     string            `json:"id"`
+	Type   MetricType        `json:"type"`
+	Delta  *int64            `json:"delta,omitempty"`
+	Value  *float64          `json:"value,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Gauge - метрика типа gauge. На сервер отправляется последнее установленное значение,
+// если оно устанавливалось после прошлой отправки.
+type Gauge struct {
+	name string
+
+	mu    sync.Mutex
+	value float64
+	dirty bool
+}
+
+// Set устанавливает значение метрики
+func (g *Gauge) Set(value float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.value = value
+	g.dirty = true
+}
+
+// take возвращает значение для отправки, если оно устанавливалось после прошлой отправки
+func (g *Gauge) take() (float64, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	dirty := g.dirty
+	g.dirty = false
+	return g.value, dirty
+}
+
+// Counter - метрика типа counter. На сервер отправляется сумма приращений с прошлой отправки.
+type Counter struct {
+	name  string
+	delta atomic.Int64
+}
+
+// Add увеличивает метрику на delta
+func (c *Counter) Add(delta int64) {
+	c.delta.Add(delta)
+}
+
+// Inc увеличивает метрику на 1
+func (c *Counter) Inc() {
+	c.delta.Add(1)
+}
+
+// take возвращает сумму приращений с прошлой отправки и обнуляет ее
+func (c *Counter) take() int64 {
+	return c.delta.Swap(0)
+}